@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------------- ADMIN AUTH ---------------- */
+
+// adminAuth guards /admin and /debug routes with the shared secret in ADMIN_TOKEN,
+// sent as the X-Admin-Token header. Admin routes are disabled when unset.
+func adminAuth() gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		secret := os.Getenv("ADMIN_TOKEN")
+		if secret == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "admin endpoints disabled (ADMIN_TOKEN not set)",
+			})
+			return
+		}
+
+		got := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid admin token",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+/* ---------------- CACHE PURGE ---------------- */
+
+// purgeableCaches maps a cache name to the function that clears it.
+var purgeableCaches = map[string]func(){
+	"cve":         PurgeCVECache,
+	"results":     PurgeResultCache,
+	"idempotency": PurgeIdempotencyKeys,
+}
+
+type purgeRequest struct {
+	Caches []string `json:"caches"`
+}
+
+func handleCachePurge(c *gin.Context) {
+
+	var req purgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if len(req.Caches) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "no caches specified",
+			"available": availableCaches(),
+		})
+		return
+	}
+
+	for _, name := range req.Caches {
+		if _, ok := purgeableCaches[strings.ToLower(name)]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":     "unknown cache: " + name,
+				"available": availableCaches(),
+			})
+			return
+		}
+	}
+
+	purged := []string{}
+	done := map[string]bool{}
+
+	for _, name := range req.Caches {
+		name = strings.ToLower(name)
+		if done[name] {
+			continue
+		}
+
+		purgeableCaches[name]()
+		done[name] = true
+		purged = append(purged, name)
+
+		Logger.Printf("🧹 Admin purged %s cache", name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"purged": purged,
+	})
+}
+
+func availableCaches() []string {
+
+	names := make([]string, 0, len(purgeableCaches))
+	for name := range purgeableCaches {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminRouter serves the admin routes with ADMIN_TOKEN "secret".
+func adminRouter(t *testing.T) *gin.Engine {
+
+	t.Helper()
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	router := gin.New()
+	admin := router.Group("/admin", adminAuth())
+	admin.POST("/cache/purge", handleCachePurge)
+	admin.POST("/replay", handleAlertReplay)
+
+	return router
+}
+
+func adminPost(router *gin.Engine, path, token, body string) *httptest.ResponseRecorder {
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+/* ---------------- CACHE PURGE ---------------- */
+
+func TestCachePurgeIsSelective(t *testing.T) {
+
+	router := adminRouter(t)
+
+	// The real CVE purge refetches from NVD; count calls instead
+	cvePurges := 0
+	prev := purgeableCaches["cve"]
+	purgeableCaches["cve"] = func() { cvePurges++ }
+	t.Cleanup(func() { purgeableCaches["cve"] = prev })
+
+	PurgeResultCache()
+	t.Cleanup(PurgeResultCache)
+	results.put("k", UnifiedResponse{Severity: "high"}, time.Hour)
+
+	w := adminPost(router, "/admin/cache/purge", "secret", `{"caches":["CVE","cve"]}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"purged":["cve"]`) {
+		t.Fatalf("purge cve: %d %s", w.Code, w.Body)
+	}
+	if cvePurges != 1 {
+		t.Errorf("cve purged %d times, want 1", cvePurges)
+	}
+	if _, ok := results.get("k"); !ok {
+		t.Error("result cache purged along with the CVE cache")
+	}
+
+	w = adminPost(router, "/admin/cache/purge", "secret", `{"caches":["results"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("purge results: %d %s", w.Code, w.Body)
+	}
+	if _, ok := results.get("k"); ok {
+		t.Error("result cache not purged")
+	}
+	if cvePurges != 1 {
+		t.Error("CVE cache purged along with the result cache")
+	}
+}
+
+func TestCachePurgeRejectsUnknownCaches(t *testing.T) {
+
+	router := adminRouter(t)
+
+	purged := false
+	prev := purgeableCaches["results"]
+	purgeableCaches["results"] = func() { purged = true }
+	t.Cleanup(func() { purgeableCaches["results"] = prev })
+
+	for _, body := range []string{`{"caches":["results","bogus"]}`, `{"caches":[]}`, `not json`} {
+		if w := adminPost(router, "/admin/cache/purge", "secret", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, w.Code)
+		}
+	}
+
+	if purged {
+		t.Error("purged despite an invalid request")
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+
+	router := adminRouter(t)
+	body := `{"caches":["results"]}`
+
+	if w := adminPost(router, "/admin/cache/purge", "", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: %d, want 401", w.Code)
+	}
+	if w := adminPost(router, "/admin/cache/purge", "wrong", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d, want 401", w.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "")
+	if w := adminPost(router, "/admin/cache/purge", "secret", body); w.Code != http.StatusForbidden {
+		t.Errorf("ADMIN_TOKEN unset: %d, want 403", w.Code)
+	}
+}
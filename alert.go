@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+/* ======================================================
+   🔥 WEBHOOK ALERTS
+   ======================================================
+
+   With ALERT_WEBHOOK_URL set, analyses at or above
+   ALERT_MIN_SEVERITY (default critical) are POSTed as a
+   Slack-compatible {"text": ...} message. Sending does not
+   block the request unless ALERT_SYNC asks for the
+   receiver's event id in the result; background sends go
+   through ALERT_WORKERS workers fed by a queue of
+   ALERT_QUEUE_SIZE. A flapping event alerts at most once
+   per ALERT_COOLDOWN, and ALERT_MAX_PER_MINUTE caps the
+   total so an incident cannot flood the channel. Failed
+   posts are retried with backoff (ALERT_MAX_RETRIES), then
+   dead-lettered (see dlq.go).
+
+   The webhook is the service's only downstream forward, so
+   it is where a gateway forward's options live: with
+   ALERT_SYNC the alert is posted before the response and
+   the receiver's event_id is returned as alert_id, and
+   ALERT_TIMEOUT and ALERT_MAX_RETRIES are its timeout and
+   retry policy, independent of the Watsonx ones.
+*/
+
+type alertLimiter struct {
+	mu       sync.Mutex
+	lastByFP map[string]time.Time
+	window   time.Time
+	sent     int
+}
+
+var alerts = &alertLimiter{lastByFP: map[string]time.Time{}}
+
+// allow reports whether an alert for fingerprint may go out now, and
+// records it if so.
+func (l *alertLimiter) allow(fingerprint string, now time.Time, cooldown time.Duration, maxPerMinute int) bool {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if last, ok := l.lastByFP[fingerprint]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+
+	if now.Sub(l.window) >= time.Minute {
+		l.window = now
+		l.sent = 0
+	}
+	if maxPerMinute > 0 && l.sent >= maxPerMinute {
+		return false
+	}
+
+	// Forget fingerprints whose cooldown has passed
+	for fp, last := range l.lastByFP {
+		if now.Sub(last) >= cooldown {
+			delete(l.lastByFP, fp)
+		}
+	}
+
+	l.lastByFP[fingerprint] = now
+	l.sent++
+
+	return true
+}
+
+// notifyAlert sends the webhook alert for a result above the threshold.
+// cves are the RAG CVEs, best first. Sending is in the background unless
+// ALERT_SYNC is on; then the alert is posted under ctx before the analysis
+// returns and the receiver's id for it, if it answered with one, is
+// returned.
+func notifyAlert(ctx context.Context, event Event, result UnifiedResponse, cves []CVE) (alertID string) {
+
+	url := envString("ALERT_WEBHOOK_URL", "")
+	if url == "" {
+		return ""
+	}
+
+	threshold := normalizeSeverity(envString("ALERT_MIN_SEVERITY", "critical"))
+	if !severityAtLeast(result.Severity, threshold) {
+		return ""
+	}
+
+	if !alerts.allow(result.Fingerprint, time.Now(),
+		envDuration("ALERT_COOLDOWN", 10*time.Minute),
+		envInt("ALERT_MAX_PER_MINUTE", 20),
+	) {
+		LogFields("🔕 Alert suppressed by rate limit",
+			"type", event.Type,
+			"fingerprint", result.Fingerprint,
+		)
+		return ""
+	}
+
+	payload, _ := json.Marshal(map[string]string{
+		"text": alertText(event, result, cves),
+	})
+
+	if envBool("ALERT_SYNC", false) {
+		return deliverAlert(ctx, url, event.Type, payload)
+	}
+
+	if !alertQueue().submit(func(ctx context.Context) { deliverAlert(ctx, url, event.Type, payload) }) {
+		alertDeliveries.WithLabelValues("dropped").Inc()
+		LogFields("🗑️ Alert dropped — delivery queue full", "type", event.Type)
+	}
+
+	return ""
+}
+
+/* ---------------- DELIVERY POOL ---------------- */
+
+var alertQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "aicore_alert_queue_depth",
+	Help: "Alerts waiting for a delivery worker.",
+})
+
+// alertPool runs background deliveries on a fixed number of workers, so
+// a burst of alerts cannot pile up goroutines holding payloads. A full
+// queue drops the alert unless block is set. Jobs run under ctx: once it
+// is cancelled, retries stop and the alerts are dead-lettered.
+type alertPool struct {
+	ctx   context.Context
+	jobs  chan func(ctx context.Context)
+	block bool
+}
+
+var (
+	alertPoolOnce sync.Once
+	alertPoolInst *alertPool
+)
+
+// StartAlertDelivery starts the shared pool from ALERT_WORKERS,
+// ALERT_QUEUE_SIZE and ALERT_QUEUE_BLOCK, its deliveries bounded by ctx.
+// Later calls do nothing.
+func StartAlertDelivery(ctx context.Context) {
+	alertPoolOnce.Do(func() {
+		alertPoolInst = newAlertPool(ctx,
+			envInt("ALERT_WORKERS", 4),
+			envInt("ALERT_QUEUE_SIZE", 100),
+			envBool("ALERT_QUEUE_BLOCK", false),
+		)
+	})
+}
+
+// alertQueue returns the shared pool, started without a deadline if
+// StartAlertDelivery was not called.
+func alertQueue() *alertPool {
+	StartAlertDelivery(context.Background())
+	return alertPoolInst
+}
+
+func newAlertPool(ctx context.Context, workers, size int, block bool) *alertPool {
+
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	p := &alertPool{ctx: ctx, jobs: make(chan func(ctx context.Context), size), block: block}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range p.jobs {
+				alertQueueDepth.Dec()
+				job(p.ctx)
+			}
+		}()
+	}
+
+	return p
+}
+
+// submit queues job, reporting false when the queue is full and the
+// pool drops rather than blocks.
+func (p *alertPool) submit(job func(ctx context.Context)) bool {
+
+	alertQueueDepth.Inc()
+
+	if p.block {
+		p.jobs <- job
+		return true
+	}
+
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		alertQueueDepth.Dec()
+		return false
+	}
+}
+
+// deliverAlert posts the alert, dead-lettering it on failure, and returns
+// the receiver's id for it.
+func deliverAlert(ctx context.Context, url, eventType string, payload []byte) string {
+
+	id, err := postAlert(ctx, url, payload)
+	if err != nil {
+		LogFields("⚠️ Alert webhook failed", "type", eventType, "error", err)
+		deadLetterAlert(url, eventType, payload, err)
+		return ""
+	}
+
+	alertDeliveries.WithLabelValues("sent").Inc()
+
+	return id
+}
+
+// alertText formats the Slack message: severity, event, explanation,
+// action and the top CVE, if any.
+func alertText(event Event, result UnifiedResponse, cves []CVE) string {
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "🚨 *%s* %s", strings.ToUpper(result.Severity), event.Type)
+	if event.SourceHost != "" {
+		fmt.Fprintf(&b, " on %s", event.SourceHost)
+	}
+	fmt.Fprintf(&b, "\n> %s", event.Message)
+	fmt.Fprintf(&b, "\n*Why:* %s", result.Explanation)
+	fmt.Fprintf(&b, "\n*Action:* %s", result.RecommendedAction)
+
+	if len(cves) > 0 {
+		top := cves[0]
+		if top.scored() {
+			fmt.Fprintf(&b, "\n*Top CVE:* %s (CVSS %.1f)", top.ID, top.CVSSScore)
+		} else {
+			fmt.Fprintf(&b, "\n*Top CVE:* %s", top.ID)
+		}
+	}
+
+	return b.String()
+}
+
+// alertRetryPolicy retries 5xx and 429 answers of the webhook. Timeouts
+// (ALERT_TIMEOUT per attempt) are not retried: the receiver may have
+// posted the alert already.
+func alertRetryPolicy() retryPolicy {
+	return retryPolicy{
+		MaxRetries: envInt("ALERT_MAX_RETRIES", 3),
+		BaseDelay:  envDuration("ALERT_RETRY_DELAY", time.Second),
+	}
+}
+
+// postAlert returns the id the receiver assigned to the alert: the
+// "event_id" (or "id") of a JSON answer, "" for others such as Slack's "ok".
+// Cancelling ctx stops the attempt and the retry backoff.
+func postAlert(ctx context.Context, url string, payload []byte) (string, error) {
+
+	client := newHTTPClient(envDuration("ALERT_TIMEOUT", 10*time.Second))
+
+	resp, err := doWithRetry(ctx, client, alertRetryPolicy(), "Alert webhook", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode/100 != 2 {
+		return "", &statusError{Service: "Alert webhook", Code: resp.StatusCode, Body: string(body)}
+	}
+
+	var ack struct {
+		EventID json.RawMessage `json:"event_id"`
+		ID      json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(body, &ack) != nil {
+		return "", nil
+	}
+
+	if id := rawID(ack.EventID); id != "" {
+		return id, nil
+	}
+
+	return rawID(ack.ID), nil
+}
+
+// rawID reads a JSON string or number id.
+func rawID(raw json.RawMessage) string {
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+
+	return ""
+}
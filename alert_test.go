@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubAlertReceiver points ALERT_WEBHOOK_URL at handler with a fresh
+// limiter, no retry delays and no dead-letter file.
+func stubAlertReceiver(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	prev := alerts
+	alerts = &alertLimiter{lastByFP: map[string]time.Time{}}
+	t.Cleanup(func() { alerts = prev })
+
+	t.Setenv("ALERT_WEBHOOK_URL", srv.URL)
+	t.Setenv("ALERT_MIN_SEVERITY", "high")
+	t.Setenv("ALERT_RETRY_DELAY", "1ms")
+	t.Setenv("DLQ_PATH", "")
+
+	return srv
+}
+
+var alertEvent = Event{Type: "bgp_down", Message: "BGP neighbor 10.0.0.1 down"}
+
+/* ---------------- SYNCHRONOUS ALERTS ---------------- */
+
+func TestNotifyAlertSyncReturnsReceiverID(t *testing.T) {
+
+	stubAlertReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"event_id": "evt-1"})
+	})
+	t.Setenv("ALERT_SYNC", "true")
+
+	got := notifyAlert(context.Background(), alertEvent, UnifiedResponse{Severity: "critical", Fingerprint: "fp"}, nil)
+	if got != "evt-1" {
+		t.Errorf("alert id = %q, want evt-1", got)
+	}
+}
+
+func TestNotifyAlertAsyncStillDelivers(t *testing.T) {
+
+	posted := make(chan struct{}, 1)
+	stubAlertReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"event_id": "evt-1"})
+		posted <- struct{}{}
+	})
+
+	if got := notifyAlert(context.Background(), alertEvent, UnifiedResponse{Severity: "critical", Fingerprint: "fp"}, nil); got != "" {
+		t.Errorf("alert id = %q, want none without ALERT_SYNC", got)
+	}
+
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert never posted")
+	}
+}
+
+func TestPostAlertID(t *testing.T) {
+
+	for body, want := range map[string]string{
+		`{"event_id":"evt-1","id":"x"}`: "evt-1",
+		`{"id":42}`:                     "42",
+		`ok`:                            "",
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, body)
+		}))
+
+		got, err := postAlert(context.Background(), srv.URL, []byte(`{"text":"x"}`))
+		srv.Close()
+
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", body, got, err, want)
+		}
+	}
+}
+
+/* ---------------- ALERT TIMEOUT AND RETRIES ---------------- */
+
+func TestPostAlertRetryCount(t *testing.T) {
+
+	for _, retries := range []string{"0", "2"} {
+
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+
+		t.Setenv("ALERT_MAX_RETRIES", retries)
+		t.Setenv("ALERT_RETRY_DELAY", "1ms")
+
+		_, err := postAlert(context.Background(), srv.URL, []byte(`{"text":"x"}`))
+		srv.Close()
+
+		if err == nil {
+			t.Errorf("ALERT_MAX_RETRIES=%s: want an error", retries)
+		}
+		if want := map[string]int{"0": 1, "2": 3}[retries]; calls != want {
+			t.Errorf("ALERT_MAX_RETRIES=%s: %d calls, want %d", retries, calls, want)
+		}
+	}
+}
+
+func TestPostAlertTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	t.Setenv("ALERT_TIMEOUT", "50ms")
+	t.Setenv("ALERT_MAX_RETRIES", "3")
+
+	start := time.Now()
+	_, err := postAlert(context.Background(), srv.URL, []byte(`{"text":"x"}`))
+
+	if err == nil || !isTimeout(err) {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %s with ALERT_TIMEOUT=50ms", elapsed)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d calls, want 1: timeouts are not retried", n)
+	}
+}
+
+/* ---------------- ALERT WORKER POOL ---------------- */
+
+func TestAlertPoolNeverExceedsWorkers(t *testing.T) {
+
+	const workers = 3
+	pool := newAlertPool(context.Background(), workers, 50, true)
+
+	var active, peak atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		pool.submit(func(context.Context) {
+			defer wg.Done()
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			active.Add(-1)
+		})
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > workers {
+		t.Errorf("peak concurrency = %d, want <= %d", got, workers)
+	}
+}
+
+func TestAlertPoolDropsWhenFull(t *testing.T) {
+
+	pool := newAlertPool(context.Background(), 1, 1, false)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	defer close(release)
+
+	pool.submit(func(context.Context) { close(started); <-release })
+	<-started
+
+	if !pool.submit(func(context.Context) {}) {
+		t.Fatal("queue with room rejected a job")
+	}
+	if pool.submit(func(context.Context) {}) {
+		t.Error("full queue accepted a job, want it dropped")
+	}
+}
+
+func TestCancelledPoolStopsRetries(t *testing.T) {
+
+	var calls atomic.Int32
+	srv := stubAlertReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	t.Setenv("ALERT_MAX_RETRIES", "5")
+	t.Setenv("ALERT_RETRY_DELAY", "10s")
+	dlq := filepath.Join(t.TempDir(), "dlq.jsonl")
+	t.Setenv("DLQ_PATH", dlq)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := newAlertPool(ctx, 1, 1, false)
+
+	done := make(chan struct{})
+	start := time.Now()
+	pool.submit(func(ctx context.Context) {
+		deliverAlert(ctx, srv.URL, "bgp_down", []byte(`{"text":"x"}`))
+		close(done)
+	})
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery still retrying after cancel")
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("delivery took %s after cancel", elapsed)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d attempts, want 1 before the cancel", n)
+	}
+	if recs := readDeadLetters(t, dlq); len(recs) != 1 {
+		t.Errorf("%d DLQ entries, want the cancelled alert", len(recs))
+	}
+}
+
+/* ---------------- WEBHOOK PAYLOAD ---------------- */
+
+func TestAlertPayloadForCriticalOnly(t *testing.T) {
+
+	bodies := make(chan map[string]string, 2)
+	stubAlertReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies <- body
+	})
+	t.Setenv("ALERT_SYNC", "true")
+	t.Setenv("ALERT_MIN_SEVERITY", "critical")
+
+	event := Event{Type: "bgp_down", Message: "BGP neighbor 10.0.0.1 down", SourceHost: "edge-1"}
+
+	notifyAlert(context.Background(), event, UnifiedResponse{Severity: "low", Fingerprint: "fp-low"}, nil)
+	notifyAlert(context.Background(), event, UnifiedResponse{
+		Severity:          "critical",
+		Explanation:       "Edge router lost its upstream",
+		RecommendedAction: "Fail over to the backup link",
+		Fingerprint:       "fp-critical",
+	}, []CVE{ciscoIOS})
+	close(bodies)
+
+	var got []map[string]string
+	for b := range bodies {
+		got = append(got, b)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("webhook called %d times, want once (critical only)", len(got))
+	}
+	if len(got[0]) != 1 {
+		t.Errorf("payload keys = %v, want only text", got[0])
+	}
+	if !containsAll(got[0]["text"], "*CRITICAL* bgp_down on edge-1", "BGP neighbor 10.0.0.1 down",
+		"Edge router lost its upstream", "Fail over to the backup link", "*Top CVE:* CVE-2024-0401 (CVSS 8.6)") {
+		t.Errorf("text = %q", got[0]["text"])
+	}
+}
+
+/* ---------------- SEVERITY THRESHOLD ---------------- */
+
+func TestAlertMinSeverityFiltersResults(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var posted atomic.Int32
+	stubAlertReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		posted.Add(1)
+		writeJSON(w, map[string]string{"event_id": "evt-1"})
+	})
+	t.Setenv("ALERT_SYNC", "true")
+	t.Setenv("ALERT_MIN_SEVERITY", "medium")
+
+	var reply atomic.Value
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		generationReply(reply.Load().(string))(w, r)
+	})
+
+	reply.Store(`{"severity":"info"}`)
+	if w := postEvent(t, `{"type":"login","message":"user admin logged in"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if n := posted.Load(); n != 0 {
+		t.Errorf("info result sent %d alerts, want none", n)
+	}
+
+	reply.Store(`{"severity":"high"}`)
+	if w := postEvent(t, `{"type":"bgp_down","message":"BGP neighbor 10.0.0.1 down"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if n := posted.Load(); n != 1 {
+		t.Errorf("high result sent %d alerts, want 1", n)
+	}
+}
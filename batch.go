@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------------- BATCH EVENTS ---------------- */
+
+type batchRequest struct {
+	Events []Event `json:"events"`
+
+	// SharedRAG retrieves CVEs once for the whole batch
+	SharedRAG bool `json:"shared_rag,omitempty"`
+}
+
+type batchItem struct {
+	Result *UnifiedResponse `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// handleEventBatch analyzes up to AI_CORE_BATCH_MAX events with at most
+// AI_CORE_BATCH_CONCURRENCY in flight. Results keep the input order and a
+// failed item carries its error without failing the batch. IAM tokens
+// come from the shared cache, so a batch costs one token fetch per key.
+func handleEventBatch(c *gin.Context) {
+
+	var req batchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidRequest, err.Error())
+		return
+	}
+
+	if max := envInt("AI_CORE_BATCH_MAX", 100); len(req.Events) > max {
+		respondError(c, http.StatusBadRequest, errBatchTooLarge,
+			fmt.Sprintf("batch too large: %d events (max %d)", len(req.Events), max))
+		return
+	}
+
+	concurrency := envInt("AI_CORE_BATCH_CONCURRENCY", 4)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx := c.Request.Context()
+	keepRaw := wantsRawOutput(c)
+
+	var sharedCVEs []CVE
+	useShared := req.SharedRAG && FeatureEnabled(FlagRAG) && batchSharesContext(req.Events)
+
+	if useShared {
+		sharedCVEs = sharedBatchCVEs(ctx, req.Events)
+		Logger.Printf("📦 Batch uses shared RAG context (%d CVEs)", len(sharedCVEs))
+	}
+	items := make([]batchItem, len(req.Events))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, evt := range req.Events {
+
+		if err := validateEvent(evt); err != nil {
+			items[i].Error = err.Error()
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, evt Event) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var result UnifiedResponse
+			var err error
+
+			if useShared {
+				result, err = dispatchWithCVEs(ctx, evt, ragSelection{CVEs: sharedCVEs, Source: ragSourceShared}, analyzeWithProvider)
+			} else {
+				result, err = dispatch(ctx, evt, analyzeWithProvider)
+			}
+
+			applyRawOutput(&result, keepRaw)
+			signResponse(&result)
+
+			items[i].Result = &result
+			if err != nil {
+				items[i].Error = err.Error()
+			}
+		}(i, evt)
+	}
+
+	wg.Wait()
+
+	Logger.Printf("📦 Batch of %d events processed", len(req.Events))
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": items,
+	})
+}
+
+/* ---------------- SHARED BATCH RAG ---------------- */
+
+// batchSharesContext reports whether one RAG context fits every event:
+// they all name the same vendor or all come from the same host. Setting
+// BATCH_SHARED_RAG_ANY=true skips the check and always shares.
+func batchSharesContext(events []Event) bool {
+
+	if envBool("BATCH_SHARED_RAG_ANY", false) {
+		return true
+	}
+
+	if len(events) == 0 {
+		return false
+	}
+
+	sameVendor, sameHost := true, true
+	vendor := extractVendorFromEvent(events[0].Message)
+	host := events[0].SourceHost
+
+	for _, e := range events[1:] {
+		if extractVendorFromEvent(e.Message) != vendor {
+			sameVendor = false
+		}
+		if e.SourceHost != host {
+			sameHost = false
+		}
+	}
+
+	return (sameVendor && vendor != "") || (sameHost && host != "")
+}
+
+// sharedBatchCVEs is the union of each event's relevant CVEs, in first-
+// seen order and capped at RAG_MAX_CVES.
+func sharedBatchCVEs(ctx context.Context, events []Event) []CVE {
+
+	var union []CVE
+	seen := map[string]bool{}
+
+	for _, e := range events {
+		for _, cve := range FindRelevantCVEs(e.Message) {
+			if !seen[cve.ID] {
+				seen[cve.ID] = true
+				union = append(union, cve)
+			}
+		}
+	}
+
+	if max := ragMaxCVEs(); len(union) > max {
+		union = union[:max]
+	}
+
+	if FeatureEnabled(FlagEPSS) {
+		enrichEPSS(ctx, union)
+	}
+
+	return union
+}
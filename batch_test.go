@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------------- BATCH EVENTS ---------------- */
+
+func TestBatchPartialFailureKeepsOrder(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false, FlagRuleFallback: false})
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		if strings.Contains(req.Input, "fan failure") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		generationReply(`{"severity":"low","explanation":"x","recommended_action":"y"}`)(w, r)
+	})
+
+	router := gin.New()
+	router.POST("/events/batch", handleEventBatch)
+
+	body := `{"events":[
+		{"type":"link_down","message":"Gi0/1 down"},
+		{"type":"hw","message":"fan failure"},
+		{"type":"x","message":"y","mode":"bogus"},
+		{"type":"link_up","message":"Gi0/1 up"}
+	]}`
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events/batch", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Results []batchItem `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Results) != 4 {
+		t.Fatalf("results = %d, want 4", len(resp.Results))
+	}
+
+	for i, wantErr := range []bool{false, true, true, false} {
+		item := resp.Results[i]
+		if (item.Error != "") != wantErr {
+			t.Errorf("item %d: error %q, want error=%v", i, item.Error, wantErr)
+		}
+	}
+
+	if r := resp.Results[0].Result; r == nil || r.Severity != "low" {
+		t.Errorf("item 0 = %+v, want low", r)
+	}
+	if r := resp.Results[1].Result; r == nil || r.Severity != "unknown" {
+		t.Errorf("item 1 = %+v, want the unknown fallback", r)
+	}
+}
+
+/* ---------------- SHARED BATCH RAG ---------------- */
+
+func TestSharedRAGMatchesPerEventUnion(t *testing.T) {
+
+	items := append(ciscoCVEs(2), CVE{ID: "CVE-2024-0301", Vendor: "cisco", Product: "nx-os", Vendors: []string{"cisco"}, Products: []string{"nx-os"}, CVSSScore: 8.8, HasScore: true})
+	useRecentCVEs(t, items)
+	t.Setenv("RAG_MIN_RELEVANCE", "1")
+	t.Setenv("BATCH_SHARED_RAG_ANY", "")
+
+	events := []Event{
+		{Type: "crash", Message: "Cisco IOS XE crashed"},
+		{Type: "crash", Message: "Cisco NX-OS crashed"},
+	}
+
+	if !batchSharesContext(events) {
+		t.Fatal("same-vendor batch not shared")
+	}
+
+	perEvent := map[string]bool{}
+	for _, e := range events {
+		for _, c := range FindRelevantCVEs(e.Message) {
+			perEvent[c.ID] = true
+		}
+	}
+
+	shared := sharedBatchCVEs(context.Background(), events)
+
+	if len(shared) != len(perEvent) {
+		t.Errorf("shared %v, per-event %v", cveIDs(shared), perEvent)
+	}
+	for _, c := range shared {
+		if !perEvent[c.ID] {
+			t.Errorf("shared CVE %s not selected for any event", c.ID)
+		}
+	}
+}
+
+func TestHeterogeneousBatchNotShared(t *testing.T) {
+
+	t.Setenv("BATCH_SHARED_RAG_ANY", "")
+
+	events := []Event{
+		{Type: "crash", Message: "Cisco IOS XE crashed", SourceHost: "a"},
+		{Type: "crash", Message: "Juniper Junos crashed", SourceHost: "b"},
+	}
+	if batchSharesContext(events) {
+		t.Error("mixed vendors and hosts shared")
+	}
+
+	events[1].SourceHost = "a"
+	if !batchSharesContext(events) {
+		t.Error("same-host batch not shared")
+	}
+
+	events[1].SourceHost = "b"
+	t.Setenv("BATCH_SHARED_RAG_ANY", "true")
+	if !batchSharesContext(events) {
+		t.Error("BATCH_SHARED_RAG_ANY ignored")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ======================================================
+   🔥 EVENT CORRELATION
+   ======================================================
+
+   With the "correlation" flag on, /events holds full-mode
+   events that name a source_host in a per-host buffer. Each
+   new event restarts the CORRELATION_WINDOW (default 10s);
+   when it passes quietly, or CORRELATION_MAX_EVENTS arrive,
+   the buffer is flushed and analyzed as one incident whose
+   message lists the sequence. Every request in the group
+   gets that analysis, with correlated_events set. A lone
+   event is analyzed as usual. Off by default: each event
+   then waits for nobody.
+*/
+
+const correlatedIncidentType = "correlated_incident"
+
+type correlatedEvent struct {
+	event    Event
+	received time.Time
+}
+
+type eventGroup struct {
+	events []correlatedEvent
+	timer  *time.Timer
+	ctx    context.Context // of the first event, without its cancellation
+
+	done   chan struct{}
+	result UnifiedResponse
+	err    error
+}
+
+type correlator struct {
+	mu     sync.Mutex
+	groups map[string]*eventGroup
+
+	window    func() time.Duration
+	maxEvents func() int
+
+	// analyze runs the grouped (or single) event
+	analyze func(ctx context.Context, event Event) (UnifiedResponse, error)
+}
+
+var correlations = &correlator{
+	groups:    map[string]*eventGroup{},
+	window:    func() time.Duration { return envDuration("CORRELATION_WINDOW", 10*time.Second) },
+	maxEvents: func() int { return envInt("CORRELATION_MAX_EVENTS", 10) },
+	analyze:   DispatchEvent,
+}
+
+// DispatchCorrelated is DispatchEvent, through the correlation buffer
+// when the flag is on.
+func DispatchCorrelated(ctx context.Context, event Event) (UnifiedResponse, error) {
+
+	if !FeatureEnabled(FlagCorrelation) {
+		return DispatchEvent(ctx, event)
+	}
+
+	return correlations.submit(ctx, event)
+}
+
+// correlationKey groups events by host and model. Events without a host,
+// and classify/remediate requests, are not correlated ("").
+func correlationKey(event Event) string {
+
+	host := normalizeEventField(event.SourceHost)
+	if host == "" || (event.Mode != "" && event.Mode != ModeFull) {
+		return ""
+	}
+
+	return host + "\x00" + event.ModelID
+}
+
+// submit adds event to its host's group and waits for the group's
+// analysis.
+func (c *correlator) submit(ctx context.Context, event Event) (UnifiedResponse, error) {
+
+	key := correlationKey(event)
+	if key == "" {
+		return c.analyze(ctx, event)
+	}
+
+	c.mu.Lock()
+
+	g, ok := c.groups[key]
+	if !ok {
+		g = &eventGroup{ctx: context.WithoutCancel(ctx), done: make(chan struct{})}
+		g.timer = time.AfterFunc(c.window(), func() { c.flush(key, g) })
+		c.groups[key] = g
+	} else {
+		g.timer.Reset(c.window())
+	}
+
+	g.events = append(g.events, correlatedEvent{event: event, received: time.Now()})
+	full := len(g.events) >= c.maxEvents()
+
+	c.mu.Unlock()
+
+	if full {
+		go c.flush(key, g)
+	}
+
+	select {
+	case <-g.done:
+		return g.result, g.err
+	case <-ctx.Done():
+		return UnifiedResponse{
+			Severity:          severityUnknown,
+			Explanation:       ctx.Err().Error(),
+			RecommendedAction: "Check logs",
+		}, ctx.Err()
+	}
+}
+
+// flush analyzes g once, whichever of the timer and the size limit
+// fires first.
+func (c *correlator) flush(key string, g *eventGroup) {
+
+	c.mu.Lock()
+	if c.groups[key] != g {
+		c.mu.Unlock()
+		return // already flushed
+	}
+	delete(c.groups, key)
+	g.timer.Stop()
+	events := g.events
+	c.mu.Unlock()
+
+	if len(events) == 1 {
+		g.result, g.err = c.analyze(g.ctx, events[0].event)
+		close(g.done)
+		return
+	}
+
+	LogFields("🔗 Correlated events into one incident",
+		"host", events[0].event.SourceHost,
+		"events", len(events),
+		"span", events[len(events)-1].received.Sub(events[0].received).Round(time.Millisecond),
+	)
+
+	g.result, g.err = c.analyze(g.ctx, incidentEvent(events))
+	g.result.CorrelatedEvents = len(events)
+	close(g.done)
+}
+
+// incidentEvent describes the sequence as one event: the shared type (or
+// correlated_incident), the host's metadata and one message line per
+// event with its offset from the first.
+func incidentEvent(events []correlatedEvent) Event {
+
+	first := events[0].event
+
+	incident := Event{
+		Type:             first.Type,
+		SourceHost:       first.SourceHost,
+		SourceIP:         first.SourceIP,
+		Category:         first.Category,
+		ModelID:          first.ModelID,
+		Language:         first.Language,
+		AssetCriticality: first.AssetCriticality,
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sequence of %d related events from %s, oldest first:", len(events), first.SourceHost)
+
+	for i, ce := range events {
+
+		e := ce.event
+		if e.Type != incident.Type {
+			incident.Type = correlatedIncidentType
+		}
+		if e.Category != incident.Category {
+			incident.Category = ""
+		}
+		// Criticality levels share their names with severities
+		if severityRank[e.AssetCriticality] > severityRank[incident.AssetCriticality] {
+			incident.AssetCriticality = e.AssetCriticality
+		}
+
+		offset := ce.received.Sub(events[0].received).Round(time.Second)
+		fmt.Fprintf(&b, "\n%d. [+%s] %s: %s", i+1, offset, e.Type, e.Message)
+	}
+
+	incident.Message = b.String()
+
+	return incident
+}
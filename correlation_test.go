@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCorrelator returns a correlator whose analyses are recorded in
+// seen instead of reaching a model.
+func testCorrelator(window time.Duration, maxEvents int, seen *[]Event) *correlator {
+
+	var mu sync.Mutex
+
+	return &correlator{
+		groups:    map[string]*eventGroup{},
+		window:    func() time.Duration { return window },
+		maxEvents: func() int { return maxEvents },
+		analyze: func(ctx context.Context, event Event) (UnifiedResponse, error) {
+			mu.Lock()
+			*seen = append(*seen, event)
+			mu.Unlock()
+			return UnifiedResponse{Severity: "high", Explanation: event.Message}, nil
+		},
+	}
+}
+
+/* ---------------- EVENT CORRELATION ---------------- */
+
+func TestBurstFromOneHostAnalyzedOnce(t *testing.T) {
+
+	var seen []Event
+	c := testCorrelator(100*time.Millisecond, 10, &seen)
+
+	messages := []string{"Gi0/1 down", "Gi0/2 down", "BGP neighbor 10.0.0.2 down"}
+	results := make([]UnifiedResponse, len(messages))
+
+	var wg sync.WaitGroup
+	for i, message := range messages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = c.submit(context.Background(), Event{Type: "link_down", SourceHost: "core-sw1", Message: message})
+		}()
+		time.Sleep(10 * time.Millisecond) // keep arrival order
+	}
+
+	lone, _ := c.submit(context.Background(), Event{Type: "link_down", SourceHost: "edge-rtr2", Message: "Gi0/9 down"})
+	wg.Wait()
+
+	if len(seen) != 2 {
+		t.Fatalf("%d analyses, want the burst and the lone event", len(seen))
+	}
+	if lone.CorrelatedEvents != 0 {
+		t.Errorf("lone event correlated_events = %d", lone.CorrelatedEvents)
+	}
+
+	var incident Event
+	for _, e := range seen {
+		if e.SourceHost == "core-sw1" {
+			incident = e
+		}
+	}
+	if !containsAll(incident.Message, append(messages, "Sequence of 3 related events from core-sw1")...) {
+		t.Errorf("incident message:\n%s", incident.Message)
+	}
+	if strings.Index(incident.Message, messages[0]) > strings.Index(incident.Message, messages[2]) {
+		t.Error("incident lists the events out of order")
+	}
+
+	for i, r := range results {
+		if r.CorrelatedEvents != 3 || r.Explanation != incident.Message {
+			t.Errorf("request %d got %+v, want the shared incident analysis", i, r)
+		}
+	}
+}
+
+func TestFullGroupFlushesBeforeWindow(t *testing.T) {
+
+	var seen []Event
+	c := testCorrelator(time.Hour, 2, &seen)
+
+	done := make(chan UnifiedResponse)
+	go func() {
+		r, _ := c.submit(context.Background(), Event{SourceHost: "sw1", Message: "first"})
+		done <- r
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	r, _ := c.submit(context.Background(), Event{SourceHost: "sw1", Message: "second"})
+	if r.CorrelatedEvents != 2 || (<-done).CorrelatedEvents != 2 {
+		t.Errorf("full group not flushed as one incident: %+v", r)
+	}
+}
@@ -1,29 +1,97 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 /* ---------------- CONFIG ---------------- */
 
-const cacheFile = "cve_cache.json"
+const defaultCacheFile = "cve_cache.json"
 const freshnessWindow = 15 * time.Minute
 
+// NVD only accepts publication windows of up to 120 days
+const maxLookbackDays = 120
+
+var defaultNetworkVendors = []string{
+	"cisco", "juniper", "fortinet", "mikrotik",
+	"paloalto", "netgear", "dlink", "tplink",
+	"ubiquiti", "arista",
+}
+
+// nvdLookbackDays reads NVD_LOOKBACK_DAYS (default 7, capped at 120).
+func nvdLookbackDays() int {
+
+	days := envInt("NVD_LOOKBACK_DAYS", 7)
+
+	switch {
+	case days < 1:
+		return 7
+	case days > maxLookbackDays:
+		return maxLookbackDays
+	}
+
+	return days
+}
+
 /* ---------------- CVE STRUCT ---------------- */
 
 type CVE struct {
-	ID          string  `json:"id"`
-	Description string  `json:"description"`
-	Published   string  `json:"published"`
-	CVSSScore   float64 `json:"cvss_score"`
-	Vendor      string  `json:"vendor"`
-	Product     string  `json:"product"`
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Published   string   `json:"published"`
+	CVSSScore   float64  `json:"cvss_score"`
+	HasScore    bool     `json:"has_score"`
+	Vendor      string   `json:"vendor"`
+	Product     string   `json:"product"`
+	Vendors     []string `json:"vendors,omitempty"`
+	Products    []string `json:"products,omitempty"`
+	CWEs        []string `json:"cwes,omitempty"`
+
+	// KnownExploited is set from the CISA KEV catalog
+	KnownExploited bool `json:"known_exploited,omitempty"`
+
+	// FIRST EPSS probability (0–1) and percentile, filled per request
+	EPSSScore      float64 `json:"epss_score,omitempty"`
+	EPSSPercentile float64 `json:"epss_percentile,omitempty"`
+}
+
+// scored distinguishes a real 0.0 from "not scored yet". Caches written
+// before HasScore existed only carry a non-zero score.
+func (c CVE) scored() bool {
+	return c.HasScore || c.CVSSScore > 0
+}
+
+// allVendors and allProducts fall back to Vendor/Product for caches
+// written before multi-vendor parsing.
+func (c CVE) allVendors() []string {
+	if len(c.Vendors) > 0 {
+		return c.Vendors
+	}
+	if c.Vendor != "" {
+		return []string{c.Vendor}
+	}
+	return nil
+}
+
+func (c CVE) allProducts() []string {
+	if len(c.Products) > 0 {
+		return c.Products
+	}
+	if c.Product != "" {
+		return []string{c.Product}
+	}
+	return nil
 }
 
 /* ---------------- FILE CACHE STRUCT ---------------- */
@@ -36,57 +104,394 @@ type cveCacheFile struct {
 /* ---------------- MEMORY STORAGE ---------------- */
 
 var (
-	recentCVEs []CVE
-	cveMutex   sync.RWMutex
+	recentCVEs   []CVE
+	cveUpdatedAt time.Time // when recentCVEs were fetched from NVD
+	lastNVDError string    // last NVD fetch failure, cleared on success
+	cveMutex     sync.RWMutex
 )
 
 /* ======================================================
    🔥 LOAD OR FETCH CVEs
    ====================================================== */
 
-func EnsureRecentNetworkCVEs() error {
+// cveRefreshes lets concurrent callers (startup, refresher, purge) share
+// one NVD fetch instead of each hitting the rate limit and the file.
+var cveRefreshes singleflight.Group
+
+// EnsureRecentNetworkCVEs loads fresh CVEs from the cache file or NVD.
+// Callers arriving while a refresh runs wait for it and get its result;
+// the refresh runs under the ctx of the caller that started it.
+func EnsureRecentNetworkCVEs(ctx context.Context) error {
+
+	_, err, _ := cveRefreshes.Do("cves", func() (interface{}, error) {
+		return nil, ensureRecentNetworkCVEs(ctx)
+	})
+
+	return err
+}
+
+func ensureRecentNetworkCVEs(ctx context.Context) error {
 
 	cache, err := loadCacheFromFile()
 
 	if err == nil && time.Since(cache.Timestamp) < freshnessWindow {
 
-		cveMutex.Lock()
-		recentCVEs = cache.CVEs
-		cveMutex.Unlock()
+		setRecentCVEs(cache.CVEs, cache.Timestamp)
 
-		Logger.Println("✅ Loaded CVEs from cache file")
+		LogFields("✅ Loaded CVEs from cache file",
+			"cve_count", len(cache.CVEs),
+			"cache_age", time.Since(cache.Timestamp).Round(time.Second),
+		)
 		return nil
 	}
 
+	// A recent cache only needs what NVD changed since it was written
+	if err == nil && incrementalSyncPossible(cache) {
+		return syncModifiedCVEs(ctx, cache)
+	}
+
 	Logger.Println("🌐 Fetching fresh CVEs from NVD")
 
-	items, err := fetchRecentCVEsFromNVD(7)
-	if err != nil {
-		return err
+	started := time.Now().UTC()
+	items, fetchErr := fetchRecentCVEsFromNVD(ctx, nvdLookbackDays())
+	setLastNVDError(fetchErr)
+
+	var partial *nvdPartialError
+	if errors.As(fetchErr, &partial) {
+		return usePartialCVEs(items, partial)
+	}
+
+	if fetchErr != nil {
+		if err == nil {
+			serveStaleCache(cache, fetchErr)
+		}
+		return fetchErr
 	}
 
 	filtered := filterNetworkCVEs(items)
 	if len(filtered) == 0 {
-		Logger.Println("⚠️ No network CVEs found — using all CVEs")
+		LogFields("⚠️ No network CVEs found — using all CVEs",
+			"cve_count", len(items),
+		)
 		filtered = items
 	}
 
-	saveCacheToFile(filtered)
+	saveCacheToFile(filtered, started)
+	setRecentCVEs(filtered, started)
+
+	LogFields("✅ Stored CVEs",
+		"status", "fresh",
+		"cve_count", len(filtered),
+	)
+
+	return nil
+}
+
+// serveStaleCache loads an outdated cache file when the NVD fetch failed
+// and nothing is loaded yet. Opt-in with CVE_SERVE_STALE: by default a
+// failed fetch leaves RAG without CVEs, as it always has.
+func serveStaleCache(cache *cveCacheFile, fetchErr error) {
+
+	if !envBool("CVE_SERVE_STALE", false) || len(GetRecentCVEs()) > 0 {
+		return
+	}
+
+	setRecentCVEs(cache.CVEs, cache.Timestamp)
+
+	LogFields("⚠️ NVD fetch failed — serving stale CVE cache",
+		"status", "stale",
+		"cve_count", len(cache.CVEs),
+		"cache_age", time.Since(cache.Timestamp).Round(time.Second),
+		"error", fetchErr,
+	)
+}
+
+/* ---------------- INCREMENTAL SYNC ---------------- */
+
+// incrementalSyncPossible reports whether cache can be brought up to date
+// with lastModStartDate instead of a full refetch: NVD_INCREMENTAL is on
+// (default) and the cache is younger than the lookback window. An older
+// cache is missing CVEs that changes alone cannot bring back.
+func incrementalSyncPossible(cache *cveCacheFile) bool {
+
+	if !envBool("NVD_INCREMENTAL", true) || len(cache.CVEs) == 0 {
+		return false
+	}
+
+	return time.Since(cache.Timestamp) < time.Duration(nvdLookbackDays())*24*time.Hour
+}
+
+// syncModifiedCVEs merges the CVEs NVD published or changed since the
+// cache was written into it. An incomplete fetch is served but not
+// written, so the next refresh asks for the same changes again.
+func syncModifiedCVEs(ctx context.Context, cache *cveCacheFile) error {
+
+	LogFields("🌐 Fetching CVE changes from NVD", "since", cache.Timestamp.Format(time.RFC3339))
+
+	started := time.Now().UTC()
+	updates, fetchErr := fetchModifiedCVEsFromNVD(ctx, cache.Timestamp)
+	setLastNVDError(fetchErr)
+
+	var partial *nvdPartialError
+	if fetchErr != nil && !errors.As(fetchErr, &partial) {
+		serveStaleCache(cache, fetchErr)
+		return fetchErr
+	}
+
+	// Filtering after the merge also drops CVEs an update took out of
+	// scope (e.g. CVSS rescored below CVE_MIN_CVSS)
+	cutoff := started.AddDate(0, 0, -nvdLookbackDays())
+	merged := mergeCVEs(cache.CVEs, updates, cutoff)
+	if filtered := filterNetworkCVEs(merged); len(filtered) > 0 {
+		merged = filtered
+	}
+
+	if partial != nil {
+		setRecentCVEs(merged, cache.Timestamp)
+
+		LogFields("⚠️ Merged CVE changes from incomplete NVD fetch",
+			"status", "partial",
+			"cve_count", len(merged),
+			"changed", len(updates),
+			"error", partial.Err,
+		)
+		return nil
+	}
+
+	saveCacheToFile(merged, started)
+	setRecentCVEs(merged, started)
+
+	LogFields("✅ Merged CVE changes",
+		"status", "fresh",
+		"cve_count", len(merged),
+		"changed", len(updates),
+	)
+
+	return nil
+}
+
+// mergeCVEs replaces current CVEs by ID with their updated version, adds
+// new ones and drops those published before cutoff (out of the lookback
+// window, as a full fetch would). CVEs with no parsable date are kept.
+func mergeCVEs(current, updates []CVE, cutoff time.Time) []CVE {
+
+	updated := make(map[string]CVE, len(updates))
+	for _, c := range updates {
+		updated[c.ID] = c
+	}
+
+	inWindow := func(c CVE) bool {
+		published := parsePublished(c.Published)
+		return published.IsZero() || !published.Before(cutoff)
+	}
+
+	merged := make([]CVE, 0, len(current)+len(updates))
+
+	for _, c := range current {
+		if u, ok := updated[c.ID]; ok {
+			c = u
+			delete(updated, c.ID)
+		}
+		if inWindow(c) {
+			merged = append(merged, c)
+		}
+	}
+
+	// New CVEs in NVD's order
+	for _, c := range updates {
+		if _, ok := updated[c.ID]; ok && inWindow(c) {
+			merged = append(merged, c)
+			delete(updated, c.ID)
+		}
+	}
+
+	return merged
+}
+
+// usePartialCVEs serves the CVEs of an incomplete NVD fetch unless more
+// are already loaded. They are not written to the cache file, so the next
+// refresh fetches again.
+func usePartialCVEs(items []CVE, partial *nvdPartialError) error {
+
+	filtered := filterNetworkCVEs(items)
+	if len(filtered) == 0 {
+		filtered = items
+	}
+
+	if current := len(GetRecentCVEs()); current >= len(filtered) {
+		LogFields("⚠️ Incomplete NVD fetch — keeping loaded CVEs",
+			"status", "partial",
+			"cve_count", current,
+			"fetched", len(filtered),
+		)
+		return nil
+	}
+
+	setRecentCVEs(filtered, time.Now().UTC())
+
+	LogFields("⚠️ Stored CVEs from incomplete NVD fetch",
+		"status", "partial",
+		"cve_count", len(filtered),
+		"error", partial.Err,
+	)
+
+	return nil
+}
+
+// setRecentCVEs enriches the CVEs (KEV flags) and makes them current.
+// fetchedAt is when they came from NVD (the cache timestamp for cached
+// CVEs).
+func setRecentCVEs(items []CVE, fetchedAt time.Time) {
+
+	items = dedupeCVEs(items)
+
+	if FeatureEnabled(FlagKEV) {
+		if err := EnsureKEVCatalog(); err != nil {
+			LogFields("⚠️ KEV catalog unavailable", "error", err)
+		}
+		markKnownExploited(items)
+	}
+
+	index := buildCVEIndex(items)
 
 	cveMutex.Lock()
-	recentCVEs = filtered
+	recentCVEs = items
+	recentCVEIndex = index
+	cveUpdatedAt = fetchedAt
 	cveMutex.Unlock()
+}
+
+func setLastNVDError(err error) {
+
+	cveMutex.Lock()
+	defer cveMutex.Unlock()
+
+	lastNVDError = ""
+	if err != nil {
+		lastNVDError = err.Error()
+	}
+}
+
+// cveCacheStatus reports the CVE count, when they were fetched (zero if
+// none are loaded) and the last NVD error, for /health.
+func cveCacheStatus() (count int, updatedAt time.Time, nvdErr string) {
+
+	cveMutex.RLock()
+	defer cveMutex.RUnlock()
+
+	return len(recentCVEs), cveUpdatedAt, lastNVDError
+}
 
-	Logger.Printf("✅ Stored %d CVEs", len(filtered))
+/* ======================================================
+   🔥 BACKGROUND REFRESHER
+   ====================================================== */
+
+// StartCVERefresher calls EnsureRecentNetworkCVEs on every tick until ctx
+// is cancelled. A failed refresh keeps serving the last good CVEs.
+func StartCVERefresher(ctx context.Context, interval time.Duration) {
+
+	go func() {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				Logger.Println("🛑 CVE refresher stopped")
+				return
+
+			case <-ticker.C:
+				Logger.Println("🔄 Checking CVE cache freshness...")
+
+				if err := EnsureRecentNetworkCVEs(ctx); err != nil {
+					LogFields("⚠️ CVE refresh error — keeping cached CVEs",
+						"cve_count", len(GetRecentCVEs()),
+						"error", err,
+					)
+					continue
+				}
+
+				Logger.Println("✅ CVE cache check complete")
+			}
+		}
+	}()
+}
+
+/* ======================================================
+   🔥 STRICT FRESHNESS (STARTUP)
+   ======================================================
+
+   CVE_STRICT_FRESHNESS=true refuses to start when the NVD
+   fetch failed and the cache on disk is missing or older
+   than CVE_MAX_CACHE_AGE (default 24h). Lenient by default.
+*/
+
+func CheckStartupCVEFreshness() error {
+
+	if !FeatureEnabled(FlagStrictFresh) {
+		return nil
+	}
+
+	maxAge := envDuration("CVE_MAX_CACHE_AGE", 24*time.Hour)
+
+	cache, err := loadCacheFromFile()
+	if err != nil {
+		return fmt.Errorf("strict freshness: no usable CVE cache: %w", err)
+	}
+
+	if age := time.Since(cache.Timestamp); age > maxAge {
+		return fmt.Errorf("strict freshness: CVE cache is %s old (max %s)",
+			age.Round(time.Second), maxAge)
+	}
 
 	return nil
 }
 
+/* ---------------- PURGE ---------------- */
+
+// PurgeCVECache drops the in-memory CVEs and the cache file, then
+// refetches from NVD in the background.
+func PurgeCVECache() {
+
+	cveMutex.Lock()
+	recentCVEs = nil
+	recentCVEIndex = nil
+	cveUpdatedAt = time.Time{}
+	cveMutex.Unlock()
+
+	cacheFileMutex.Lock()
+	err := os.Remove(cveCachePath())
+	cacheFileMutex.Unlock()
+
+	if err != nil && !os.IsNotExist(err) {
+		LogFields("⚠️ Failed to remove CVE cache file", "error", err)
+	}
+
+	go func() {
+		if err := EnsureRecentNetworkCVEs(context.Background()); err != nil {
+			LogFields("⚠️ CVE refetch after purge failed", "error", err)
+		}
+	}()
+}
+
 /* ---------------- FILE OPERATIONS ---------------- */
 
+// cacheFileMutex serializes writes, removals and reads of the cache file.
+var cacheFileMutex sync.Mutex
+
+// cveCachePath reads CVE_CACHE_PATH (default cve_cache.json in the
+// working directory).
+func cveCachePath() string {
+	return envString("CVE_CACHE_PATH", defaultCacheFile)
+}
+
 func loadCacheFromFile() (*cveCacheFile, error) {
 
-	data, err := os.ReadFile(cacheFile)
+	cacheFileMutex.Lock()
+	data, err := os.ReadFile(cveCachePath())
+	cacheFileMutex.Unlock()
+
 	if err != nil {
 		return nil, err
 	}
@@ -99,48 +504,108 @@ func loadCacheFromFile() (*cveCacheFile, error) {
 	return &cache, nil
 }
 
-func saveCacheToFile(items []CVE) {
+// saveCacheToFile writes items stamped with fetchedAt, the start of the
+// NVD query that produced them: the next incremental sync asks for
+// changes since then.
+func saveCacheToFile(items []CVE, fetchedAt time.Time) {
 
 	cache := cveCacheFile{
-		Timestamp: time.Now().UTC(),
-		CVEs:      items,
+		Timestamp: fetchedAt,
+		CVEs:      dedupeCVEs(items),
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		LogFields("⚠️ Failed to encode CVE cache", "error", err)
+		return
 	}
 
-	data, _ := json.MarshalIndent(cache, "", "  ")
-	_ = os.WriteFile(cacheFile, data, 0644)
+	cacheFileMutex.Lock()
+	defer cacheFileMutex.Unlock()
+
+	if err := writeFileAtomic(cveCachePath(), data, 0644); err != nil {
+		LogFields("⚠️ Failed to write CVE cache file",
+			"path", cveCachePath(),
+			"error", err,
+		)
+	}
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it
+// into place, so readers see either the old or the new file, never a
+// partial one.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	// Clean up the temp file unless the rename succeeded
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
 }
 
 /* ======================================================
    🔥 NETWORK CVE FILTER
    ====================================================== */
 
+// filterNetworkCVEs keeps CVEs from CVE_VENDORS (comma-separated,
+// defaults to defaultNetworkVendors) scoring at least CVE_MIN_CVSS (7.0).
 func filterNetworkCVEs(items []CVE) []CVE {
 
-	networkVendors := []string{
-		"cisco", "juniper", "fortinet", "mikrotik",
-		"paloalto", "netgear", "dlink", "tplink",
-		"ubiquiti", "arista",
-	}
+	networkVendors := envList("CVE_VENDORS", defaultNetworkVendors)
+	minCVSS := envFloat("CVE_MIN_CVSS", 7.0)
 
 	var result []CVE
 
 	for _, c := range items {
 
-		if c.CVSSScore < 7.0 {
+		if c.CVSSScore < minCVSS {
 			continue
 		}
 
-		vendor := strings.ToLower(c.Vendor)
+		if matchesAnyVendor(c, networkVendors) {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
 
-		for _, nv := range networkVendors {
-			if vendor == nv {
-				result = append(result, c)
-				break
+func matchesAnyVendor(c CVE, vendors []string) bool {
+
+	for _, v := range c.allVendors() {
+		for _, nv := range vendors {
+			if sameVendor(v, nv) {
+				return true
 			}
 		}
 	}
 
-	return result
+	return false
 }
 
 /* ======================================================
@@ -169,29 +634,17 @@ func BuildCVERagBlock() string {
 		return ""
 	}
 
-	sort.Slice(items, func(i, j int) bool {
-		return parsePublished(items[i].Published).
-			After(parsePublished(items[j].Published))
-	})
+	rankCVEs(items)
 
-	if len(items) > 5 {
-		items = items[:5]
+	if max := ragMaxCVEs(); len(items) > max {
+		items = items[:max]
 	}
 
 	var b strings.Builder
 	b.WriteString("<Rag>\n")
 
 	for _, c := range items {
-
-		score := "N/A"
-		if c.CVSSScore > 0 {
-			score = fmt.Sprintf("%.1f", c.CVSSScore)
-		}
-
-		b.WriteString(
-			fmt.Sprintf("%s - %s/%s - CVSS %s\n",
-				c.ID, c.Vendor, c.Product, score),
-		)
+		b.WriteString(formatRagLine(c))
 	}
 
 	b.WriteString("</Rag>\n")
@@ -202,19 +655,15 @@ func BuildCVERagBlock() string {
    🔥 EVENT-AWARE RAG BLOCK
    ====================================================== */
 
+// extractVendorFromEvent returns the canonical name of the first network
+// vendor mentioned in text under any of its spellings.
 func extractVendorFromEvent(text string) string {
 
 	text = strings.ToLower(text)
 
-	vendors := []string{
-		"cisco", "juniper", "fortinet", "mikrotik",
-		"palo alto", "netgear", "d-link", "tp-link",
-		"ubiquiti", "arista",
-	}
-
-	for _, v := range vendors {
-		if strings.Contains(text, v) {
-			return strings.ReplaceAll(v, " ", "")
+	for _, v := range defaultNetworkVendors {
+		if containsAnyWord(text, vendorSpellings(v), minTermLen) {
+			return canonicalVendor(v)
 		}
 	}
 
@@ -234,7 +683,7 @@ func BuildCVERagBlockForEvent(event Event) string {
 
 	if vendor != "" {
 		for _, c := range items {
-			if strings.ToLower(c.Vendor) == vendor {
+			if sameVendor(c.Vendor, vendor) {
 				filtered = append(filtered, c)
 			}
 		}
@@ -244,29 +693,17 @@ func BuildCVERagBlockForEvent(event Event) string {
 		filtered = items
 	}
 
-	sort.Slice(filtered, func(i, j int) bool {
-		return parsePublished(filtered[i].Published).
-			After(parsePublished(filtered[j].Published))
-	})
+	rankCVEs(filtered)
 
-	if len(filtered) > 5 {
-		filtered = filtered[:5]
+	if max := ragMaxCVEs(); len(filtered) > max {
+		filtered = filtered[:max]
 	}
 
 	var b strings.Builder
 	b.WriteString("<Rag>\n")
 
 	for _, c := range filtered {
-
-		score := "N/A"
-		if c.CVSSScore > 0 {
-			score = fmt.Sprintf("%.1f", c.CVSSScore)
-		}
-
-		b.WriteString(
-			fmt.Sprintf("%s - %s/%s - CVSS %s\n",
-				c.ID, c.Vendor, c.Product, score),
-		)
+		b.WriteString(formatRagLine(c))
 	}
 
 	b.WriteString("</Rag>\n")
@@ -277,58 +714,286 @@ func BuildCVERagBlockForEvent(event Event) string {
    🔥 FIND RELEVANT CVEs FOR EVENT
    ====================================================== */
 
-func FindRelevantCVEs(text string) []CVE {
+// cveRelevance scores how well a CVE matches the (lowercased) event text:
+// 0.5 for any of its vendors (under any alias) and 0.5 for any of its
+// products appearing in it as whole words. CVEs with no vendor/product
+// data score 0. Product names shorter than shortProductLen ("ios", "go")
+// only count when a vendor matched too, so "kiosk" or "ongoing" don't
+// pull in CVEs.
+func cveRelevance(text string, c CVE) float64 {
+
+	score := 0.0
+
+	vendorHit := false
+	for _, v := range c.allVendors() {
+		if containsAnyWord(text, vendorSpellings(v), minTermLen) {
+			vendorHit = true
+			break
+		}
+	}
+	if vendorHit {
+		score += 0.5
+	}
 
-	items := GetRecentCVEs()
-	if len(items) == 0 {
-		return nil
+	for _, p := range c.allProducts() {
+		if len(p) < shortProductLen && !vendorHit {
+			continue
+		}
+		if containsWord(text, p, minTermLen) {
+			score += 0.5
+			break
+		}
+	}
+
+	return score
+}
+
+const (
+	minTermLen      = 2
+	shortProductLen = 4
+)
+
+func containsAnyWord(text string, terms []string, minLen int) bool {
+
+	for _, t := range terms {
+		if containsWord(text, t, minLen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsWord reports whether term occurs in text with no letter or
+// digit directly before or after it. CPE underscores match spaces
+// ("ios_xe" matches "ios xe").
+func containsWord(text, term string, minLen int) bool {
+
+	term = strings.ToLower(strings.ReplaceAll(term, "_", " "))
+	if len(term) < minLen {
+		return false
 	}
 
+	for from := 0; ; {
+
+		i := strings.Index(text[from:], term)
+		if i < 0 {
+			return false
+		}
+
+		start := from + i
+		end := start + len(term)
+
+		if (start == 0 || !isWordByte(text[start-1])) &&
+			(end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+
+		from = start + 1
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// FindRelevantCVEs returns up to RAG_MAX_CVES matching CVEs, best match
+// first, topped up with the highest-priority CVEs to reach RAG_MIN_CVES. With
+// RAG_MIN_RELEVANCE > 0 only CVEs scoring at least that much are kept and
+// an event with no such match gets no CVEs (no RAG block) instead of the
+// priority fallback.
+func FindRelevantCVEs(text string) []CVE {
+	return findRelevantCVEs(text).CVEs
+}
+
+// findRelevantCVEs is FindRelevantCVEs, also reporting whether the CVEs
+// matched the event or came from the priority fallback.
+func findRelevantCVEs(text string) ragSelection {
+
 	text = strings.ToLower(text)
 
-	var result []CVE
+	// Only CVEs whose vendor or product occurs in the text can score
+	candidates, total := candidateCVEs(text)
+	if total == 0 {
+		return ragSelection{Source: ragSourceNone}
+	}
 
-	for _, c := range items {
+	minRelevance := envFloat("RAG_MIN_RELEVANCE", 0)
 
-		if strings.Contains(text, strings.ToLower(c.Vendor)) ||
-			strings.Contains(text, strings.ToLower(c.Product)) {
+	type scored struct {
+		cve   CVE
+		score float64
+	}
 
-			result = append(result, c)
+	var matches []scored
+
+	for _, c := range candidates {
+
+		score := cveRelevance(text, c)
+		if score > 0 && score >= minRelevance {
+			matches = append(matches, scored{c, score})
 		}
 	}
 
-	// fallback → most recent CVEs
-	if len(result) == 0 {
+	// fallback → highest-priority CVEs, unless the relevance gate is on
+	if len(matches) == 0 {
+
+		if minRelevance > 0 {
+			LogFields("🚫 No CVE meets relevance — skipping RAG",
+				"min_relevance", minRelevance,
+				"vendor", extractVendorFromEvent(text),
+			)
+			return ragSelection{Source: ragSourceNone}
+		}
 
-		sort.Slice(items, func(i, j int) bool {
-			return parsePublished(items[i].Published).
-				After(parsePublished(items[j].Published))
-		})
+		items := GetRecentCVEs()
+		rankCVEs(items)
 
-		if len(items) > 5 {
-			items = items[:5]
+		if max := ragMaxCVEs(); len(items) > max {
+			items = items[:max]
 		}
 
-		return items
+		return ragSelection{CVEs: items, Source: ragSourceFallback}
 	}
 
-	if len(result) > 5 {
-		result = result[:5]
+	// Equally relevant CVEs go by priority
+	w := loadRankWeights()
+	now := time.Now()
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return cvePriority(matches[i].cve, w, now) > cvePriority(matches[j].cve, w, now)
+	})
+
+	if max := ragMaxCVEs(); len(matches) > max {
+		matches = matches[:max]
 	}
 
-	return result
+	result := make([]CVE, 0, len(matches))
+	included := map[string]bool{}
+	matched := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		result = append(result, m.cve)
+		included[m.cve.ID] = true
+		matched[m.cve.ID] = true
+	}
+
+	if min := ragMinCVEs(); len(result) < min {
+
+		items := GetRecentCVEs()
+		rankCVEs(items)
+
+		for _, c := range items {
+			if len(result) >= min {
+				break
+			}
+			if !included[c.ID] {
+				result = append(result, c)
+				included[c.ID] = true
+			}
+		}
+	}
+
+	return ragSelection{CVEs: result, Source: ragSourceMatch, Matched: matched}
+}
+
+/* ---------------- RAG SIZE LIMITS ---------------- */
+
+const ragMaxCVEsCap = 50
+
+// ragMaxCVEs reads RAG_MAX_CVES (default 5, between 1 and 50).
+func ragMaxCVEs() int {
+
+	n := envInt("RAG_MAX_CVES", 5)
+
+	switch {
+	case n < 1:
+		return 1
+	case n > ragMaxCVEsCap:
+		return ragMaxCVEsCap
+	}
+
+	return n
+}
+
+// ragMinCVEs reads RAG_MIN_CVES (default 0, never above RAG_MAX_CVES).
+func ragMinCVEs() int {
+
+	n := envInt("RAG_MIN_CVES", 0)
+
+	if n < 0 {
+		return 0
+	}
+	if max := ragMaxCVEs(); n > max {
+		return max
+	}
+
+	return n
 }
 
 /* ---------------- HELPERS ---------------- */
 
-func parsePublished(s string) time.Time {
+// dedupeCVEs returns a copy of items with one entry per CVE ID, at the
+// position of its first occurrence, keeping the better record.
+func dedupeCVEs(items []CVE) []CVE {
+
+	out := make([]CVE, 0, len(items))
+	seen := make(map[string]int, len(items))
+
+	for _, c := range items {
+
+		if i, ok := seen[c.ID]; ok {
+			if betterCVE(c, out[i]) {
+				out[i] = c
+			}
+			continue
+		}
+
+		seen[c.ID] = len(out)
+		out = append(out, c)
+	}
+
+	return out
+}
 
-	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
-		return t
+// betterCVE prefers a scored record, then the higher CVSS, then the one
+// with more vendor/product/CWE data.
+func betterCVE(a, b CVE) bool {
+
+	if a.scored() != b.scored() {
+		return a.scored()
+	}
+	if a.CVSSScore != b.CVSSScore {
+		return a.CVSSScore > b.CVSSScore
+	}
+
+	return cveCompleteness(a) > cveCompleteness(b)
+}
+
+func cveCompleteness(c CVE) int {
+
+	n := len(c.allVendors()) + len(c.allProducts()) + len(c.CWEs)
+	if c.Description != "" {
+		n++
 	}
-	if t, err := time.Parse(time.RFC3339, s); err == nil {
-		return t
+
+	return n
+}
+
+// nvdTimeLayout is NVD's timestamp format: UTC without a zone suffix.
+const nvdTimeLayout = "2006-01-02T15:04:05.000"
+
+// parsePublished reads NVD timestamps and RFC3339 ones (cache files and
+// tests); unparseable values are the zero time.
+func parsePublished(s string) time.Time {
+
+	for _, layout := range []string{nvdTimeLayout, time.RFC3339Nano, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
 	}
+
 	return time.Time{}
 }
 
@@ -336,39 +1001,38 @@ func parsePublished(s string) time.Time {
    🔥 BUILD RAG BLOCK FROM GIVEN CVE LIST (FINAL)
    ======================================================= */
 
-   func BuildCVERagBlockFromList(items []CVE) string {
+// BuildCVERagBlockFromList is the <Rag> block of items alone, without
+// other RAG sources.
+func BuildCVERagBlockFromList(items []CVE) string {
+	return RAGPipeline{
+		Sources:      []RAGSource{CVESource{CVEs: items}},
+		MaxRAGTokens: envInt("RAG_MAX_TOKENS", 400),
+	}.Block(Event{})
+}
 
-    if len(items) == 0 {
-        return ""
-    }
+/* ---------------- RAG LINE FORMAT ---------------- */
 
-    // Sort newest first
-    sort.Slice(items, func(i, j int) bool {
-        return parsePublished(items[i].Published).
-            After(parsePublished(items[j].Published))
-    })
+func formatRagLine(c CVE) string {
 
-    // Limit to top 5
-    if len(items) > 5 {
-        items = items[:5]
-    }
+	score := "N/A"
+	if c.scored() {
+		score = fmt.Sprintf("%.1f", c.CVSSScore)
+	}
 
-    var b strings.Builder
-    b.WriteString("<Rag>\n")
+	line := fmt.Sprintf("%s - %s/%s - CVSS %s", c.ID, c.Vendor, c.Product, score)
 
-    for _, c := range items {
+	// rag_cwe adds the weakness types; off by default for token cost
+	if len(c.CWEs) > 0 && FeatureEnabled(FlagRAGCWE) {
+		line += " - " + strings.Join(c.CWEs, ", ")
+	}
 
-        score := "N/A"
-        if c.CVSSScore > 0 {
-            score = fmt.Sprintf("%.1f", c.CVSSScore)
-        }
+	if c.EPSSScore > 0 {
+		line += fmt.Sprintf(" - EPSS %.1f%% (p%.0f)", c.EPSSScore*100, c.EPSSPercentile*100)
+	}
 
-        b.WriteString(
-            fmt.Sprintf("%s - %s/%s - CVSS %s\n",
-                c.ID, c.Vendor, c.Product, score),
-        )
-    }
+	if c.KnownExploited {
+		line += " [ACTIVELY EXPLOITED]"
+	}
 
-    b.WriteString("</Rag>\n")
-    return b.String()
+	return line + "\n"
 }
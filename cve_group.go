@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+/* ======================================================
+   🔥 ADVISORY GROUPING
+   ======================================================
+
+   With RAG_GROUP_ADVISORIES=true, CVEs that look like they
+   come from one vendor advisory share a single RAG line:
+   same vendor/product, published within 48h of each other
+   and with largely overlapping descriptions.
+*/
+
+const (
+	advisoryWindow     = 48 * time.Hour
+	advisoryMinOverlap = 0.5
+)
+
+// groupRelatedCVEs merges related CVEs into one entry whose ID lists
+// every member ("CVE-A, CVE-B"), keeping the highest CVSS score. Input
+// order is preserved by the first member of each group.
+func groupRelatedCVEs(items []CVE) []CVE {
+
+	var groups [][]CVE
+
+	for _, c := range items {
+
+		placed := false
+		for i, g := range groups {
+			if sameAdvisory(g[0], c) {
+				groups[i] = append(g, c)
+				placed = true
+				break
+			}
+		}
+
+		if !placed {
+			groups = append(groups, []CVE{c})
+		}
+	}
+
+	out := make([]CVE, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, mergeCVEGroup(g))
+	}
+
+	return out
+}
+
+func sameAdvisory(a, b CVE) bool {
+
+	if a.Vendor == "" || a.Product == "" {
+		return false
+	}
+
+	if !strings.EqualFold(a.Vendor, b.Vendor) || !strings.EqualFold(a.Product, b.Product) {
+		return false
+	}
+
+	gap := parsePublished(a.Published).Sub(parsePublished(b.Published))
+	if math.Abs(float64(gap)) > float64(advisoryWindow) {
+		return false
+	}
+
+	return descriptionOverlap(a.Description, b.Description) >= advisoryMinOverlap
+}
+
+// descriptionOverlap is the Jaccard similarity of the two word sets.
+func descriptionOverlap(a, b string) float64 {
+
+	wa, wb := wordSet(a), wordSet(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+func wordSet(s string) map[string]bool {
+
+	out := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), isWordSeparator) {
+		out[w] = true
+	}
+
+	return out
+}
+
+func isWordSeparator(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+}
+
+func mergeCVEGroup(g []CVE) CVE {
+
+	if len(g) == 1 {
+		return g[0]
+	}
+
+	merged := g[0]
+	ids := []string{g[0].ID}
+
+	for _, c := range g[1:] {
+		ids = append(ids, c.ID)
+
+		if c.CVSSScore > merged.CVSSScore {
+			merged.CVSSScore = c.CVSSScore
+		}
+		merged.HasScore = merged.HasScore || c.HasScore
+
+		for _, cwe := range c.CWEs {
+			if !containsString(merged.CWEs, cwe) {
+				merged.CWEs = append(merged.CWEs, cwe)
+			}
+		}
+	}
+
+	merged.ID = strings.Join(ids, ", ")
+	return merged
+}
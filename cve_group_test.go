@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+/* ---------------- ADVISORY GROUPING ---------------- */
+
+func advisoryCVE(id, published, description string, score float64) CVE {
+	return CVE{
+		ID: id, Vendor: "cisco", Product: "ios_xe",
+		Published: published, Description: description,
+		CVSSScore: score, HasScore: true,
+	}
+}
+
+func TestGroupRelatedCVEsFromOneAdvisory(t *testing.T) {
+
+	items := []CVE{
+		advisoryCVE("CVE-2024-2001", "2024-05-01T10:00:00.000", "Multiple vulnerabilities in the web UI of Cisco IOS XE allow privilege escalation", 8.1),
+		advisoryCVE("CVE-2024-2002", "2024-05-02T09:00:00.000", "Multiple vulnerabilities in the web UI of Cisco IOS XE allow command injection", 9.8),
+		advisoryCVE("CVE-2024-2003", "2024-05-20T10:00:00.000", "Multiple vulnerabilities in the web UI of Cisco IOS XE allow privilege escalation", 7.0),
+		advisoryCVE("CVE-2024-2004", "2024-05-01T11:00:00.000", "Denial of service in the OSPF implementation", 7.5),
+	}
+
+	got := groupRelatedCVEs(items)
+
+	if len(got) != 3 {
+		t.Fatalf("got %v, want 3 entries", cveIDs(got))
+	}
+	if got[0].ID != "CVE-2024-2001, CVE-2024-2002" || got[0].CVSSScore != 9.8 {
+		t.Errorf("group = %q CVSS %v, want both IDs with the highest score", got[0].ID, got[0].CVSSScore)
+	}
+	if got[1].ID != "CVE-2024-2003" || got[2].ID != "CVE-2024-2004" {
+		t.Errorf("got %v, want the later and unrelated CVEs on their own", cveIDs(got))
+	}
+}
+
+func TestGroupingRequiresVendorAndProduct(t *testing.T) {
+
+	a := advisoryCVE("CVE-2024-2001", "2024-05-01T10:00:00.000", "same text", 8)
+	b := advisoryCVE("CVE-2024-2002", "2024-05-01T10:00:00.000", "same text", 8)
+	b.Product = "nx-os"
+
+	if got := groupRelatedCVEs([]CVE{a, b}); len(got) != 2 {
+		t.Errorf("different products grouped: %v", cveIDs(got))
+	}
+
+	a.Vendor, b.Vendor, b.Product = "", "", a.Product
+	if got := groupRelatedCVEs([]CVE{a, b}); len(got) != 2 {
+		t.Errorf("CVEs without vendor grouped: %v", cveIDs(got))
+	}
+}
+
+func TestGroupingBehindFlag(t *testing.T) {
+
+	items := []CVE{
+		advisoryCVE("CVE-2024-2001", "2024-05-01T10:00:00.000", "Web UI privilege escalation in Cisco IOS XE", 8.1),
+		advisoryCVE("CVE-2024-2002", "2024-05-01T10:00:00.000", "Web UI privilege escalation in Cisco IOS XE", 9.8),
+	}
+
+	setFlags(t, map[string]bool{FlagRAGGrouping: false})
+	if chunks := (CVESource{CVEs: items}).Retrieve(Event{}); len(chunks) != 2 {
+		t.Errorf("grouping off: %d chunks, want 2", len(chunks))
+	}
+
+	setFlags(t, map[string]bool{FlagRAGGrouping: true})
+	chunks := (CVESource{CVEs: items}).Retrieve(Event{})
+	if len(chunks) != 1 || !strings.HasPrefix(chunks[0].Text, "CVE-2024-2002, CVE-2024-2001 - ") {
+		t.Errorf("grouping on: %+v, want one line listing both", chunks)
+	}
+}
+
+func TestParsePublishedNVDFormat(t *testing.T) {
+
+	for _, s := range []string{"2024-05-01T10:00:00.000", "2024-05-01T10:00:00Z", "2024-05-01T12:00:00+02:00"} {
+		if got := parsePublished(s); got.Unix() != 1714557600 {
+			t.Errorf("%s: got %v", s, got)
+		}
+	}
+
+	if !parsePublished("yesterday").IsZero() {
+		t.Error("garbage parsed")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+/* ======================================================
+   🔥 CVE TERM INDEX
+   ======================================================
+
+   Matching an event against every CVE runs the word search
+   once per CVE and alias. The index maps each distinct
+   vendor spelling and product name to the CVEs carrying it,
+   and files every term under its leading word. An event is
+   split into words once and each word is looked up, so only
+   terms starting with a word of the event are checked and
+   only CVEs whose terms occur are scored. It is rebuilt with
+   recentCVEs and guarded by cveMutex.
+*/
+
+type cveIndex struct {
+	positions map[string][]int    // lowercase term → positions in recentCVEs, ascending
+	byWord    map[string][]string // leading word → terms starting with it
+}
+
+var recentCVEIndex *cveIndex
+
+func buildCVEIndex(items []CVE) *cveIndex {
+
+	index := &cveIndex{positions: map[string][]int{}, byWord: map[string][]string{}}
+
+	add := func(term string, i int) {
+		term = strings.ToLower(strings.ReplaceAll(term, "_", " "))
+		if len(term) < minTermLen {
+			return
+		}
+		pos, known := index.positions[term]
+		if !known {
+			word := leadingWord(term)
+			index.byWord[word] = append(index.byWord[word], term)
+		}
+		if len(pos) == 0 || pos[len(pos)-1] != i {
+			index.positions[term] = append(pos, i)
+		}
+	}
+
+	for i, c := range items {
+		for _, v := range c.allVendors() {
+			for _, s := range vendorSpellings(v) {
+				add(s, i)
+			}
+		}
+		for _, p := range c.allProducts() {
+			add(p, i)
+		}
+	}
+
+	return index
+}
+
+// leadingWord returns the run of word bytes term starts with, empty when
+// term starts with punctuation.
+func leadingWord(term string) string {
+
+	end := 0
+	for end < len(term) && isWordByte(term[end]) {
+		end++
+	}
+	return term[:end]
+}
+
+// candidates returns the positions of CVEs with a vendor or product term
+// in the lowercased text, in storage order. Every CVE cveRelevance scores
+// above zero is among them.
+func (ix *cveIndex) candidates(text string) []int {
+
+	if ix == nil {
+		return nil
+	}
+
+	seen := map[int]bool{}
+	var out []int
+
+	add := func(term string) {
+		for _, i := range ix.positions[term] {
+			if !seen[i] {
+				seen[i] = true
+				out = append(out, i)
+			}
+		}
+	}
+
+	// A term starting with a word byte can only match where a word starts,
+	// and that word is the term's leading word
+	for start := 0; start < len(text); {
+
+		if !isWordByte(text[start]) {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(text) && isWordByte(text[end]) {
+			end++
+		}
+
+		for _, term := range ix.byWord[text[start:end]] {
+			if matchesWordAt(text, term, start) {
+				add(term)
+			}
+		}
+
+		start = end
+	}
+
+	// Terms starting with punctuation have no word to look up
+	for _, term := range ix.byWord[""] {
+		if containsWord(text, term, minTermLen) {
+			add(term)
+		}
+	}
+
+	sort.Ints(out)
+	return out
+}
+
+// matchesWordAt reports whether term occurs in text at start and ends at
+// a word boundary.
+func matchesWordAt(text, term string, start int) bool {
+
+	end := start + len(term)
+	return strings.HasPrefix(text[start:], term) &&
+		(end == len(text) || !isWordByte(text[end]))
+}
+
+// candidateCVEs returns copies of the CVEs that may match text, and how
+// many CVEs are loaded in total.
+func candidateCVEs(text string) (candidates []CVE, total int) {
+
+	cveMutex.RLock()
+	defer cveMutex.RUnlock()
+
+	for _, i := range recentCVEIndex.candidates(text) {
+		candidates = append(candidates, recentCVEs[i])
+	}
+
+	return candidates, len(recentCVEs)
+}
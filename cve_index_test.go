@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+/* ---------------- CVE TERM INDEX ---------------- */
+
+// manyVendorCVEs returns n CVEs spread over vendors distinct
+// vendor/product pairs, with a few multi-word and punctuated terms.
+func manyVendorCVEs(n, vendors int) []CVE {
+
+	items := make([]CVE, 0, n)
+	for i := 0; i < n; i++ {
+		v := i % vendors
+		items = append(items, CVE{
+			ID:       fmt.Sprintf("CVE-2024-%05d", i),
+			Vendors:  []string{fmt.Sprintf("vendor%d", v)},
+			Products: []string{fmt.Sprintf("product_%d_os", v), fmt.Sprintf("(box%d)", v)},
+		})
+	}
+
+	return items
+}
+
+// linearCandidates is the scan the index replaces: every term of every
+// CVE searched in text.
+func linearCandidates(items []CVE, text string) []int {
+
+	var out []int
+
+	for i, c := range items {
+		var terms []string
+		for _, v := range c.allVendors() {
+			terms = append(terms, vendorSpellings(v)...)
+		}
+		terms = append(terms, c.allProducts()...)
+
+		for _, term := range terms {
+			if containsWord(text, term, minTermLen) {
+				out = append(out, i)
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+func TestCVEIndexMatchesLinearScan(t *testing.T) {
+
+	items := append(manyVendorCVEs(200, 40), ciscoCVEs(5)...)
+	items = append(items, sonicWallCVE)
+	index := buildCVEIndex(items)
+
+	for _, text := range []string{
+		"%link-3-updown: interface gi0/1 on cisco ios xe changed state to down",
+		"vendor7 product 7 os reboot",
+		"vendor7product 7 os",
+		"vendor12: (box3) fan failure; product 19 os",
+		"sonicwall vpn tunnel flapping",
+		"cisco systems ios-xe",
+		"no vendor here",
+		"",
+	} {
+		if got, want := index.candidates(text), linearCandidates(items, text); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: index %v, linear %v", text, got, want)
+		}
+	}
+}
+
+func BenchmarkCVECandidates(b *testing.B) {
+
+	items := manyVendorCVEs(20000, 500)
+	index := buildCVEIndex(items)
+	text := "%link-3-updown: interface gi0/1 on vendor42 product 42 os changed state to down"
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.candidates(text)
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			linearCandidates(items, text)
+		}
+	})
+}
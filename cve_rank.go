@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+/* ======================================================
+   🔥 CVE PRIORITY RANKING
+   ======================================================
+
+   RAG slots go to the CVEs that matter most, not just the
+   newest ones: CVSS, KEV and EPSS are combined with a
+   recency decay so a two-week-old 9.8 still beats a
+   brand-new 5.0.
+*/
+
+type rankWeights struct {
+	CVSS     float64
+	Recency  float64
+	KEV      float64
+	EPSS     float64
+	HalfLife time.Duration
+}
+
+// loadRankWeights reads RAG_RANK_WEIGHT_{CVSS,RECENCY,KEV,EPSS} and
+// RAG_RANK_HALF_LIFE (age at which the recency term halves).
+func loadRankWeights() rankWeights {
+
+	w := rankWeights{
+		CVSS:     envFloat("RAG_RANK_WEIGHT_CVSS", 0.5),
+		Recency:  envFloat("RAG_RANK_WEIGHT_RECENCY", 0.3),
+		KEV:      envFloat("RAG_RANK_WEIGHT_KEV", 0.15),
+		EPSS:     envFloat("RAG_RANK_WEIGHT_EPSS", 0.05),
+		HalfLife: envDuration("RAG_RANK_HALF_LIFE", 7*24*time.Hour),
+	}
+
+	if w.HalfLife <= 0 {
+		w.HalfLife = 7 * 24 * time.Hour
+	}
+
+	return w
+}
+
+// cvePriority scores c between 0 and the sum of the weights. Unscored
+// CVEs get no CVSS credit; unknown publication dates no recency credit.
+func cvePriority(c CVE, w rankWeights, now time.Time) float64 {
+
+	score := 0.0
+
+	if c.scored() {
+		score += w.CVSS * c.CVSSScore / 10
+	}
+
+	if published := parsePublished(c.Published); !published.IsZero() {
+		age := now.Sub(published)
+		if age < 0 {
+			age = 0
+		}
+		score += w.Recency * math.Exp2(-float64(age)/float64(w.HalfLife))
+	}
+
+	if c.KnownExploited {
+		score += w.KEV
+	}
+
+	score += w.EPSS * c.EPSSScore
+
+	return score
+}
+
+// rankCVEs sorts items in place, highest priority first; ties keep the
+// newest CVE first.
+func rankCVEs(items []CVE) {
+
+	w := loadRankWeights()
+	now := time.Now()
+
+	priority := make(map[string]float64, len(items))
+	for _, c := range items {
+		priority[c.ID] = cvePriority(c, w, now)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		pi, pj := priority[items[i].ID], priority[items[j].ID]
+		if pi != pj {
+			return pi > pj
+		}
+		return parsePublished(items[i].Published).
+			After(parsePublished(items[j].Published))
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// publishedAgo formats the publication date of a CVE age old.
+func publishedAgo(age time.Duration) string {
+	return time.Now().UTC().Add(-age).Format(nvdTimeLayout)
+}
+
+/* ---------------- CVE PRIORITY RANKING ---------------- */
+
+func TestCriticalOlderCVEOutranksLowNewer(t *testing.T) {
+
+	for _, key := range []string{"RAG_RANK_WEIGHT_CVSS", "RAG_RANK_WEIGHT_RECENCY", "RAG_RANK_WEIGHT_KEV", "RAG_RANK_WEIGHT_EPSS", "RAG_RANK_HALF_LIFE"} {
+		t.Setenv(key, "")
+	}
+
+	items := []CVE{
+		{ID: "CVE-2024-0050", CVSSScore: 5.0, HasScore: true, Published: publishedAgo(time.Hour)},
+		{ID: "CVE-2024-0098", CVSSScore: 9.8, HasScore: true, Published: publishedAgo(14 * 24 * time.Hour)},
+	}
+
+	rankCVEs(items)
+
+	if items[0].ID != "CVE-2024-0098" {
+		t.Errorf("ranked %v, want the 14-day-old 9.8 first", cveIDs(items))
+	}
+}
+
+func TestExploitSignalsBreakTies(t *testing.T) {
+
+	published := publishedAgo(48 * time.Hour)
+	base := func(id string) CVE { return CVE{ID: id, CVSSScore: 8.1, HasScore: true, Published: published} }
+
+	plain, kev, epss := base("CVE-2024-0001"), base("CVE-2024-0002"), base("CVE-2024-0003")
+	kev.KnownExploited = true
+	epss.EPSSScore = 0.9
+
+	items := []CVE{plain, epss, kev}
+	rankCVEs(items)
+
+	if got := strings.Join(cveIDs(items), ","); got != "CVE-2024-0002,CVE-2024-0003,CVE-2024-0001" {
+		t.Errorf("ranked %s, want KEV, then high EPSS, then the plain CVE", got)
+	}
+}
+
+func TestRagBuildersRankByPriority(t *testing.T) {
+
+	t.Setenv("RAG_MAX_CVES", "1")
+
+	useRecentCVEs(t, []CVE{
+		{ID: "CVE-2024-0050", Vendor: "cisco", CVSSScore: 5.0, HasScore: true, Published: publishedAgo(time.Hour)},
+		{ID: "CVE-2024-0098", Vendor: "cisco", CVSSScore: 9.8, HasScore: true, Published: publishedAgo(14 * 24 * time.Hour)},
+	})
+
+	for name, block := range map[string]string{
+		"generic":     BuildCVERagBlock(),
+		"event-aware": BuildCVERagBlockForEvent(Event{Message: "cisco router reload"}),
+	} {
+		if !strings.Contains(block, "CVE-2024-0098") {
+			t.Errorf("%s block does not lead with the 9.8:\n%s", name, block)
+		}
+	}
+}
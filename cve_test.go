@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeCVECache writes a cache file of the given age to a temp
+// CVE_CACHE_PATH.
+func writeCVECache(t *testing.T, age time.Duration, items []CVE) {
+
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cve_cache.json")
+	t.Setenv("CVE_CACHE_PATH", path)
+
+	raw, err := json.Marshal(cveCacheFile{Timestamp: time.Now().Add(-age), CVEs: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stubNVD points nvdCVEsURL at handler with retries off.
+func stubNVD(t *testing.T, handler http.HandlerFunc) {
+
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	prev := nvdCVEsURL
+	nvdCVEsURL = srv.URL + "/rest/json/cves/2.0"
+	t.Cleanup(func() { nvdCVEsURL = prev })
+
+	t.Setenv("NVD_MAX_RETRIES", "0")
+	t.Setenv("NVD_API_KEY", "test") // no public-rate-limit sleeps
+}
+
+/* ---------------- STRICT FRESHNESS ---------------- */
+
+func TestStrictFreshnessRefusesStaleCache(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagStrictFresh: true})
+	t.Setenv("CVE_MAX_CACHE_AGE", "24h")
+
+	writeCVECache(t, 48*time.Hour, []CVE{{ID: "CVE-2024-0001"}})
+	if err := CheckStartupCVEFreshness(); err == nil {
+		t.Error("stale cache accepted in strict mode")
+	}
+
+	writeCVECache(t, time.Hour, []CVE{{ID: "CVE-2024-0001"}})
+	if err := CheckStartupCVEFreshness(); err != nil {
+		t.Errorf("fresh cache rejected: %v", err)
+	}
+
+	t.Setenv("CVE_CACHE_PATH", filepath.Join(t.TempDir(), "missing.json"))
+	if err := CheckStartupCVEFreshness(); err == nil {
+		t.Error("missing cache accepted in strict mode")
+	}
+}
+
+func TestLenientFreshnessStartsWithStaleCache(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagStrictFresh: false})
+
+	writeCVECache(t, 48*time.Hour, nil)
+	if err := CheckStartupCVEFreshness(); err != nil {
+		t.Errorf("lenient mode refused to start: %v", err)
+	}
+}
+
+func TestStaleCacheServedOnlyWhenOptedIn(t *testing.T) {
+
+	stubNVD(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	t.Setenv("NVD_INCREMENTAL", "false")
+
+	for _, optIn := range []bool{false, true} {
+
+		useRecentCVEs(t, nil)
+		writeCVECache(t, 48*time.Hour, []CVE{{ID: "CVE-2024-0001"}})
+
+		if optIn {
+			t.Setenv("CVE_SERVE_STALE", "true")
+		}
+
+		if err := ensureRecentNetworkCVEs(context.Background()); err == nil {
+			t.Fatal("want the NVD error")
+		}
+
+		if got := len(GetRecentCVEs()); got != map[bool]int{false: 0, true: 1}[optIn] {
+			t.Errorf("CVE_SERVE_STALE=%v: %d CVEs loaded", optIn, got)
+		}
+	}
+}
+
+/* ---------------- RELEVANCE GATE ---------------- */
+
+var gateCVEs = []CVE{
+	{ID: "CVE-2024-0101", Vendor: "cisco", Product: "ios_xe", Vendors: []string{"cisco"}, Products: []string{"ios_xe"}, CVSSScore: 9.8, HasScore: true},
+	{ID: "CVE-2024-0102", Vendor: "juniper", Product: "junos", Vendors: []string{"juniper"}, Products: []string{"junos"}, CVSSScore: 7.5, HasScore: true},
+}
+
+func TestRelevanceGateKeepsStrongMatches(t *testing.T) {
+
+	useRecentCVEs(t, gateCVEs)
+	t.Setenv("RAG_MIN_RELEVANCE", "1")
+	t.Setenv("RAG_MIN_CVES", "0")
+
+	rag := findRelevantCVEs("Cisco IOS XE crashed on core-rtr-1")
+
+	if rag.Source != ragSourceMatch || len(rag.CVEs) != 1 || rag.CVEs[0].ID != "CVE-2024-0101" {
+		t.Errorf("got %s %v, want the vendor+product match only", rag.Source, cveIDs(rag.CVEs))
+	}
+}
+
+func TestRelevanceGateDropsWeakMatches(t *testing.T) {
+
+	useRecentCVEs(t, gateCVEs)
+	t.Setenv("RAG_MIN_CVES", "0")
+
+	text := "cisco switch rebooted" // vendor only: 0.5
+
+	t.Setenv("RAG_MIN_RELEVANCE", "1")
+	if rag := findRelevantCVEs(text); rag.Source != ragSourceNone || len(rag.CVEs) != 0 {
+		t.Errorf("gate on: got %s %v, want no RAG", rag.Source, cveIDs(rag.CVEs))
+	}
+
+	t.Setenv("RAG_MIN_RELEVANCE", "0")
+	if rag := findRelevantCVEs(text); rag.Source != ragSourceMatch || len(rag.CVEs) != 1 {
+		t.Errorf("gate off: got %s %v, want the weak match", rag.Source, cveIDs(rag.CVEs))
+	}
+
+	t.Setenv("RAG_MIN_RELEVANCE", "1")
+	if rag := findRelevantCVEs("disk full on host-7"); rag.Source != ragSourceNone {
+		t.Errorf("no match with the gate on: got %s, want no recency fallback", rag.Source)
+	}
+}
+
+func cveIDs(items []CVE) []string {
+
+	ids := make([]string, 0, len(items))
+	for _, c := range items {
+		ids = append(ids, c.ID)
+	}
+
+	return ids
+}
+
+/* ---------------- NETWORK VENDOR FILTER ---------------- */
+
+var sonicWallCVE = CVE{ID: "CVE-2024-0201", Vendor: "sonicwall", Vendors: []string{"sonicwall"}, CVSSScore: 9.1, HasScore: true}
+
+func TestDefaultVendorsDropSonicWall(t *testing.T) {
+
+	t.Setenv("CVE_VENDORS", "")
+	t.Setenv("CVE_MIN_CVSS", "")
+
+	items := []CVE{sonicWallCVE, {ID: "CVE-2024-0202", Vendor: "cisco", Vendors: []string{"cisco"}, CVSSScore: 8.0, HasScore: true}}
+
+	if got := cveIDs(filterNetworkCVEs(items)); len(got) != 1 || got[0] != "CVE-2024-0202" {
+		t.Errorf("got %v, want only the cisco CVE", got)
+	}
+}
+
+func TestCustomVendorsKeepSonicWall(t *testing.T) {
+
+	t.Setenv("CVE_VENDORS", "aruba, SonicWall ,zyxel")
+
+	if got := cveIDs(filterNetworkCVEs([]CVE{sonicWallCVE})); len(got) != 1 {
+		t.Errorf("got %v, want the SonicWall CVE", got)
+	}
+
+	t.Setenv("CVE_MIN_CVSS", "9.5")
+	if got := filterNetworkCVEs([]CVE{sonicWallCVE}); len(got) != 0 {
+		t.Errorf("got %v, want it dropped below CVE_MIN_CVSS", cveIDs(got))
+	}
+}
+
+func TestNVDLookbackDays(t *testing.T) {
+
+	for raw, want := range map[string]int{"": 7, "30": 30, "0": 7, "500": maxLookbackDays} {
+		t.Setenv("NVD_LOOKBACK_DAYS", raw)
+		if got := nvdLookbackDays(); got != want {
+			t.Errorf("NVD_LOOKBACK_DAYS=%q: %d, want %d", raw, got, want)
+		}
+	}
+}
+
+/* ---------------- NVD PAGINATION ---------------- */
+
+// nvdPages serves ids as pages of perPage, counting requests.
+func nvdPages(ids []string, perPage int, calls *int) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		*calls++
+
+		start := 0
+		fmt.Sscan(r.URL.Query().Get("startIndex"), &start)
+
+		var vulns []map[string]interface{}
+		for _, id := range ids[start:min(start+perPage, len(ids))] {
+			vulns = append(vulns, map[string]interface{}{"cve": map[string]string{"id": id}})
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"resultsPerPage":  perPage,
+			"startIndex":      start,
+			"totalResults":    len(ids),
+			"vulnerabilities": vulns,
+		})
+	}
+}
+
+func TestFetchNVDTwoPages(t *testing.T) {
+
+	calls := 0
+	stubNVD(t, nvdPages([]string{"CVE-2024-0001", "CVE-2024-0002", "CVE-2024-0003"}, 2, &calls))
+
+	items, err := fetchRecentCVEsFromNVD(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cveIDs(items); fmt.Sprint(got) != "[CVE-2024-0001 CVE-2024-0002 CVE-2024-0003]" || calls != 2 {
+		t.Errorf("got %v in %d requests, want all three in 2", got, calls)
+	}
+}
+
+/* ---------------- RAG CVE BOUNDS ---------------- */
+
+// ciscoCVEs returns n scored cisco/ios_xe CVEs.
+func ciscoCVEs(n int) []CVE {
+
+	items := make([]CVE, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, CVE{
+			ID:        fmt.Sprintf("CVE-2024-1%03d", i),
+			Vendor:    "cisco",
+			Product:   "ios_xe",
+			Vendors:   []string{"cisco"},
+			Products:  []string{"ios_xe"},
+			CVSSScore: 9.0 - float64(i)/10,
+			HasScore:  true,
+		})
+	}
+
+	return items
+}
+
+func TestRagBuilderHonorsMaxCVEs(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: true, FlagRAGGrouping: false})
+	useRecentCVEs(t, ciscoCVEs(8))
+	t.Setenv("RAG_MAX_CVES", "3")
+	t.Setenv("RAG_MAX_TOKENS", "0")
+
+	event := Event{Type: "crash", Message: "Cisco IOS XE crashed"}
+	rag := findRelevantCVEs(event.Message)
+
+	if len(rag.CVEs) != 3 {
+		t.Fatalf("selected %d CVEs, want RAG_MAX_CVES=3", len(rag.CVEs))
+	}
+	if lines := ragLines(buildRagBlock(event, rag.CVEs)); len(lines) != 3 {
+		t.Errorf("block has %d lines, want 3", len(lines))
+	}
+}
+
+func TestRagBuilderHonorsMinCVEs(t *testing.T) {
+
+	items := append(ciscoCVEs(1), gateCVEs[1], sonicWallCVE)
+	useRecentCVEs(t, items)
+	t.Setenv("RAG_MIN_RELEVANCE", "0")
+
+	t.Setenv("RAG_MIN_CVES", "2")
+	if rag := findRelevantCVEs("Cisco IOS XE crashed"); len(rag.CVEs) != 2 || rag.CVEs[0].ID != "CVE-2024-1000" {
+		t.Errorf("got %v, want the match topped up to 2", cveIDs(rag.CVEs))
+	}
+
+	t.Setenv("RAG_MIN_CVES", "0")
+	if rag := findRelevantCVEs("Cisco IOS XE crashed"); len(rag.CVEs) != 1 {
+		t.Errorf("got %v, want only the match", cveIDs(rag.CVEs))
+	}
+}
+
+func TestRagBoundsClamped(t *testing.T) {
+
+	for raw, want := range map[string]int{"": 5, "0": 1, "500": ragMaxCVEsCap} {
+		t.Setenv("RAG_MAX_CVES", raw)
+		if got := ragMaxCVEs(); got != want {
+			t.Errorf("RAG_MAX_CVES=%q: %d, want %d", raw, got, want)
+		}
+	}
+
+	t.Setenv("RAG_MAX_CVES", "3")
+	t.Setenv("RAG_MIN_CVES", "10")
+	if got := ragMinCVEs(); got != 3 {
+		t.Errorf("RAG_MIN_CVES above max: %d, want 3", got)
+	}
+}
+
+/* ---------------- WORD-BOUNDARY MATCHING ---------------- */
+
+var ciscoIOS = CVE{ID: "CVE-2024-0401", Vendor: "cisco", Product: "ios", Vendors: []string{"cisco"}, Products: []string{"ios"}, CVSSScore: 8.6, HasScore: true}
+
+func TestShortProductNeedsVendorAndWordBoundary(t *testing.T) {
+
+	for text, want := range map[string]float64{
+		"kiosk crashed":               0,
+		"ongoing ios upgrade":         0,   // short product without the vendor
+		"cisco kiosk crashed":         0.5, // vendor only
+		"cisco ios crashed":           1,
+		"cisco-ios: %SYS-5-RESTART":   1,
+		"ciscoios crashed":            0,
+		"anycisco ios and the ios-xr": 0,
+	} {
+		if got := cveRelevance(text, ciscoIOS); got != want {
+			t.Errorf("%q: relevance %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestKioskDoesNotMatchIOS(t *testing.T) {
+
+	useRecentCVEs(t, []CVE{ciscoIOS})
+	t.Setenv("RAG_MIN_RELEVANCE", "0")
+
+	if rag := findRelevantCVEs("kiosk crashed"); rag.Source == ragSourceMatch {
+		t.Errorf("kiosk matched %v", cveIDs(rag.CVEs))
+	}
+}
+
+/* ---------------- ATOMIC CACHE WRITES ---------------- */
+
+func TestCacheWritesNeverExposePartialJSON(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "nested", "cve_cache.json")
+	t.Setenv("CVE_CACHE_PATH", path)
+
+	saveCacheToFile(ciscoCVEs(1), time.Now())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			saveCacheToFile(ciscoCVEs(1+i%2*200), time.Now())
+		}
+	}()
+
+	// Read without cacheFileMutex, as another process would
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(raw) {
+			t.Fatalf("read %d bytes of invalid JSON", len(raw))
+		}
+	}
+
+	if left, _ := filepath.Glob(path + ".tmp-*"); len(left) != 0 {
+		t.Errorf("temp files left behind: %v", left)
+	}
+}
+
+func TestFailedCacheWriteLeavesNoTempFile(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// A non-empty directory in the way makes the rename fail
+	blocked := filepath.Join(dir, "cve_cache.json")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(blocked, []byte(`{}`), 0644); err == nil {
+		t.Fatal("rename over a non-empty directory succeeded")
+	}
+
+	if left, _ := filepath.Glob(filepath.Join(dir, "*.tmp-*")); len(left) != 0 {
+		t.Errorf("temp files left behind: %v", left)
+	}
+}
+
+/* ---------------- DUPLICATE CVEs ---------------- */
+
+func TestDedupeKeepsBetterRecord(t *testing.T) {
+
+	for name, c := range map[string]struct {
+		first, second CVE
+		want          string
+	}{
+		"higher CVSS": {
+			first:  CVE{ID: "CVE-2024-0001", Description: "v1", CVSSScore: 7.5, HasScore: true},
+			second: CVE{ID: "CVE-2024-0001", Description: "v2", CVSSScore: 9.8, HasScore: true},
+			want:   "v2",
+		},
+		"scored over unscored": {
+			first:  CVE{ID: "CVE-2024-0001", Description: "v1", CVSSScore: 5.0, HasScore: true},
+			second: CVE{ID: "CVE-2024-0001", Description: "v2", Vendor: "cisco", Product: "ios"},
+			want:   "v1",
+		},
+		"more complete at equal CVSS": {
+			first:  CVE{ID: "CVE-2024-0001", Description: "v1", CVSSScore: 8.1, HasScore: true},
+			second: CVE{ID: "CVE-2024-0001", Description: "v2", CVSSScore: 8.1, HasScore: true, Vendor: "cisco", Product: "ios_xe", CWEs: []string{"CWE-20"}},
+			want:   "v2",
+		},
+		"first kept on a full tie": {
+			first:  CVE{ID: "CVE-2024-0001", Description: "v1", CVSSScore: 8.1, HasScore: true},
+			second: CVE{ID: "CVE-2024-0001", Description: "v2", CVSSScore: 8.1, HasScore: true},
+			want:   "v1",
+		},
+	} {
+		for _, order := range [][]CVE{{c.first, c.second, ciscoIOS}, {ciscoIOS, c.first, c.second}} {
+			out := dedupeCVEs(order)
+			if len(out) != 2 {
+				t.Errorf("%s: %d CVEs left, want 2", name, len(out))
+				continue
+			}
+			for _, kept := range out {
+				if kept.ID == "CVE-2024-0001" && kept.Description != c.want {
+					t.Errorf("%s: kept %q, want %q", name, kept.Description, c.want)
+				}
+			}
+		}
+	}
+
+	// The more complete record wins whichever comes first
+	sparse := CVE{ID: "CVE-2024-0001", Description: "sparse", CVSSScore: 8.1, HasScore: true}
+	full := CVE{ID: "CVE-2024-0001", Description: "full", CVSSScore: 8.1, HasScore: true, Vendor: "cisco", Product: "ios_xe"}
+	if out := dedupeCVEs([]CVE{full, sparse}); len(out) != 1 || out[0].Description != "full" {
+		t.Errorf("dedupe = %+v, want the complete record", out)
+	}
+}
+
+/* ---------------- CONCURRENT REFRESHES ---------------- */
+
+func TestConcurrentRefreshesFetchOnce(t *testing.T) {
+
+	useRecentCVEs(t, nil)
+	t.Setenv("CVE_CACHE_PATH", filepath.Join(t.TempDir(), "cve_cache.json"))
+
+	var calls atomic.Int32
+	stubNVD(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond) // keep the fetch in flight while the others arrive
+		writeJSON(w, map[string]interface{}{
+			"resultsPerPage":  1,
+			"totalResults":    1,
+			"vulnerabilities": []map[string]interface{}{{"cve": map[string]string{"id": "CVE-2024-0001"}}},
+		})
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := EnsureRecentNetworkCVEs(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("NVD requests = %d for 10 concurrent refreshes, want 1", n)
+	}
+}
+
+/* ---------------- INCREMENTAL SYNC ---------------- */
+
+func TestIncrementalSyncMergesChanges(t *testing.T) {
+
+	t.Setenv("NVD_LOOKBACK_DAYS", "7")
+	t.Setenv("CVE_VENDORS", "cisco")
+	useRecentCVEs(t, nil)
+
+	daysAgo := func(n int) string { return time.Now().UTC().AddDate(0, 0, -n).Format(nvdTimeLayout) }
+	cisco := func(id string, score float64, published string) CVE {
+		return CVE{ID: id, Vendor: "cisco", Vendors: []string{"cisco"}, CVSSScore: score, HasScore: true, Published: published}
+	}
+
+	writeCVECache(t, time.Hour, []CVE{
+		cisco("CVE-2024-0001", 9.0, daysAgo(2)), // untouched, still in the window
+		cisco("CVE-2024-0002", 7.5, daysAgo(3)), // rescored by the update
+		cisco("CVE-2023-0003", 9.0, daysAgo(30)),
+	})
+
+	var query atomic.Value
+	stubNVD(t, func(w http.ResponseWriter, r *http.Request) {
+		query.Store(r.URL.Query())
+		fmt.Fprintf(w, `{"resultsPerPage":2,"startIndex":0,"totalResults":2,"vulnerabilities":[
+			{"cve":{"id":"CVE-2024-0002","published":%q,"metrics":{"cvssMetricV31":[{"cvssData":{"baseScore":9.8}}]},
+				"configurations":[{"nodes":[{"cpeMatch":[{"vulnerable":true,"criteria":"cpe:2.3:o:cisco:ios_xe:17.9:*:*:*:*:*:*:*"}]}]}]}},
+			{"cve":{"id":"CVE-2024-0004","published":%q,"metrics":{"cvssMetricV31":[{"cvssData":{"baseScore":8.1}}]},
+				"configurations":[{"nodes":[{"cpeMatch":[{"vulnerable":true,"criteria":"cpe:2.3:o:cisco:ios_xe:17.9:*:*:*:*:*:*:*"}]}]}]}}
+		]}`, daysAgo(3), daysAgo(0))
+	})
+
+	if err := ensureRecentNetworkCVEs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	q, _ := query.Load().(url.Values)
+	if q.Get("lastModStartDate") == "" || q.Get("pubStartDate") != "" {
+		t.Errorf("NVD query %v, want lastModStartDate only", q)
+	}
+
+	got := map[string]float64{}
+	for _, c := range GetRecentCVEs() {
+		got[c.ID] = c.CVSSScore
+	}
+
+	want := map[string]float64{"CVE-2024-0001": 9.0, "CVE-2024-0002": 9.8, "CVE-2024-0004": 8.1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged CVEs = %v, want %v", got, want)
+	}
+
+	cache, err := loadCacheFromFile()
+	if err != nil || len(cache.CVEs) != len(want) {
+		t.Errorf("cache file not rewritten with the merge: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------------- RAW OUTPUT DEBUGGING ---------------- */
+
+const maxRawOutputLen = 4096
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`(?i)(api[_-]?key|apikey|token|secret|password)(["']?\s*[:=]\s*["']?)[^\s"',}]+`),
+}
+
+// wantsRawOutput reports whether the caller asked for ?debug_raw=true and
+// the debug_raw flag (DEBUG_RAW_ENABLED) permits it. Off by default.
+func wantsRawOutput(c *gin.Context) bool {
+	return c.Query("debug_raw") == "true" && FeatureEnabled(FlagDebugRawResp)
+}
+
+// applyRawOutputPolicy keeps the redacted raw output only when requested
+// and permitted.
+func applyRawOutputPolicy(c *gin.Context, result *UnifiedResponse) {
+	applyRawOutput(result, wantsRawOutput(c))
+}
+
+func applyRawOutput(result *UnifiedResponse, keep bool) {
+	if keep {
+		result.RawOutput = redactRawOutput(result.RawOutput)
+	} else {
+		result.RawOutput = ""
+	}
+}
+
+// redactRawOutput masks credentials and caps the size of the model output.
+func redactRawOutput(raw string) string {
+
+	for _, key := range getWatsonConfig().APIKeys {
+		raw = strings.ReplaceAll(raw, key, "[REDACTED]")
+	}
+
+	raw = secretPatterns[0].ReplaceAllString(raw, "Bearer [REDACTED]")
+	raw = secretPatterns[1].ReplaceAllString(raw, "${1}${2}[REDACTED]")
+
+	if len(raw) > maxRawOutputLen {
+		raw = raw[:maxRawOutputLen] + "…[truncated]"
+	}
+
+	return raw
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+/* ---------------- RAW OUTPUT ---------------- */
+
+func TestRawOutputOnlyWhenRequestedAndPermitted(t *testing.T) {
+
+	stubWatsonx(t, generationReply(`{"severity":"low","explanation":"x","recommended_action":"y"} token=abc123`))
+
+	for _, tc := range []struct {
+		target  string
+		enabled bool
+		want    bool
+	}{
+		{"/events", true, false},
+		{"/events?debug_raw=true", false, false},
+		{"/events?debug_raw=true", true, true},
+	} {
+		setFlags(t, map[string]bool{FlagRAG: false, FlagDebugRawResp: tc.enabled})
+		PurgeResultCache()
+
+		w := postEventTo(t, tc.target, `{"type":"link_down","message":"Gi0/1 down"}`)
+
+		var got UnifiedResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if (got.RawOutput != "") != tc.want {
+			t.Errorf("%s with debug_raw=%v: raw output %q", tc.target, tc.enabled, got.RawOutput)
+		}
+		if strings.Contains(got.RawOutput, "abc123") {
+			t.Errorf("secret not redacted: %q", got.RawOutput)
+		}
+	}
+}
+
+func TestRedactRawOutput(t *testing.T) {
+
+	useWatsonConfig(t, testWatsonConfig("http://unused"))
+
+	got := redactRawOutput(`key test-key, Authorization: Bearer eyJabc.def, "password": "hunter2"` + strings.Repeat("x", maxRawOutputLen))
+
+	for _, secret := range []string{"test-key", "eyJabc", "hunter2"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("%q not redacted", secret)
+		}
+	}
+	if !strings.HasSuffix(got, "…[truncated]") {
+		t.Error("raw output not capped")
+	}
+}
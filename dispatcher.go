@@ -1,22 +1,184 @@
 package main
 
-func DispatchEvent(event Event) UnifiedResponse {
+import (
+	"context"
+	"strings"
+	"time"
 
-    Logger.Println("Dispatching event")
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
 
-    relevantCVEs := FindRelevantCVEs(event.Message)
+// analyzeFunc analyzes event with ragData as the prompt's <Rag> block.
+type analyzeFunc func(ctx context.Context, event Event, ragData string) (UnifiedResponse, error)
 
-    response, err := CallWatsonAI(event, relevantCVEs)
-    if err != nil {
-        Logger.Printf("AI processing failed: %v", err)
+// DispatchEvent analyzes the event; cancelling ctx (e.g. the client
+// disconnecting) aborts the in-flight Watson call. On failure the
+// response is the "unknown" fallback and err says why.
+func DispatchEvent(ctx context.Context, event Event) (UnifiedResponse, error) {
+	return dispatch(ctx, event, analyzeWithProvider)
+}
 
-        return UnifiedResponse{
-            Severity:          "unknown",
-            Explanation:       err.Error(),
-            RecommendedAction: "Check logs",
-        }
-    }
+// DispatchEventStream is DispatchEvent with generated text passed to
+// onChunk as it streams in. Only Watsonx streams; other providers send
+// no chunks, just the final result.
+func DispatchEventStream(ctx context.Context, event Event, onChunk func(string)) UnifiedResponse {
 
-    Logger.Println("AI processing successful")
-    return response
-}
\ No newline at end of file
+	if activeProvider().Name() != providerWatsonx {
+		response, _ := dispatch(ctx, event, analyzeWithProvider)
+		return response
+	}
+
+	response, _ := dispatch(ctx, event, func(ctx context.Context, event Event, ragData string) (UnifiedResponse, error) {
+		return CallWatsonAIStream(ctx, event, ragData, onChunk)
+	})
+	return response
+}
+
+// dispatch always returns a usable response; on failure it is the
+// "unknown" fallback and err says why.
+func dispatch(ctx context.Context, event Event, analyze analyzeFunc) (UnifiedResponse, error) {
+	return dispatchWithCVEs(ctx, event, selectCVEs(ctx, event), analyze)
+}
+
+// selectCVEs picks the RAG CVEs for one event.
+func selectCVEs(ctx context.Context, event Event) ragSelection {
+
+	if event.Mode == ModeClassify || !FeatureEnabled(FlagRAG) {
+		return ragSelection{Source: ragSourceNone}
+	}
+
+	defer observeSince(cveLookupSeconds, time.Now())
+
+	ctx, span := startSpan(ctx, "cve.select")
+	defer span.End()
+
+	rag := findRelevantCVEs(event.Message)
+
+	if FeatureEnabled(FlagEPSS) {
+		enrichEPSS(ctx, rag.CVEs)
+	}
+
+	span.SetAttributes(
+		attribute.Int("cve.count", len(rag.CVEs)),
+		attribute.String("cve.source", rag.Source),
+	)
+
+	return rag
+}
+
+// dispatchWithCVEs analyzes the event against an already selected CVE
+// list (used directly when a batch shares one RAG context).
+func dispatchWithCVEs(ctx context.Context, event Event, rag ragSelection, analyze analyzeFunc) (UnifiedResponse, error) {
+
+	eventsProcessed.Add(1)
+
+	if event.Mode == ModeClassify {
+		rag = ragSelection{Source: ragSourceNone}
+	}
+	relevantCVEs := rag.CVEs
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.String("event.type", event.Type),
+		attribute.String("event.mode", event.Mode),
+		attribute.Int("cve.count", len(relevantCVEs)),
+	)
+
+	LogFields("Dispatching event",
+		"type", event.Type,
+		"message", event.Message,
+		"cve_count", len(relevantCVEs),
+	)
+
+	start := time.Now()
+
+	// CVEs plus any other configured RAG sources, ranked together and
+	// trimmed to the context window. From here on only the CVEs that
+	// made it into the block count.
+	ragData := fitRagBlock(getWatsonConfig().forEvent(event), event, buildRagBlock(event, relevantCVEs))
+	rag = rag.rendered(ragData)
+	relevantCVEs = rag.CVEs
+
+	response, err := analyze(ctx, event, ragData)
+	if err != nil {
+		LogFields("AI processing failed",
+			"type", event.Type,
+			"status", failureReason(err),
+			"error", err,
+		)
+
+		fallback := UnifiedResponse{
+			Severity:          "unknown",
+			Explanation:       err.Error(),
+			RecommendedAction: "Check logs",
+		}
+
+		// Best-effort triage instead of "unknown" when a rule matches
+		if FeatureEnabled(FlagRuleFallback) {
+			if triaged, ok := classifyByRules(event); ok {
+				fallback = triaged
+			}
+		}
+
+		fallback.SeverityLevel = severityLevel(fallback.Severity)
+		fallback.Fingerprint = eventFingerprint(event)
+		fallback.AnalyzedAt = analyzedAt()
+		recordResult(event, fallback, err)
+		recordRecent(event, fallback, time.Since(start), err)
+
+		return fallback, err
+	}
+
+	// Remediate mode keeps the externally supplied severity; otherwise
+	// it was only a hint and we record whether the model agreed
+	if event.Mode == ModeRemediate {
+		response.Severity = normalizeSeverity(event.Severity)
+	} else if hint := strings.TrimSpace(event.Severity); hint != "" {
+		agreed := normalizeSeverity(hint) == response.Severity
+		response.SeverityHint = hint
+		response.HintAgreed = &agreed
+	}
+
+	// The hint is compared with the model's verdict, before criticality
+	if event.Mode != ModeRemediate {
+		checkSeverityPlausibility(event, &response)
+		escalateForExploits(&response, rag.matched())
+
+		if adjusted := applyAssetCriticality(response.Severity, event.AssetCriticality); adjusted != response.Severity {
+			if response.ModelSeverity == "" {
+				response.ModelSeverity = response.Severity
+			}
+			response.Severity = adjusted
+		}
+	}
+
+	applyReviewPolicy(&response)
+
+	response.SeverityLevel = severityLevel(response.Severity)
+	response.Fingerprint = eventFingerprint(event)
+	response.AnalyzedAt = analyzedAt()
+
+	if ragContextEnabled() {
+		response.RAGContext = newRAGContext(rag)
+	}
+
+	span.SetAttributes(attribute.String("event.severity", response.Severity))
+
+	LogFields("AI processing successful",
+		"type", event.Type,
+		"severity", response.Severity,
+	)
+
+	// With ALERT_SYNC the receiver's id is part of the result
+	response.AlertID = notifyAlert(ctx, event, response, relevantCVEs)
+
+	recordResult(event, response, nil)
+	recordRecent(event, response, time.Since(start), nil)
+
+	return response, nil
+}
+
+func analyzedAt() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
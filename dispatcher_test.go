@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// replyWith is an analyzeFunc answering resp without a model call.
+func replyWith(resp UnifiedResponse) analyzeFunc {
+	return func(ctx context.Context, event Event, ragData string) (UnifiedResponse, error) {
+		return resp, nil
+	}
+}
+
+/* ---------------- ANALYZED AT ---------------- */
+
+func TestAnalyzedAtSerialization(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	received := time.Now().UTC().Truncate(time.Second)
+
+	got, err := dispatch(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"},
+		func(ctx context.Context, event Event, ragData string) (UnifiedResponse, error) {
+			time.Sleep(1100 * time.Millisecond) // analysis outlasts the receipt second
+			return UnifiedResponse{Severity: "low"}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(got)
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	value, _ := decoded["analyzed_at"].(string)
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("analyzed_at %q is not RFC3339", value)
+	}
+	if !at.After(received) {
+		t.Errorf("analyzed_at %v is not after receipt %v", at, received)
+	}
+}
+
+/* ---------------- REMEDIATE MODE ---------------- */
+
+func TestRemediateResponseKeepsNormalizedSeverity(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	got, err := dispatch(context.Background(),
+		Event{Type: "link_down", Message: "Gi0/1 down", Mode: ModeRemediate, Severity: " Critical ", AssetCriticality: criticalityLow},
+		replyWith(UnifiedResponse{
+			RecommendedAction: "Replace the optic",
+			RemediationSteps:  []string{"Check light levels", "Swap the SFP"},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Severity != "critical" || got.SeverityLevel != severityLevel("critical") {
+		t.Errorf("severity %q level %d, want the supplied severity normalized", got.Severity, got.SeverityLevel)
+	}
+	if got.SeverityHint != "" || got.HintAgreed != nil {
+		t.Error("supplied severity treated as a hint")
+	}
+	if got.RecommendedAction != "Replace the optic" || len(got.RemediationSteps) != 2 {
+		t.Errorf("remediation lost: %+v", got)
+	}
+}
+
+func TestRemediateAnswerParses(t *testing.T) {
+
+	got, ok := parseResponse(`{"recommended_action":"Replace the optic","remediation_steps":["a","b"]}`)
+	if !ok || got.Severity != "" || len(got.RemediationSteps) != 2 {
+		t.Errorf("got %+v (ok=%v), want steps and no severity", got, ok)
+	}
+}
+
+/* ---------------- SEVERITY HINT ---------------- */
+
+func TestSeverityHintAgreement(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	for hint, agreed := range map[string]bool{"HIGH": true, "major": true, "low": false} {
+
+		got, err := dispatch(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down", Severity: hint},
+			replyWith(UnifiedResponse{Severity: "high", Confidence: 90}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.SeverityHint != hint || got.HintAgreed == nil || *got.HintAgreed != agreed {
+			t.Errorf("hint %q: recorded %q agreed=%v, want agreed=%v", hint, got.SeverityHint, got.HintAgreed, agreed)
+		}
+	}
+
+	got, _ := dispatch(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, replyWith(UnifiedResponse{Severity: "high"}))
+	if got.SeverityHint != "" || got.HintAgreed != nil {
+		t.Error("agreement recorded without a hint")
+	}
+}
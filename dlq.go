@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+/* ======================================================
+   🔥 DEAD-LETTER FILE
+   ======================================================
+
+   Alerts the webhook still rejects after ALERT_MAX_RETRIES
+   are appended as JSON lines to DLQ_PATH instead of being
+   lost. Once the receiver is back, POST /admin/replay
+   re-sends them and keeps only those that fail again.
+   Without DLQ_PATH they are dropped, as before; either way
+   aicore_alert_deliveries_total counts the outcome.
+*/
+
+type deadLetter struct {
+	DeadLetteredAt string          `json:"dead_lettered_at"`
+	URL            string          `json:"url"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Error          string          `json:"error"`
+}
+
+var alertDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "aicore_alert_deliveries_total",
+	Help: "Alert webhook deliveries by outcome (sent, dead_lettered, dropped).",
+}, []string{"result"})
+
+// dlqMu serializes access to the DLQ_PATH file.
+var dlqMu sync.Mutex
+
+// deadLetterAlert keeps an undeliverable alert in DLQ_PATH, or drops it
+// when unset or unwritable.
+func deadLetterAlert(url, eventType string, payload []byte, sendErr error) {
+
+	path := envString("DLQ_PATH", "")
+	if path == "" {
+		alertDeliveries.WithLabelValues("dropped").Inc()
+		LogFields("🗑️ Alert dropped", "type", eventType, "error", sendErr)
+		return
+	}
+
+	rec := deadLetter{
+		DeadLetteredAt: analyzedAt(),
+		URL:            url,
+		EventType:      eventType,
+		Payload:        payload,
+		Error:          sendErr.Error(),
+	}
+
+	if err := appendDeadLetter(path, rec); err != nil {
+		alertDeliveries.WithLabelValues("dropped").Inc()
+		LogFields("🗑️ Alert dropped — dead-letter write failed", "type", eventType, "path", path, "error", err)
+		return
+	}
+
+	alertDeliveries.WithLabelValues("dead_lettered").Inc()
+	LogFields("📮 Alert dead-lettered", "type", eventType, "path", path, "error", sendErr)
+}
+
+func appendDeadLetter(path string, rec deadLetter) error {
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	return appendDeadLetterLines(path, [][]byte{line})
+}
+
+// appendDeadLetterLines appends lines (without newlines) to path.
+func appendDeadLetterLines(path string, lines [][]byte) error {
+
+	buf := joinDeadLetterLines(lines)
+
+	dlqMu.Lock()
+	defer dlqMu.Unlock()
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// joinDeadLetterLines terminates each line with a newline.
+func joinDeadLetterLines(lines [][]byte) []byte {
+
+	var buf []byte
+	for _, line := range lines {
+		buf = append(append(buf, line...), '\n')
+	}
+
+	return buf
+}
+
+/* ---------------- REPLAY ---------------- */
+
+// dlqReplayMu allows one replay at a time.
+var dlqReplayMu sync.Mutex
+
+var errReplayRunning = errors.New("a dead-letter replay is already running")
+
+// replayDeadLetters re-posts every dead-lettered alert once (with the
+// usual retries). Failures, and lines that cannot be parsed, go back to
+// path. The file is moved aside first so alerts failing meanwhile are
+// appended to a fresh one; a pending file left by a crashed replay is
+// replayed before the current file is touched. A replay already running
+// fails the call with errReplayRunning.
+func replayDeadLetters(ctx context.Context, path string) (succeeded, failed int, err error) {
+
+	if !dlqReplayMu.TryLock() {
+		return 0, 0, errReplayRunning
+	}
+	defer dlqReplayMu.Unlock()
+
+	pending := path + ".replaying"
+
+	dlqMu.Lock()
+	_, statErr := os.Stat(pending)
+	if errors.Is(statErr, fs.ErrNotExist) {
+		statErr = os.Rename(path, pending)
+	}
+	dlqMu.Unlock()
+
+	if errors.Is(statErr, fs.ErrNotExist) {
+		return 0, 0, nil // nothing dead-lettered
+	}
+	if statErr != nil {
+		return 0, 0, statErr
+	}
+
+	raw, err := os.ReadFile(pending)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var keep [][]byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, len(raw)+1)
+
+	for scanner.Scan() {
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec deadLetter
+		if err := json.Unmarshal(line, &rec); err != nil || rec.URL == "" {
+			failed++
+			keep = append(keep, append([]byte(nil), line...))
+			continue
+		}
+
+		if _, err := postAlert(ctx, rec.URL, rec.Payload); err != nil {
+			failed++
+			rec.Error = err.Error()
+			updated, _ := json.Marshal(rec)
+			keep = append(keep, updated)
+			continue
+		}
+
+		succeeded++
+		alertDeliveries.WithLabelValues("sent").Inc()
+	}
+
+	if len(keep) > 0 {
+		if err := appendDeadLetterLines(path, keep); err != nil {
+			// Leave the failures pending for the next replay rather than
+			// lose them, without the alerts that were just delivered
+			if rerr := writeFileAtomic(pending, joinDeadLetterLines(keep), 0644); rerr != nil {
+				LogFields("⚠️ Pending dead letters not narrowed — delivered alerts will be re-sent",
+					"path", pending, "error", rerr)
+			}
+			return succeeded, failed, err
+		}
+	}
+
+	LogFields("📮 Dead-lettered alerts replayed", "succeeded", succeeded, "failed", failed)
+
+	return succeeded, failed, os.Remove(pending)
+}
+
+// handleAlertReplay re-sends the dead-lettered alerts of DLQ_PATH.
+func handleAlertReplay(c *gin.Context) {
+
+	path := envString("DLQ_PATH", "")
+	if path == "" {
+		respondError(c, http.StatusConflict, errDeadLetterDisabled, "dead-lettering disabled (DLQ_PATH not set)")
+		return
+	}
+
+	succeeded, failed, err := replayDeadLetters(c.Request.Context(), path)
+
+	switch {
+	case errors.Is(err, errReplayRunning):
+		respondError(c, http.StatusConflict, errReplayInProgress, err.Error())
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, errReplayFailed,
+			fmt.Sprintf("%v (%d succeeded, %d failed)", err, succeeded, failed))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"succeeded": succeeded,
+		"failed":    failed,
+	})
+}
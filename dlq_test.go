@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// readDeadLetters returns the records in the DLQ file at path.
+func readDeadLetters(t *testing.T, path string) []deadLetter {
+
+	t.Helper()
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []deadLetter
+	for _, line := range bytes.Split(bytes.TrimSpace(raw), []byte("\n")) {
+		var rec deadLetter
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("DLQ line %q: %v", line, err)
+		}
+		out = append(out, rec)
+	}
+
+	return out
+}
+
+/* ---------------- ALERT DEAD-LETTERING ---------------- */
+
+func TestAlertDeliveredAfterTwo500s(t *testing.T) {
+
+	var calls atomic.Int32
+	stubAlertReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]string{"event_id": "evt-1"})
+	})
+	t.Setenv("ALERT_SYNC", "true")
+	t.Setenv("ALERT_MAX_RETRIES", "3")
+	dlq := filepath.Join(t.TempDir(), "dlq.jsonl")
+	t.Setenv("DLQ_PATH", dlq)
+
+	sent := sampleCount(t, "aicore_alert_deliveries_total", map[string]string{"result": "sent"})
+
+	if got := notifyAlert(context.Background(), alertEvent, UnifiedResponse{Severity: "critical", Fingerprint: "retry-fp"}, nil); got != "evt-1" {
+		t.Errorf("alert id = %q, want evt-1", got)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d webhook calls, want 3", n)
+	}
+	if recs := readDeadLetters(t, dlq); len(recs) != 0 {
+		t.Errorf("delivered alert dead-lettered: %+v", recs)
+	}
+	if d := sampleCount(t, "aicore_alert_deliveries_total", map[string]string{"result": "sent"}) - sent; d != 1 {
+		t.Errorf("sent deliveries +%v, want +1", d)
+	}
+}
+
+func TestUndeliverableAlertDeadLettered(t *testing.T) {
+
+	var calls atomic.Int32
+	srv := stubAlertReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	t.Setenv("ALERT_SYNC", "true")
+	t.Setenv("ALERT_MAX_RETRIES", "2")
+	dlq := filepath.Join(t.TempDir(), "dlq.jsonl")
+	t.Setenv("DLQ_PATH", dlq)
+
+	deadLettered := sampleCount(t, "aicore_alert_deliveries_total", map[string]string{"result": "dead_lettered"})
+
+	if got := notifyAlert(context.Background(), alertEvent, UnifiedResponse{Severity: "critical", Fingerprint: "dlq-fp"}, nil); got != "" {
+		t.Errorf("alert id = %q for a failed delivery", got)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d webhook calls, want 3", n)
+	}
+
+	recs := readDeadLetters(t, dlq)
+	if len(recs) != 1 {
+		t.Fatalf("%d DLQ entries, want 1", len(recs))
+	}
+	if rec := recs[0]; rec.URL != srv.URL || rec.EventType != alertEvent.Type || len(rec.Payload) == 0 || rec.Error == "" {
+		t.Errorf("DLQ entry %+v", rec)
+	}
+	if d := sampleCount(t, "aicore_alert_deliveries_total", map[string]string{"result": "dead_lettered"}) - deadLettered; d != 1 {
+		t.Errorf("dead-lettered deliveries +%v, want +1", d)
+	}
+}
+
+/* ---------------- DEAD-LETTER REPLAY ---------------- */
+
+// deadLetterReceivers returns the URL of a receiver that accepts alerts,
+// counting them in delivered, and of one that always fails.
+func deadLetterReceivers(t *testing.T, delivered *atomic.Int32) (ok, failing string) {
+
+	t.Helper()
+
+	accept := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered.Add(1)
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(accept.Close)
+
+	reject := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(reject.Close)
+
+	t.Setenv("ALERT_MAX_RETRIES", "0")
+
+	return accept.URL, reject.URL
+}
+
+func TestReplayRemovesDeliveredAlerts(t *testing.T) {
+
+	var delivered atomic.Int32
+	ok, failing := deadLetterReceivers(t, &delivered)
+
+	dlq := filepath.Join(t.TempDir(), "dlq.jsonl")
+	t.Setenv("DLQ_PATH", dlq)
+	for _, rec := range []deadLetter{
+		{URL: ok, EventType: "bgp_down", Payload: json.RawMessage(`{"text":"a"}`), Error: "503"},
+		{URL: failing, EventType: "link_down", Payload: json.RawMessage(`{"text":"b"}`), Error: "503"},
+	} {
+		if err := appendDeadLetter(dlq, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := adminRouter(t)
+	if w := adminPost(router, "/admin/replay", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("replay without token: %d", w.Code)
+	}
+
+	w := adminPost(router, "/admin/replay", "secret", "")
+	if w.Code != http.StatusOK || !containsAll(w.Body.String(), `"succeeded":1`, `"failed":1`) {
+		t.Fatalf("replay: %d %s", w.Code, w.Body)
+	}
+	if n := delivered.Load(); n != 1 {
+		t.Errorf("%d alerts delivered, want 1", n)
+	}
+
+	recs := readDeadLetters(t, dlq)
+	if len(recs) != 1 || recs[0].URL != failing {
+		t.Errorf("DLQ after replay: %+v, want only the failing alert", recs)
+	}
+	if _, err := os.Stat(dlq + ".replaying"); !os.IsNotExist(err) {
+		t.Errorf("pending file left behind: %v", err)
+	}
+}
+
+func TestReplayWriteBackFailureKeepsOnlyFailures(t *testing.T) {
+
+	var delivered atomic.Int32
+	ok, failing := deadLetterReceivers(t, &delivered)
+
+	// A pending file left by a crashed replay, and a DLQ path that
+	// cannot be written
+	dlq := filepath.Join(t.TempDir(), "dlq.jsonl")
+	pending := dlq + ".replaying"
+	for _, url := range []string{ok, failing} {
+		if err := appendDeadLetter(pending, deadLetter{URL: url, EventType: "bgp_down", Payload: json.RawMessage(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(dlq, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	succeeded, failed, err := replayDeadLetters(context.Background(), dlq)
+	if err == nil || succeeded != 1 || failed != 1 {
+		t.Fatalf("replay = %d, %d, %v; want 1, 1 and the write-back error", succeeded, failed, err)
+	}
+
+	recs := readDeadLetters(t, pending)
+	if len(recs) != 1 || recs[0].URL != failing {
+		t.Errorf("pending after failed write-back: %+v, want only the failing alert", recs)
+	}
+
+	// The next replay must not deliver the first alert again
+	replayDeadLetters(context.Background(), dlq)
+	if n := delivered.Load(); n != 1 {
+		t.Errorf("delivered alert re-sent: %d deliveries", n)
+	}
+}
+
+func TestReplayErrorsUseErrorResponse(t *testing.T) {
+
+	router := adminRouter(t)
+
+	decode := func(w *httptest.ResponseRecorder) ErrorResponse {
+		var body ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("body %s: %v", w.Body, err)
+		}
+		return body
+	}
+
+	t.Setenv("DLQ_PATH", "")
+	w := adminPost(router, "/admin/replay", "secret", "")
+	if w.Code != http.StatusConflict || decode(w).Code != errDeadLetterDisabled {
+		t.Errorf("replay without DLQ_PATH: %d %s", w.Code, w.Body)
+	}
+
+	t.Setenv("DLQ_PATH", filepath.Join(t.TempDir(), "dlq.jsonl"))
+
+	dlqReplayMu.Lock()
+	w = adminPost(router, "/admin/replay", "secret", "")
+	dlqReplayMu.Unlock()
+	if w.Code != http.StatusConflict || decode(w).Code != errReplayInProgress {
+		t.Errorf("replay while one is running: %d %s", w.Code, w.Body)
+	}
+
+	// A DLQ path under a regular file cannot be moved aside
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DLQ_PATH", filepath.Join(blocker, "dlq.jsonl"))
+
+	w = adminPost(router, "/admin/replay", "secret", "")
+	if w.Code != http.StatusInternalServerError || decode(w).Code != errReplayFailed {
+		t.Errorf("failed replay: %d %s", w.Code, w.Body)
+	}
+}
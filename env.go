@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------------- ENV HELPERS ---------------- */
+
+func envString(key, def string) string {
+
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+
+	return def
+}
+
+func envInt(key string, def int) int {
+
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return def
+	}
+
+	return v
+}
+
+func envFloat(key string, def float64) float64 {
+
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return def
+	}
+
+	return v
+}
+
+func envBool(key string, def bool) bool {
+
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return def
+	}
+
+	return v
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+
+	v, err := time.ParseDuration(raw)
+	if err != nil || v <= 0 {
+		return def
+	}
+
+	return v
+}
+
+// envTimeout is envDuration for timeouts where 0 means "no timeout".
+func envTimeout(key string, def time.Duration) time.Duration {
+
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return def
+	}
+
+	v, err := time.ParseDuration(raw)
+	if err != nil || v < 0 {
+		return def
+	}
+
+	return v
+}
+
+// envMap parses "from=to,from2=to2" into a lowercase-keyed map.
+func envMap(key string) map[string]string {
+
+	out := map[string]string{}
+
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		from, to, ok := strings.Cut(pair, "=")
+		from = strings.ToLower(strings.TrimSpace(from))
+		to = strings.TrimSpace(to)
+
+		if ok && from != "" && to != "" {
+			out[from] = to
+		}
+	}
+
+	return out
+}
+
+// envList splits a comma-separated value, trimming and dropping empties.
+func envList(key string, def []string) []string {
+
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+
+	if len(out) == 0 {
+		return def
+	}
+
+	return out
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ======================================================
+   🔥 FIRST EPSS EXPLOIT-PROBABILITY SCORES
+   ====================================================== */
+
+var epssAPIURL = "https://api.first.org/data/v1/epss"
+
+const (
+	epssCacheTTL = 24 * time.Hour
+	epssTimeout  = 5 * time.Second
+)
+
+type epssEntry struct {
+	score      float64
+	percentile float64
+	fetched    time.Time
+}
+
+var (
+	epssCache = map[string]epssEntry{}
+	epssMutex sync.Mutex
+)
+
+// enrichEPSS fills EPSSScore/EPSSPercentile for the given CVEs, looking
+// up all uncached IDs in one request. If FIRST is unreachable the scores
+// are simply left empty.
+func enrichEPSS(ctx context.Context, items []CVE) {
+
+	if len(items) == 0 {
+		return
+	}
+
+	var missing []string
+
+	epssMutex.Lock()
+	for _, c := range items {
+		if e, ok := epssCache[c.ID]; !ok || time.Since(e.fetched) > epssCacheTTL {
+			missing = append(missing, c.ID)
+		}
+	}
+	epssMutex.Unlock()
+
+	if len(missing) > 0 {
+		scores, err := fetchEPSS(ctx, missing)
+		if err != nil {
+			Logger.Printf("⚠️ EPSS lookup failed: %v", err)
+		}
+
+		now := time.Now()
+
+		epssMutex.Lock()
+		for id, e := range scores {
+			e.fetched = now
+			epssCache[id] = e
+		}
+		epssMutex.Unlock()
+	}
+
+	applyCachedEPSS(items)
+}
+
+// applyCachedEPSS fills in the scores already cached, without a lookup.
+func applyCachedEPSS(items []CVE) {
+
+	epssMutex.Lock()
+	defer epssMutex.Unlock()
+
+	for i := range items {
+		if e, ok := epssCache[items[i].ID]; ok {
+			items[i].EPSSScore = e.score
+			items[i].EPSSPercentile = e.percentile
+		}
+	}
+}
+
+func fetchEPSS(ctx context.Context, ids []string) (map[string]epssEntry, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, epssTimeout)
+	defer cancel()
+
+	endpoint := epssAPIURL + "?cve=" + url.QueryEscape(strings.Join(ids, ","))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "ai-core/1.0")
+
+	resp, err := newHTTPClient(0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("EPSS request failed: %s", resp.Status)
+	}
+
+	// FIRST returns the numbers as strings
+	var body struct {
+		Data []struct {
+			CVE        string `json:"cve"`
+			EPSS       string `json:"epss"`
+			Percentile string `json:"percentile"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]epssEntry, len(body.Data))
+	for _, d := range body.Data {
+		score, _ := strconv.ParseFloat(d.EPSS, 64)
+		pct, _ := strconv.ParseFloat(d.Percentile, 64)
+		out[d.CVE] = epssEntry{score: score, percentile: pct}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ======================================================
+   🔥 ERROR RESPONSES
+   ======================================================
+
+   Every event endpoint failure has the same JSON shape. When
+   the analysis itself failed, the "unknown" fallback result
+   is returned under degraded_response with a 503.
+*/
+
+const (
+	errInvalidRequest = "invalid_request"
+	errInvalidEvent   = "invalid_event"
+	errBatchTooLarge  = "batch_too_large"
+	errAnalysisFailed = "analysis_failed"
+
+	errIdempotencyConflict = "idempotency_conflict"
+
+	errModelsUnavailable = "models_unavailable"
+
+	errDeadLetterDisabled = "dead_letter_disabled"
+	errReplayInProgress   = "replay_in_progress"
+	errReplayFailed       = "replay_failed"
+)
+
+type ErrorResponse struct {
+	Code             string           `json:"code"`
+	Message          string           `json:"message"`
+	Fallback         bool             `json:"fallback"`
+	DegradedResponse *UnifiedResponse `json:"degraded_response,omitempty"`
+}
+
+// respondError aborts the request with an ErrorResponse and no fallback.
+func respondError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
+
+// respondDegraded answers 503 with the fallback analysis attached.
+func respondDegraded(c *gin.Context, err error, fallback UnifiedResponse) {
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorResponse{
+		Code:             errAnalysisFailed,
+		Message:          err.Error(),
+		Fallback:         true,
+		DegradedResponse: &fallback,
+	})
+}
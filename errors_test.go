@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+/* ---------------- ERROR RESPONSES ---------------- */
+
+func TestDegradedErrorResponseShape(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false, FlagRuleFallback: false})
+
+	tests := map[string]func(t *testing.T){
+		"watson not configured": func(t *testing.T) {
+			useWatsonConfig(t, WatsonConfig{})
+		},
+		"analysis failed": func(t *testing.T) {
+			_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "boom", http.StatusInternalServerError)
+			})
+			cfg.MaxRetries = 0
+			useWatsonConfig(t, cfg)
+		},
+	}
+
+	for name, setup := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			setup(t)
+
+			w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`)
+			if w.Code != http.StatusServiceUnavailable {
+				t.Fatalf("status %d, want 503: %s", w.Code, w.Body)
+			}
+
+			var got map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range []string{"code", "message", "fallback", "degraded_response"} {
+				if _, ok := got[field]; !ok {
+					t.Errorf("missing %q in %s", field, w.Body)
+				}
+			}
+
+			var resp ErrorResponse
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Code != errAnalysisFailed || !resp.Fallback || resp.Message == "" {
+				t.Errorf("error response = %+v", resp)
+			}
+			if resp.DegradedResponse == nil || resp.DegradedResponse.Severity != severityUnknown {
+				t.Errorf("degraded_response = %+v, want the unknown fallback", resp.DegradedResponse)
+			}
+		})
+	}
+}
+
+func TestInvalidEventErrorResponse(t *testing.T) {
+
+	w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down","mode":"summarize"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", w.Code)
+	}
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != errInvalidEvent || resp.Fallback || resp.DegradedResponse != nil {
+		t.Errorf("error response = %+v", resp)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+/* ======================================================
+   🔥 EVENT FINGERPRINT
+   ======================================================
+
+   A stable join key for downstream dedup/correlation:
+
+     fingerprint = hex(SHA-256(type + "\x00" + message + "\x00" + source_host))
+
+   with each field normalized by normalizeEventField (trimmed,
+   lowercased, whitespace runs collapsed to one space). Any
+   cache keyed on events must start from eventKeyFields.
+*/
+
+func eventFingerprint(event Event) string {
+
+	key := strings.Join(eventKeyFields(event), "\x00")
+
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// eventKeyFields are the normalized type, message and source host that
+// identify an event.
+func eventKeyFields(event Event) []string {
+	return []string{
+		normalizeEventField(event.Type),
+		normalizeEventField(event.Message),
+		normalizeEventField(event.SourceHost),
+	}
+}
+
+// normalizeEventField makes cosmetically different events ("Link  Down"
+// vs "link down ") compare equal.
+func normalizeEventField(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
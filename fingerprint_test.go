@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+/* ---------------- EVENT FINGERPRINT ---------------- */
+
+func TestEventFingerprint(t *testing.T) {
+
+	base := Event{Type: "syslog", Message: "Link down on Gi0/1", SourceHost: "core-sw1"}
+
+	same := eventFingerprint(Event{Type: " SYSLOG", Message: "link  DOWN on gi0/1 ", SourceHost: "Core-SW1", Severity: "high"})
+	if got := eventFingerprint(base); got != same {
+		t.Errorf("equivalent events: %s != %s", got, same)
+	}
+	if len(same) != 64 {
+		t.Errorf("fingerprint %q is not hex SHA-256", same)
+	}
+
+	for _, other := range []Event{
+		{Type: "snmp", Message: base.Message, SourceHost: base.SourceHost},
+		{Type: base.Type, Message: "Link up on Gi0/1", SourceHost: base.SourceHost},
+		{Type: base.Type, Message: base.Message, SourceHost: "core-sw2"},
+	} {
+		if eventFingerprint(other) == eventFingerprint(base) {
+			t.Errorf("%+v shares a fingerprint with %+v", other, base)
+		}
+	}
+}
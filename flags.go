@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ======================================================
+   🔥 FEATURE FLAGS
+   ======================================================
+
+   Flags start from defaultFeatureFlags, then FEATURE_FLAGS,
+   then FEATURE_FLAGS_<APP_ENV> (e.g. FEATURE_FLAGS_PRODUCTION),
+   each a comma-separated "name=true|false" list.
+*/
+
+const (
+	FlagRAG          = "rag"
+	FlagRAGCWE       = "rag_cwe"
+	FlagRAGGrouping  = "rag_grouping"
+	FlagKEV          = "kev"
+	FlagEPSS         = "epss"
+	FlagStreaming    = "streaming"
+	FlagBatch        = "batch"
+	FlagFileIngest   = "file_ingest"
+	FlagKafka        = "kafka"
+	FlagStrictFresh  = "strict_cve_freshness"
+	FlagDebugRawResp = "debug_raw"
+	FlagLangDetect   = "language_detection"
+	FlagRuleFallback = "rule_fallback"
+	FlagCorrelation  = "correlation"
+	FlagGRPC         = "grpc"
+)
+
+func defaultFeatureFlags() map[string]bool {
+	return map[string]bool{
+		FlagRAG:       true,
+		FlagKEV:       true,
+		FlagEPSS:      true,
+		FlagStreaming: true,
+		FlagBatch:     true,
+
+		FlagLangDetect:   true,
+		FlagRuleFallback: true,
+
+		// Older individual switches still seed their flag
+		FlagRAGCWE:       envBool("RAG_INCLUDE_CWE", false),
+		FlagRAGGrouping:  envBool("RAG_GROUP_ADVISORIES", false),
+		FlagFileIngest:   os.Getenv("INGEST_MODE") == "files",
+		FlagKafka:        os.Getenv("EVENT_SOURCE") == "kafka",
+		FlagStrictFresh:  envBool("CVE_STRICT_FRESHNESS", false),
+		FlagDebugRawResp: envBool("DEBUG_RAW_ENABLED", false),
+	}
+}
+
+var (
+	featureFlags     map[string]bool
+	featureFlagsOnce sync.Once
+)
+
+func loadFeatureFlags() map[string]bool {
+
+	flags := defaultFeatureFlags()
+
+	sources := []string{"FEATURE_FLAGS"}
+	if env := appEnv(); env != "" {
+		sources = append(sources, "FEATURE_FLAGS_"+strings.ToUpper(env))
+	}
+
+	for _, key := range sources {
+		for name, raw := range envMap(key) {
+			v, err := strconv.ParseBool(raw)
+			if err != nil {
+				Logger.Printf("⚠️ Ignoring feature flag %s=%q in %s", name, raw, key)
+				continue
+			}
+			flags[name] = v
+		}
+	}
+
+	return flags
+}
+
+func appEnv() string {
+	return strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV")))
+}
+
+// FeatureEnabled reports whether the named flag is on. Unknown flags are off.
+func FeatureEnabled(name string) bool {
+	return activeFeatureFlags()[name]
+}
+
+func activeFeatureFlags() map[string]bool {
+	featureFlagsOnce.Do(func() {
+		featureFlags = loadFeatureFlags()
+	})
+	return featureFlags
+}
+
+/* ---------------- ENDPOINT + GUARD ---------------- */
+
+func handleFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"env":   appEnv(),
+		"flags": activeFeatureFlags(),
+	})
+}
+
+// requireFeature answers 404 for routes whose flag is off.
+func requireFeature(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !FeatureEnabled(name) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "feature disabled: " + name,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------------- FEATURE FLAGS ---------------- */
+
+func TestLoadFeatureFlagsPerEnvironment(t *testing.T) {
+
+	t.Setenv("FEATURE_FLAGS", "rag=false,batch=false,bogus=maybe")
+	t.Setenv("APP_ENV", "Production")
+	t.Setenv("FEATURE_FLAGS_PRODUCTION", "batch=true")
+
+	flags := loadFeatureFlags()
+
+	if flags[FlagRAG] || !flags[FlagBatch] || !flags[FlagStreaming] {
+		t.Errorf("rag=%v batch=%v streaming=%v, want false/true (env override)/true (default)",
+			flags[FlagRAG], flags[FlagBatch], flags[FlagStreaming])
+	}
+	if _, ok := flags["bogus"]; ok {
+		t.Error("invalid flag value accepted")
+	}
+}
+
+func TestDisabledFlagShortCircuitsRoute(t *testing.T) {
+
+	router := gin.New()
+	router.POST("/events/batch", requireFeature(FlagBatch), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for enabled, want := range map[bool]int{true: http.StatusOK, false: http.StatusNotFound} {
+		setFlags(t, map[string]bool{FlagBatch: enabled})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events/batch", strings.NewReader("{}")))
+
+		if w.Code != want {
+			t.Errorf("batch=%v: status %d, want %d", enabled, w.Code, want)
+		}
+	}
+}
+
+func TestDisabledRAGSkipsCVEs(t *testing.T) {
+
+	useRecentCVEs(t, ciscoCVEs(3))
+	event := Event{Type: "crash", Message: "Cisco IOS XE crashed"}
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var ragData string
+	_, err := dispatch(context.Background(), event, func(_ context.Context, _ Event, rag string) (UnifiedResponse, error) {
+		ragData = rag
+		return UnifiedResponse{Severity: "low"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ragData != "" {
+		t.Errorf("rag=false still sent CVE context:\n%s", ragData)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ======================================================
+   🔥 FOUNDATION MODELS
+   ======================================================
+
+   GET /models lists the models Watsonx offers in the
+   configured region, from foundation_model_specs. The list
+   changes rarely, so it is cached for an hour; startup uses
+   it to warn about a WATSONX_MODEL_ID that is not offered.
+*/
+
+const modelSpecsTTL = time.Hour
+
+// foundationModel is one entry of GET /models.
+type foundationModel struct {
+	ModelID           string `json:"model_id"`
+	Label             string `json:"label,omitempty"`
+	MaxSequenceLength int    `json:"max_sequence_length,omitempty"`
+	MaxOutputTokens   int    `json:"max_output_tokens,omitempty"`
+	Generation        bool   `json:"generation"`
+	Chat              bool   `json:"chat"`
+}
+
+var (
+	modelSpecsMutex     sync.Mutex
+	modelSpecs          []foundationModel
+	modelSpecsFetchedAt time.Time
+)
+
+// listFoundationModels returns the cached model list, fetching it when
+// older than modelSpecsTTL.
+func listFoundationModels(ctx context.Context) ([]foundationModel, time.Time, error) {
+
+	modelSpecsMutex.Lock()
+	defer modelSpecsMutex.Unlock()
+
+	if modelSpecs != nil && time.Since(modelSpecsFetchedAt) < modelSpecsTTL {
+		return modelSpecs, modelSpecsFetchedAt, nil
+	}
+
+	models, err := fetchFoundationModels(ctx, getWatsonConfig())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	modelSpecs = models
+	modelSpecsFetchedAt = time.Now().UTC()
+
+	return modelSpecs, modelSpecsFetchedAt, nil
+}
+
+func fetchFoundationModels(ctx context.Context, cfg WatsonConfig) ([]foundationModel, error) {
+
+	if apiKeyCount() == 0 {
+		return nil, errors.New("WATSONX_API_KEYS not set")
+	}
+
+	if !cfg.hasEndpoint() {
+		return nil, errors.New("WATSONX_REGION not set")
+	}
+
+	ctx, cancel := withStepTimeout(ctx, cfg.GenerationTimeout, errGenerationTimeout)
+	defer cancel()
+
+	endpoint := mlEndpoint(cfg, "foundation_model_specs") + "&limit=200"
+
+	var models []foundationModel
+
+	err := withKeyRotation(ctx, func(token string) error {
+
+		resp, err := doWithRetry(ctx, newHTTPClient(0), cfg.retryPolicy(), "Watsonx", func() (*http.Request, error) {
+
+			req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Accept", "application/json")
+
+			return req, nil
+		})
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			body, _ := io.ReadAll(resp.Body)
+			return &statusError{Service: "Watsonx", Code: resp.StatusCode, Body: string(body)}
+		}
+
+		models, err = decodeModelSpecs(resp.Body)
+		return err
+	})
+
+	return models, err
+}
+
+func decodeModelSpecs(r io.Reader) ([]foundationModel, error) {
+
+	var specs struct {
+		Resources []struct {
+			ModelID   string `json:"model_id"`
+			Label     string `json:"label"`
+			Functions []struct {
+				ID string `json:"id"`
+			} `json:"functions"`
+			ModelLimits struct {
+				MaxSequenceLength int `json:"max_sequence_length"`
+				MaxOutputTokens   int `json:"max_output_tokens"`
+			} `json:"model_limits"`
+		} `json:"resources"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&specs); err != nil {
+		return nil, fmt.Errorf("decode foundation_model_specs: %w", err)
+	}
+
+	models := make([]foundationModel, 0, len(specs.Resources))
+
+	for _, res := range specs.Resources {
+
+		m := foundationModel{
+			ModelID:           res.ModelID,
+			Label:             res.Label,
+			MaxSequenceLength: res.ModelLimits.MaxSequenceLength,
+			MaxOutputTokens:   res.ModelLimits.MaxOutputTokens,
+		}
+
+		for _, f := range res.Functions {
+			switch f.ID {
+			case "text_generation":
+				m.Generation = true
+			case "text_chat":
+				m.Chat = true
+			}
+		}
+
+		models = append(models, m)
+	}
+
+	return models, nil
+}
+
+func handleModels(c *gin.Context) {
+
+	models, fetchedAt, err := listFoundationModels(c.Request.Context())
+	if err != nil {
+		LogFields("⚠️ Listing Watsonx models failed", "error", err)
+		respondError(c, http.StatusBadGateway, errModelsUnavailable, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"configured_model": getWatsonConfig().ModelID,
+		"fetched_at":       fetchedAt.Format(time.RFC3339),
+		"models":           models,
+	})
+}
+
+// CheckConfiguredModel warns when WATSONX_MODEL_ID (or an allowed
+// override) is not offered in the region, or cannot do what
+// WATSONX_USE_CHAT asks of it. Listing failures only log.
+func CheckConfiguredModel(ctx context.Context) {
+
+	models, _, err := listFoundationModels(ctx)
+	if err != nil {
+		LogFields("⚠️ Could not list Watsonx models — skipping model check", "error", err)
+		return
+	}
+
+	cfg := getWatsonConfig()
+	offered := make(map[string]foundationModel, len(models))
+	for _, m := range models {
+		offered[m.ModelID] = m
+	}
+
+	for _, id := range append([]string{cfg.ModelID}, cfg.AllowedModels...) {
+
+		m, ok := offered[id]
+		switch {
+		case !ok:
+			LogFields("⚠️ Configured model not offered by Watsonx", "model", id, "region", cfg.Region)
+		case cfg.UseChatAPI && !m.Chat:
+			LogFields("⚠️ Configured model does not support chat", "model", id)
+		case !cfg.UseChatAPI && !m.Generation:
+			LogFields("⚠️ Configured model does not support text generation", "model", id)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+/* ======================================================
+   🔥 gRPC API
+   ======================================================
+
+   With the "grpc" flag on, the Analyzer service of
+   proto/aicore.proto is served on GRPC_PORT (default 9001)
+   next to the HTTP API. Analyze mirrors POST /events and
+   AnalyzeStream mirrors POST /events/stream, through the
+   same dispatch path (correlation, fallback, signing).
+
+   The messages are encoded by hand with protowire (see the
+   WIRE FORMAT section) instead of generated code, so the
+   build needs no protoc step; field numbers must match the
+   .proto file.
+*/
+
+const analyzerService = "aicore.v1.Analyzer"
+
+// StartGRPCServer listens on GRPC_PORT. The returned stop function
+// finishes in-flight calls, then closes the listener.
+func StartGRPCServer() (func(), error) {
+
+	addr := ":" + envString("GRPC_PORT", "9001")
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("grpc listen %s: %w", addr, err)
+	}
+
+	srv := newGRPCServer()
+
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			Logger.Printf("❌ gRPC server stopped: %v", err)
+		}
+	}()
+
+	LogFields("🚀 gRPC API running", "addr", addr, "service", analyzerService)
+
+	return func() {
+		srv.GracefulStop()
+		Logger.Println("🛑 gRPC server stopped")
+	}, nil
+}
+
+func newGRPCServer() *grpc.Server {
+
+	srv := grpc.NewServer(grpc.ForceServerCodec(wireCodec{}))
+	srv.RegisterService(&analyzerServiceDesc, grpcAnalyzer{})
+
+	return srv
+}
+
+/* ---------------- SERVICE ---------------- */
+
+type analyzerServer interface {
+	Analyze(ctx context.Context, req *analyzeRequest) (*analyzeResponse, error)
+	AnalyzeStream(req *analyzeRequest, stream grpc.ServerStream) error
+}
+
+type grpcAnalyzer struct{}
+
+// Analyze answers a failed analysis with the fallback result marked
+// degraded, as /events does with its 503 body.
+func (grpcAnalyzer) Analyze(ctx context.Context, req *analyzeRequest) (*analyzeResponse, error) {
+
+	if err := validateEvent(req.Event); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result, err := DispatchCorrelated(ctx, req.Event)
+	result.RawOutput = ""
+
+	if err != nil {
+		return &analyzeResponse{Result: result, Degraded: true, Error: err.Error()}, nil
+	}
+
+	signResponse(&result)
+
+	return &analyzeResponse{Result: result}, nil
+}
+
+func (grpcAnalyzer) AnalyzeStream(req *analyzeRequest, stream grpc.ServerStream) error {
+
+	if err := validateEvent(req.Event); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var sendErr error
+
+	result := DispatchEventStream(stream.Context(), req.Event, func(delta string) {
+		if sendErr == nil {
+			sendErr = stream.SendMsg(&analyzeChunk{Text: delta})
+		}
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+
+	result.RawOutput = ""
+	signResponse(&result)
+
+	return stream.SendMsg(&analyzeChunk{Result: &analyzeResponse{Result: result}})
+}
+
+var analyzerServiceDesc = grpc.ServiceDesc{
+	ServiceName: analyzerService,
+	HandlerType: (*analyzerServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Analyze",
+		Handler:    handleAnalyzeRPC,
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "AnalyzeStream",
+		Handler:       handleAnalyzeStreamRPC,
+		ServerStreams: true,
+	}},
+	Metadata: "proto/aicore.proto",
+}
+
+func handleAnalyzeRPC(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+
+	req := new(analyzeRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(analyzerServer).Analyze(ctx, req)
+	}
+
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + analyzerService + "/Analyze"}
+
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(analyzerServer).Analyze(ctx, req.(*analyzeRequest))
+	})
+}
+
+func handleAnalyzeStreamRPC(srv interface{}, stream grpc.ServerStream) error {
+
+	req := new(analyzeRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	return srv.(analyzerServer).AnalyzeStream(req, stream)
+}
+
+/* ======================================================
+   🔥 WIRE FORMAT
+   ====================================================== */
+
+// wireMessage is a message of proto/aicore.proto.
+type wireMessage interface {
+	appendWire(b []byte) []byte
+	readWire(b []byte) error
+}
+
+// wireCodec marshals wireMessages in the protobuf binary format under the
+// "proto" name, so generated clients interoperate.
+type wireCodec struct{}
+
+func (wireCodec) Name() string { return "proto" }
+
+func (wireCodec) Marshal(v interface{}) ([]byte, error) {
+
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("grpc: cannot marshal %T", v)
+	}
+
+	return m.appendWire(nil), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v interface{}) error {
+
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("grpc: cannot unmarshal into %T", v)
+	}
+
+	return m.readWire(data)
+}
+
+/* ---------------- AnalyzeRequest ---------------- */
+
+type analyzeRequest struct {
+	Event Event
+}
+
+// fields lists the string fields by number (1-based).
+func (r *analyzeRequest) fields() []*string {
+	e := &r.Event
+	return []*string{
+		&e.Type, &e.Message, &e.SourceHost, &e.SourceIP, &e.Category,
+		&e.Mode, &e.Severity, &e.ModelID, &e.Language, &e.AssetCriticality,
+	}
+}
+
+func (r *analyzeRequest) appendWire(b []byte) []byte {
+
+	for i, f := range r.fields() {
+		b = appendWireString(b, protowire.Number(i+1), *f)
+	}
+
+	return b
+}
+
+func (r *analyzeRequest) readWire(b []byte) error {
+
+	fields := r.fields()
+
+	return readWireFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+
+		if typ == protowire.BytesType && num >= 1 && int(num) <= len(fields) {
+			v, n := protowire.ConsumeString(b)
+			*fields[num-1] = v
+			return n, nil
+		}
+
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+}
+
+/* ---------------- AnalyzeResponse ---------------- */
+
+type analyzeResponse struct {
+	Result   UnifiedResponse
+	Degraded bool
+	Error    string
+}
+
+// stringFields maps field numbers to the response's string fields.
+func (r *analyzeResponse) stringFields() map[protowire.Number]*string {
+	u := &r.Result
+	return map[protowire.Number]*string{
+		1: &u.Severity, 2: &u.Explanation, 3: &u.RecommendedAction,
+		5: &u.RootCause, 6: &u.Impact, 8: &u.Source, 9: &u.Fingerprint,
+		10: &u.AnalyzedAt, 14: &u.ModelSeverity, 15: &u.Signature,
+		17: &r.Error,
+	}
+}
+
+// intFields maps field numbers to the response's int32 fields.
+func (r *analyzeResponse) intFields() map[protowire.Number]*int {
+	u := &r.Result
+	return map[protowire.Number]*int{
+		7: &u.Confidence, 11: &u.SeverityLevel, 13: &u.CorrelatedEvents,
+	}
+}
+
+func (r *analyzeResponse) appendWire(b []byte) []byte {
+
+	strs, ints := r.stringFields(), r.intFields()
+
+	// In field number order, like generated code
+	for num := protowire.Number(1); num <= 17; num++ {
+
+		if s, ok := strs[num]; ok {
+			b = appendWireString(b, num, *s)
+		}
+		if v, ok := ints[num]; ok && *v != 0 {
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(int64(int32(*v))))
+		}
+
+		switch num {
+		case 4:
+			for _, step := range r.Result.RemediationSteps {
+				b = protowire.AppendTag(b, num, protowire.BytesType)
+				b = protowire.AppendString(b, step)
+			}
+		case 12:
+			b = appendWireBool(b, num, r.Result.RequiresReview)
+		case 16:
+			b = appendWireBool(b, num, r.Degraded)
+		}
+	}
+
+	return b
+}
+
+func (r *analyzeResponse) readWire(b []byte) error {
+
+	strs, ints := r.stringFields(), r.intFields()
+
+	return readWireFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+
+		switch {
+		case typ == protowire.BytesType && num == 4:
+			v, n := protowire.ConsumeString(b)
+			r.Result.RemediationSteps = append(r.Result.RemediationSteps, v)
+			return n, nil
+
+		case typ == protowire.BytesType && strs[num] != nil:
+			v, n := protowire.ConsumeString(b)
+			*strs[num] = v
+			return n, nil
+
+		case typ == protowire.VarintType && (ints[num] != nil || num == 12 || num == 16):
+			v, n := protowire.ConsumeVarint(b)
+			switch num {
+			case 12:
+				r.Result.RequiresReview = v != 0
+			case 16:
+				r.Degraded = v != 0
+			default:
+				*ints[num] = int(int32(v))
+			}
+			return n, nil
+		}
+
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+}
+
+/* ---------------- AnalyzeChunk ---------------- */
+
+// analyzeChunk carries either generated text or, last, the result.
+type analyzeChunk struct {
+	Text   string
+	Result *analyzeResponse
+}
+
+func (c *analyzeChunk) appendWire(b []byte) []byte {
+
+	if c.Result != nil {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		return protowire.AppendBytes(b, c.Result.appendWire(nil))
+	}
+
+	// A oneof member is sent even when empty
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendString(b, c.Text)
+}
+
+func (c *analyzeChunk) readWire(b []byte) error {
+
+	return readWireFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			return protowire.ConsumeFieldValue(num, typ, b), nil
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+
+		if num == 1 {
+			c.Text, c.Result = string(v), nil
+			return n, nil
+		}
+
+		c.Result = &analyzeResponse{}
+		return n, c.Result.readWire(v)
+	})
+}
+
+/* ---------------- HELPERS ---------------- */
+
+// appendWireString omits empty strings, as proto3 does.
+func appendWireString(b []byte, num protowire.Number, s string) []byte {
+
+	if s == "" {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendWireBool(b []byte, num protowire.Number, v bool) []byte {
+
+	if !v {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+// readWireFields walks the fields of b; field consumes one value and
+// returns its length (negative on malformed input). Unknown fields are
+// skipped by the caller via protowire.ConsumeFieldValue.
+func readWireFields(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+
+	for len(b) > 0 {
+
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+
+	return nil
+}
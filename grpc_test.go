@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialGRPC serves the analyzer in process and returns a client
+// connection to it.
+func dialGRPC(t *testing.T) *grpc.ClientConn {
+
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+/* ---------------- gRPC API ---------------- */
+
+func TestAnalyzeRPC(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	stubWatsonx(t, generationReply(`{"severity":"high","explanation":"uplink lost","confidence":80}`))
+
+	conn := dialGRPC(t)
+	method := "/" + analyzerService + "/Analyze"
+
+	req := &analyzeRequest{Event: Event{Type: "link_down", Message: "Gi0/1 down", SourceHost: "core-sw1"}}
+	resp := new(analyzeResponse)
+	if err := conn.Invoke(context.Background(), method, req, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Degraded || resp.Result.Severity != "high" || resp.Result.Explanation != "uplink lost" || resp.Result.Confidence != 80 {
+		t.Errorf("response %+v", resp)
+	}
+
+	invalid := &analyzeRequest{Event: Event{Type: "link_down", Message: "Gi0/1 down", Mode: "summarize"}}
+	err := conn.Invoke(context.Background(), method, invalid, new(analyzeResponse))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid event: %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ======================================================
+   🔥 HEALTH / READINESS / LIVENESS
+   ======================================================
+
+   /live   → the process is up (never checks dependencies)
+   /ready  → Watson is configured with a usable key and we
+             are not shutting down; 503 otherwise
+   /health → per-subsystem detail: 503 when Watson is down,
+             200 "degraded" when only CVE enrichment is stale
+             or the last NVD fetch failed
+*/
+
+// shuttingDown flips /ready to 503 so load balancers stop routing here.
+var shuttingDown atomic.Bool
+
+type watsonHealth struct {
+	Configured  bool `json:"configured"`
+	HealthyKeys int  `json:"healthy_keys"`
+	TotalKeys   int  `json:"total_keys"`
+}
+
+func (w watsonHealth) ok() bool {
+	return w.Configured && w.HealthyKeys > 0
+}
+
+func checkWatson() watsonHealth {
+
+	cfg := getWatsonConfig()
+
+	return watsonHealth{
+		Configured:  apiKeyCount() > 0 && cfg.hasEndpoint() && cfg.ProjectID != "",
+		HealthyKeys: healthyKeyCount(),
+		TotalKeys:   apiKeyCount(),
+	}
+}
+
+func handleHealth(c *gin.Context) {
+
+	watson := checkWatson()
+	count, updatedAt, nvdErr := cveCacheStatus()
+
+	cve := gin.H{
+		"cve_count":      count,
+		"last_nvd_error": nvdErr,
+	}
+
+	// Stale past CVE_MAX_CACHE_AGE, the same limit as strict startup
+	stale := updatedAt.IsZero()
+	if !updatedAt.IsZero() {
+		age := time.Since(updatedAt)
+		cve["cve_cache_age"] = age.Round(time.Second).String()
+		stale = age > envDuration("CVE_MAX_CACHE_AGE", 24*time.Hour)
+	}
+	cve["stale"] = stale
+
+	// Watsonx credentials only matter when Watsonx is the provider
+	llm := activeProvider().Name()
+	llmOK := llm != providerWatsonx || watson.ok()
+
+	status, code := "ok", http.StatusOK
+	switch {
+	case !llmOK:
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	case stale || nvdErr != "":
+		status = "degraded"
+	}
+
+	c.JSON(code, gin.H{
+		"status":   status,
+		"provider": llm,
+		"watson":   watson,
+		"cve":      cve,
+	})
+}
+
+func handleReady(c *gin.Context) {
+
+	if shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	if activeProvider().Name() == providerWatsonx && !checkWatson().ok() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "watson_unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+func handleLive(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
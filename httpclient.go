@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+/* ======================================================
+   🔥 OUTBOUND HTTP
+   ======================================================
+
+   Every outbound client (Watsonx, IAM, OpenAI, NVD, KEV,
+   EPSS, alert webhook) shares one transport, so concurrent
+   analyses reuse keep-alive connections instead of a new
+   TLS handshake per call. Go's default keeps only 2 idle
+   connections per host; HTTP_MAX_IDLE_CONNS_PER_HOST raises
+   that. Timeouts stay per client.
+*/
+
+var (
+	transportOnce sync.Once
+	transport     *http.Transport
+)
+
+// sharedTransport is http.DefaultTransport with the idle pool sized by
+// HTTP_MAX_IDLE_CONNS (100), HTTP_MAX_IDLE_CONNS_PER_HOST (20) and
+// HTTP_IDLE_CONN_TIMEOUT (90s).
+func sharedTransport() *http.Transport {
+
+	transportOnce.Do(func() {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = envInt("HTTP_MAX_IDLE_CONNS", 100)
+		transport.MaxIdleConnsPerHost = envInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 20)
+		transport.IdleConnTimeout = envDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second)
+	})
+
+	return transport
+}
+
+// newHTTPClient returns a client on the shared transport; 0 means no
+// overall timeout (the caller's context bounds the call).
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: sharedTransport(), Timeout: timeout}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+/* ---------------- SHARED TRANSPORT ---------------- */
+
+func TestSequentialAnalysesReuseConnection(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var conns, calls atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/identity/token" {
+			writeJSON(w, map[string]interface{}{"access_token": "token", "expires_in": 3600})
+			return
+		}
+		generationReply(`{"severity":"low","explanation":"port flap"}`)(w, r)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	useWatsonConfig(t, testWatsonConfig(srv.URL))
+
+	for i := 0; i < 5; i++ {
+		if w := postEvent(t, `{"type":"link_flap","message":"Gi0/3 flapping"}`); w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+	}
+
+	if n := conns.Load(); n != 1 {
+		t.Errorf("%d connections for %d requests, want 1", n, calls.Load())
+	}
+}
+
+func BenchmarkSharedTransport(b *testing.B) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	for name, client := range map[string]*http.Client{
+		"shared":   newHTTPClient(0),
+		"no-reuse": {Transport: &http.Transport{DisableKeepAlives: true}},
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					b.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+/* ======================================================
+   🔥 IDEMPOTENCY KEYS
+   ======================================================
+
+   A client (or an at-least-once queue) retrying a POST sends
+   the same Idempotency-Key header; the first successful
+   response is stored for IDEMPOTENCY_TTL and replayed
+   verbatim instead of analyzing the event again. Keys are
+   scoped to the route and bound to the request body: reusing
+   a key for a different body is rejected, as is a retry
+   while the first request is still running. Only 2xx
+   responses are stored so a failed analysis can be retried.
+   At most IDEMPOTENCY_MAX_KEYS are kept, least recently
+   used evicted first.
+*/
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	idempotencyMaxKeyLen = 255
+)
+
+var idempotencyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "aicore_idempotency_requests_total",
+	Help: "Requests carrying an Idempotency-Key by outcome (new, replayed, conflict).",
+}, []string{"result"})
+
+type idempotencyEntry struct {
+	key      string
+	bodyHash [sha256.Size]byte
+	done     bool // false while the first request is in flight
+
+	status      int
+	contentType string
+	signature   string
+	body        []byte
+	expires     time.Time
+}
+
+type idempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+}
+
+var idempotencyKeys = &idempotencyStore{
+	entries: map[string]*list.Element{},
+	order:   list.New(),
+}
+
+// begin looks key up and returns the stored response to replay, if any;
+// otherwise it reserves key for this request. conflict says why the
+// request must be rejected: key in flight or used with another body.
+func (s *idempotencyStore) begin(key string, bodyHash [sha256.Size]byte, ttl time.Duration) (entry *idempotencyEntry, conflict string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*idempotencyEntry)
+
+		switch {
+		case e.done && time.Now().After(e.expires):
+			s.order.Remove(el)
+			delete(s.entries, key)
+		case e.bodyHash != bodyHash:
+			return nil, "Idempotency-Key was already used with a different request body"
+		case !e.done:
+			return nil, "a request with this Idempotency-Key is still in progress"
+		default:
+			s.order.MoveToFront(el)
+			return e, ""
+		}
+	}
+
+	s.entries[key] = s.order.PushFront(&idempotencyEntry{
+		key:      key,
+		bodyHash: bodyHash,
+		expires:  time.Now().Add(ttl),
+	})
+
+	for max := envInt("IDEMPOTENCY_MAX_KEYS", 10000); s.order.Len() > max && max > 0; {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*idempotencyEntry).key)
+	}
+
+	return nil, ""
+}
+
+// finish stores the response for key, or releases the reservation when
+// the response is not worth replaying.
+func (s *idempotencyStore) finish(key string, status int, contentType, signature string, body []byte, ttl time.Duration) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return // evicted while in flight
+	}
+
+	if status/100 != 2 {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return
+	}
+
+	e := el.Value.(*idempotencyEntry)
+	e.done = true
+	e.status = status
+	e.contentType = contentType
+	e.signature = signature
+	e.body = body
+	e.expires = time.Now().Add(ttl)
+}
+
+// PurgeIdempotencyKeys forgets every stored response and reservation.
+func PurgeIdempotencyKeys() {
+
+	idempotencyKeys.mu.Lock()
+	defer idempotencyKeys.mu.Unlock()
+
+	idempotencyKeys.entries = map[string]*list.Element{}
+	idempotencyKeys.order.Init()
+}
+
+// bodyRecorder copies the response body while it is written.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent replays the stored response for a repeated Idempotency-Key.
+// Requests without the header, or with IDEMPOTENCY_TTL=0, pass through.
+func idempotent() gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		key := c.GetHeader(idempotencyHeader)
+		ttl := envDuration("IDEMPOTENCY_TTL", 24*time.Hour)
+
+		if key == "" || ttl <= 0 {
+			c.Next()
+			return
+		}
+
+		if len(key) > idempotencyMaxKeyLen {
+			respondError(c, http.StatusBadRequest, errInvalidRequest, "Idempotency-Key longer than 255 characters")
+			return
+		}
+
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, errInvalidRequest, err.Error())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+		scoped := c.FullPath() + "\x00" + key
+
+		stored, conflict := idempotencyKeys.begin(scoped, sha256.Sum256(raw), ttl)
+
+		switch {
+		case conflict != "":
+			idempotencyRequests.WithLabelValues("conflict").Inc()
+			respondError(c, http.StatusConflict, errIdempotencyConflict, conflict)
+			return
+
+		case stored != nil:
+			idempotencyRequests.WithLabelValues("replayed").Inc()
+			if stored.signature != "" {
+				c.Header("X-Signature", stored.signature)
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.status, stored.contentType, stored.body)
+			c.Abort()
+			return
+		}
+
+		idempotencyRequests.WithLabelValues("new").Inc()
+
+		// A panicking handler must not leave the key reserved forever
+		defer func() {
+			if r := recover(); r != nil {
+				idempotencyKeys.finish(scoped, http.StatusInternalServerError, "", "", nil, ttl)
+				panic(r)
+			}
+		}()
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		idempotencyKeys.finish(scoped,
+			recorder.Status(),
+			recorder.Header().Get("Content-Type"),
+			recorder.Header().Get("X-Signature"),
+			recorder.body.Bytes(),
+			ttl,
+		)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+/* ---------------- IDEMPOTENCY KEYS ---------------- */
+
+func TestRepeatedIdempotencyKeyReplaysResponse(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	PurgeIdempotencyKeys()
+	t.Cleanup(PurgeIdempotencyKeys)
+
+	var calls atomic.Int32
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		generationReply(`{"severity":"high","explanation":"uplink lost"}`)(w, r)
+	})
+
+	body := `{"type":"link_down","message":"Gi0/1 down"}`
+	first := postEvent(t, body, idempotencyHeader, "retry-1")
+	second := postEvent(t, body, idempotencyHeader, "retry-1")
+
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("status %d/%d", first.Code, second.Code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Watsonx calls = %d, want 1", n)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("replayed body differs:\n%s\n%s", first.Body, second.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay not marked Idempotent-Replayed")
+	}
+
+	PurgeIdempotencyKeys()
+	postEvent(t, body, idempotencyHeader, "retry-1")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Watsonx calls after purge = %d, want 2", n)
+	}
+}
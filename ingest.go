@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* ======================================================
+   🔥 FILE-BASED EVENT INGESTION
+   ======================================================
+
+   INGEST_MODE=files polls INGEST_DIR for *.json event files,
+   dispatches each one, writes the result to INGEST_OUT_DIR
+   and moves the source to INGEST_DONE_DIR (or INGEST_ERROR_DIR
+   when it cannot be parsed). Meant for local testing/replay.
+*/
+
+type fileIngestConfig struct {
+	InDir    string
+	OutDir   string
+	DoneDir  string
+	ErrorDir string
+	Interval time.Duration
+}
+
+func loadFileIngestConfig() fileIngestConfig {
+
+	return fileIngestConfig{
+		InDir:    envString("INGEST_DIR", "ingest/in"),
+		OutDir:   envString("INGEST_OUT_DIR", "ingest/out"),
+		DoneDir:  envString("INGEST_DONE_DIR", "ingest/done"),
+		ErrorDir: envString("INGEST_ERROR_DIR", "ingest/error"),
+		Interval: envDuration("INGEST_POLL_INTERVAL", 2*time.Second),
+	}
+}
+
+func StartFileIngest(ctx context.Context) error {
+
+	cfg := loadFileIngestConfig()
+
+	for _, dir := range []string{cfg.InDir, cfg.OutDir, cfg.DoneDir, cfg.ErrorDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("file ingest: %w", err)
+		}
+	}
+
+	Logger.Printf("📂 File ingest watching %s", cfg.InDir)
+
+	go func() {
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			processIngestDir(ctx, cfg)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+func processIngestDir(ctx context.Context, cfg fileIngestConfig) {
+
+	files, err := filepath.Glob(filepath.Join(cfg.InDir, "*.json"))
+	if err != nil {
+		Logger.Printf("⚠️ File ingest glob failed: %v", err)
+		return
+	}
+
+	sort.Strings(files)
+
+	for _, path := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		processIngestFile(ctx, cfg, path)
+	}
+}
+
+func processIngestFile(ctx context.Context, cfg fileIngestConfig, path string) {
+
+	name := filepath.Base(path)
+
+	evt, err := readEventFile(path)
+	if err != nil {
+		Logger.Printf("❌ Ingest %s rejected: %v", name, err)
+		moveFile(path, filepath.Join(cfg.ErrorDir, name))
+		return
+	}
+
+	result, _ := DispatchEvent(ctx, evt)
+	result.RawOutput = ""
+	signResponse(&result)
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	out := filepath.Join(cfg.OutDir, strings.TrimSuffix(name, ".json")+".result.json")
+
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		Logger.Printf("⚠️ Ingest %s: writing result failed: %v", name, err)
+		return
+	}
+
+	moveFile(path, filepath.Join(cfg.DoneDir, name))
+
+	Logger.Printf("✅ Ingested %s → %s", name, out)
+}
+
+func readEventFile(path string) (Event, error) {
+
+	var evt Event
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return evt, err
+	}
+
+	if err := json.Unmarshal(data, &evt); err != nil {
+		return evt, err
+	}
+
+	if evt.Message == "" {
+		return evt, fmt.Errorf("missing message")
+	}
+
+	return evt, validateEvent(evt)
+}
+
+func moveFile(from, to string) {
+	if err := os.Rename(from, to); err != nil {
+		Logger.Printf("⚠️ Failed to move %s: %v", from, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tempIngestConfig creates the ingest directories under a temp dir.
+func tempIngestConfig(t *testing.T) fileIngestConfig {
+
+	t.Helper()
+
+	root := t.TempDir()
+	cfg := fileIngestConfig{
+		InDir:    filepath.Join(root, "in"),
+		OutDir:   filepath.Join(root, "out"),
+		DoneDir:  filepath.Join(root, "done"),
+		ErrorDir: filepath.Join(root, "error"),
+	}
+
+	for _, dir := range []string{cfg.InDir, cfg.OutDir, cfg.DoneDir, cfg.ErrorDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return cfg
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+/* ---------------- FILE INGESTION ---------------- */
+
+func TestFileIngest(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	stubWatsonx(t, generationReply(`{"severity":"medium","explanation":"x","recommended_action":"y"}`))
+
+	cfg := tempIngestConfig(t)
+
+	samples := map[string]string{
+		"a-good.json":       `{"type":"link_down","message":"Gi0/1 down"}`,
+		"b-malformed.json":  `{"type":`,
+		"c-no-message.json": `{"type":"link_down"}`,
+		"ignored.txt":       `{"type":"link_down","message":"x"}`,
+	}
+	for name, body := range samples {
+		if err := os.WriteFile(filepath.Join(cfg.InDir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	processIngestDir(context.Background(), cfg)
+
+	raw, err := os.ReadFile(filepath.Join(cfg.OutDir, "a-good.result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result UnifiedResponse
+	if err := json.Unmarshal(raw, &result); err != nil || result.Severity != "medium" {
+		t.Errorf("result = %s (%v), want medium", raw, err)
+	}
+
+	for _, want := range []string{
+		filepath.Join(cfg.DoneDir, "a-good.json"),
+		filepath.Join(cfg.ErrorDir, "b-malformed.json"),
+		filepath.Join(cfg.ErrorDir, "c-no-message.json"),
+		filepath.Join(cfg.InDir, "ignored.txt"),
+	} {
+		if !exists(want) {
+			t.Errorf("%s missing", want)
+		}
+	}
+
+	if left, _ := filepath.Glob(filepath.Join(cfg.InDir, "*.json")); len(left) != 0 {
+		t.Errorf("unprocessed: %v", left)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+/* ======================================================
+   🔥 JSON MODE
+   ======================================================
+
+   With EnforceJSON the chat payload carries the answer's
+   JSON schema as response_format, so the model cannot reply
+   in prose. Models that reject it are remembered and asked
+   again without it; extractJSON still reads every answer,
+   so nothing depends on enforcement succeeding.
+*/
+
+var severityEnum = []string{"info", "low", "medium", "high", "critical"}
+
+// analysisSchema is the JSON schema of the answer each mode's prompt
+// asks for.
+func analysisSchema(mode string) map[string]interface{} {
+
+	str := map[string]interface{}{"type": "string"}
+
+	var props map[string]interface{}
+	var required []string
+
+	switch mode {
+	case ModeClassify:
+		props = map[string]interface{}{
+			"severity":    map[string]interface{}{"type": "string", "enum": severityEnum},
+			"explanation": str,
+		}
+		required = []string{"severity", "explanation"}
+
+	case ModeRemediate:
+		props = map[string]interface{}{
+			"recommended_action": str,
+			"remediation_steps":  map[string]interface{}{"type": "array", "items": str},
+		}
+		required = []string{"recommended_action", "remediation_steps"}
+
+	default:
+		props = map[string]interface{}{
+			"severity":           map[string]interface{}{"type": "string", "enum": severityEnum},
+			"explanation":        str,
+			"root_cause":         str,
+			"impact":             str,
+			"recommended_action": str,
+			"confidence":         map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100},
+		}
+		required = []string{"severity", "explanation", "root_cause", "impact", "recommended_action", "confidence"}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// jsonModeUnsupported holds models that rejected response_format.
+var jsonModeUnsupported sync.Map
+
+// responseFormat is the response_format value for cfg, or nil when JSON
+// is not enforced (or the model is known not to support it).
+func responseFormat(cfg WatsonConfig) map[string]interface{} {
+
+	if !cfg.EnforceJSON {
+		return nil
+	}
+
+	if _, unsupported := jsonModeUnsupported.Load(cfg.ModelID); unsupported {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "event_analysis",
+			"schema": analysisSchema(cfg.mode),
+			"strict": true,
+		},
+	}
+}
+
+// rejectedResponseFormat reports a 400 blaming response_format for a
+// request that sent one, remembering the model so later requests skip it.
+func rejectedResponseFormat(cfg WatsonConfig, status int, body string) bool {
+
+	if responseFormat(cfg) == nil || status != http.StatusBadRequest || !strings.Contains(body, "response_format") {
+		return false
+	}
+
+	jsonModeUnsupported.Store(cfg.ModelID, true)
+
+	LogFields("⚠️ Model rejected response_format — continuing without JSON enforcement",
+		"model", cfg.ModelID,
+	)
+
+	return true
+}
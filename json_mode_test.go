@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+/* ---------------- JSON MODE ---------------- */
+
+// chatReply answers like text/chat with content, storing the request's
+// response_format (nil when absent) in *format.
+func chatReply(content string, format *map[string]interface{}) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ResponseFormat map[string]interface{} `json:"response_format"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		*format = payload.ResponseFormat
+
+		writeJSON(w, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": content}},
+			},
+		})
+	}
+}
+
+func TestEnforceJSONSendsSchema(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var format map[string]interface{}
+	_, cfg := stubWatsonx(t, chatReply(`{"severity":"low"}`, &format))
+	cfg.UseChatAPI = true
+	cfg.EnforceJSON = true
+	useWatsonConfig(t, cfg)
+	t.Cleanup(func() { jsonModeUnsupported.Delete(cfg.ModelID) })
+
+	if w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	if format["type"] != "json_schema" {
+		t.Fatalf("response_format = %v, want a json_schema", format)
+	}
+	spec, _ := format["json_schema"].(map[string]interface{})
+	schema, _ := spec["schema"].(map[string]interface{})
+	props, _ := schema["properties"].(map[string]interface{})
+	for _, field := range []string{"severity", "explanation", "recommended_action", "confidence"} {
+		if _, ok := props[field]; !ok {
+			t.Errorf("schema lacks %q: %v", field, schema)
+		}
+	}
+}
+
+func TestJSONModeOffSendsNoSchema(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var format map[string]interface{}
+	_, cfg := stubWatsonx(t, chatReply(`{"severity":"low"}`, &format))
+	cfg.UseChatAPI = true
+	useWatsonConfig(t, cfg)
+
+	if w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if format != nil {
+		t.Errorf("response_format sent without EnforceJSON: %v", format)
+	}
+}
+
+func TestRejectedSchemaFallsBackToExtraction(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var format map[string]interface{}
+	var sent []bool // whether each request carried response_format
+	reply := chatReply("Sure! {\"severity\":\"medium\"}", &format)
+
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		if len(sent) == 0 {
+			var payload map[string]json.RawMessage
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			_, ok := payload["response_format"]
+			sent = append(sent, ok)
+			http.Error(w, `{"errors":[{"message":"response_format is not supported"}]}`, http.StatusBadRequest)
+			return
+		}
+		reply(w, r)
+		sent = append(sent, format != nil)
+	})
+	cfg.ModelID = "json-mode-unsupported-model"
+	cfg.UseChatAPI = true
+	cfg.EnforceJSON = true
+	useWatsonConfig(t, cfg)
+	t.Cleanup(func() { jsonModeUnsupported.Delete(cfg.ModelID) })
+
+	w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var got UnifiedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Severity != "medium" {
+		t.Errorf("severity = %q, want medium from extraction", got.Severity)
+	}
+	if len(sent) != 2 || !sent[0] || sent[1] {
+		t.Errorf("response_format per request = %v, want [true false]", sent)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+/* ======================================================
+   🔥 KAFKA EVENT SOURCE
+   ======================================================
+
+   EVENT_SOURCE=kafka consumes Event JSON from
+   KAFKA_EVENTS_TOPIC (consumer group KAFKA_GROUP_ID) and
+   publishes each result to KAFKA_RESULTS_TOPIC, keyed by the
+   event fingerprint. An offset is committed only once its
+   result was published, so a crash replays rather than loses
+   events. Runs alongside the HTTP server.
+*/
+
+type kafkaConfig struct {
+	Brokers      []string
+	EventsTopic  string
+	ResultsTopic string
+	GroupID      string
+}
+
+func loadKafkaConfig() (kafkaConfig, error) {
+
+	cfg := kafkaConfig{
+		Brokers:      envList("KAFKA_BROKERS", nil),
+		EventsTopic:  envString("KAFKA_EVENTS_TOPIC", ""),
+		ResultsTopic: envString("KAFKA_RESULTS_TOPIC", ""),
+		GroupID:      envString("KAFKA_GROUP_ID", "ai-core"),
+	}
+
+	if len(cfg.Brokers) == 0 || cfg.EventsTopic == "" || cfg.ResultsTopic == "" {
+		return cfg, errors.New("KAFKA_BROKERS, KAFKA_EVENTS_TOPIC and KAFKA_RESULTS_TOPIC are required")
+	}
+
+	return cfg, nil
+}
+
+// StartKafkaIngest starts the consumer. The returned stop function stops
+// fetching, waits for the event in progress to be published and
+// committed, and closes the connections.
+func StartKafkaIngest(ctx context.Context) (func(), error) {
+
+	cfg, err := loadKafkaConfig()
+	if err != nil {
+		return nil, fmt.Errorf("kafka ingest: %w", err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: cfg.Brokers,
+		Topic:   cfg.EventsTopic,
+		GroupID: cfg.GroupID,
+	})
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.ResultsTopic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		consumeKafka(ctx, reader, writer)
+	}()
+
+	LogFields("📨 Kafka ingest consuming",
+		"topic", cfg.EventsTopic,
+		"group", cfg.GroupID,
+		"results_topic", cfg.ResultsTopic,
+	)
+
+	stop := func() {
+		cancel()
+		wg.Wait()
+
+		if err := reader.Close(); err != nil {
+			Logger.Printf("⚠️ Kafka reader close: %v", err)
+		}
+		if err := writer.Close(); err != nil {
+			Logger.Printf("⚠️ Kafka writer close: %v", err)
+		}
+
+		Logger.Println("🛑 Kafka ingest stopped")
+	}
+
+	return stop, nil
+}
+
+func consumeKafka(ctx context.Context, reader *kafka.Reader, writer *kafka.Writer) {
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			Logger.Printf("⚠️ Kafka fetch failed: %v", err)
+			sleepCtx(ctx, time.Second)
+			continue
+		}
+
+		// The event in progress finishes even once shutdown started
+		processCtx := context.WithoutCancel(ctx)
+
+		result, ok := analyzeKafkaMessage(processCtx, msg)
+		if ok && !publishKafkaResult(ctx, writer, result) {
+			// Shutting down before the result went out: leave the
+			// offset uncommitted so the event is redelivered
+			return
+		}
+
+		if err := reader.CommitMessages(processCtx, msg); err != nil {
+			Logger.Printf("⚠️ Kafka commit failed at offset %d: %v", msg.Offset, err)
+		}
+	}
+}
+
+// analyzeKafkaMessage returns false for messages that are not valid
+// events; those are logged and committed without a result.
+func analyzeKafkaMessage(ctx context.Context, msg kafka.Message) (UnifiedResponse, bool) {
+
+	var evt Event
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		LogFields("❌ Kafka event rejected", "offset", msg.Offset, "error", err)
+		return UnifiedResponse{}, false
+	}
+
+	if err := validateEvent(evt); err != nil {
+		LogFields("❌ Kafka event rejected", "offset", msg.Offset, "error", err)
+		return UnifiedResponse{}, false
+	}
+
+	result, _ := DispatchEvent(ctx, evt)
+	result.RawOutput = ""
+	signResponse(&result)
+
+	return result, true
+}
+
+// publishKafkaResult retries until the result is written, giving up only
+// when ctx is cancelled.
+func publishKafkaResult(ctx context.Context, writer *kafka.Writer, result UnifiedResponse) bool {
+
+	value, _ := json.Marshal(result)
+	msg := kafka.Message{Key: []byte(result.Fingerprint), Value: value}
+
+	for delay := time.Second; ; delay = min(2*delay, 30*time.Second) {
+
+		err := writer.WriteMessages(context.WithoutCancel(ctx), msg)
+		if err == nil {
+			return true
+		}
+
+		Logger.Printf("⚠️ Kafka publish failed, retrying in %s: %v", delay, err)
+
+		if !sleepCtx(ctx, delay) {
+			return false
+		}
+	}
+}
+
+// sleepCtx waits for d, returning false if ctx was cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+/* ======================================================
+   🔥 CISA KNOWN EXPLOITED VULNERABILITIES (KEV)
+   ====================================================== */
+
+const (
+	kevCacheFile       = "kev_cache.json"
+	kevFreshnessWindow = 24 * time.Hour
+)
+
+// kevFeedURL is a variable so tests can point it at a stub server.
+var kevFeedURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+type kevCacheFileData struct {
+	Timestamp time.Time `json:"timestamp"`
+	CVEIDs    []string  `json:"cve_ids"`
+}
+
+var (
+	kevIDs     map[string]bool
+	kevUpdated time.Time
+	kevMutex   sync.RWMutex
+)
+
+// EnsureKEVCatalog loads the KEV catalog from its cache file, fetching
+// from CISA when the cache is older than 24h. On fetch failure the
+// current (possibly stale) catalog is kept.
+func EnsureKEVCatalog() error {
+
+	kevMutex.RLock()
+	fresh := kevIDs != nil && time.Since(kevUpdated) < kevFreshnessWindow
+	kevMutex.RUnlock()
+
+	if fresh {
+		return nil
+	}
+
+	if cache, err := loadKEVCache(); err == nil {
+
+		setKEVCatalog(cache.CVEIDs, cache.Timestamp)
+
+		if time.Since(cache.Timestamp) < kevFreshnessWindow {
+			Logger.Println("✅ Loaded KEV catalog from cache file")
+			return nil
+		}
+	}
+
+	Logger.Println("🌐 Fetching CISA KEV catalog")
+
+	ids, err := fetchKEVCatalog()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	setKEVCatalog(ids, now)
+	saveKEVCache(ids, now)
+
+	Logger.Printf("✅ Stored %d KEV entries", len(ids))
+	return nil
+}
+
+func fetchKEVCatalog() ([]string, error) {
+
+	req, _ := http.NewRequest(http.MethodGet, kevFeedURL, nil)
+	req.Header.Set("User-Agent", "ai-core/1.0")
+
+	client := newHTTPClient(30 * time.Second)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KEV request failed: %s", resp.Status)
+	}
+
+	var feed struct {
+		Vulnerabilities []struct {
+			CVEID string `json:"cveID"`
+		} `json:"vulnerabilities"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(feed.Vulnerabilities))
+	for _, v := range feed.Vulnerabilities {
+		ids = append(ids, v.CVEID)
+	}
+
+	return ids, nil
+}
+
+func setKEVCatalog(ids []string, updated time.Time) {
+
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+
+	kevMutex.Lock()
+	kevIDs = set
+	kevUpdated = updated
+	kevMutex.Unlock()
+}
+
+// markKnownExploited sets KnownExploited on CVEs listed in the catalog.
+func markKnownExploited(items []CVE) {
+
+	kevMutex.RLock()
+	defer kevMutex.RUnlock()
+
+	for i := range items {
+		items[i].KnownExploited = kevIDs[items[i].ID]
+	}
+}
+
+/* ---------------- FILE OPERATIONS ---------------- */
+
+func loadKEVCache() (*kevCacheFileData, error) {
+
+	data, err := os.ReadFile(kevCacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache kevCacheFileData
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+
+	return &cache, nil
+}
+
+func saveKEVCache(ids []string, ts time.Time) {
+
+	data, _ := json.MarshalIndent(kevCacheFileData{Timestamp: ts, CVEIDs: ids}, "", "  ")
+	_ = os.WriteFile(kevCacheFile, data, 0644)
+}
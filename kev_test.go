@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useKEVCatalog replaces the KEV catalog for the test.
+func useKEVCatalog(t *testing.T, ids ...string) {
+
+	t.Helper()
+
+	kevMutex.RLock()
+	prev, prevAt := kevIDs, kevUpdated
+	kevMutex.RUnlock()
+
+	setKEVCatalog(ids, time.Now())
+
+	t.Cleanup(func() {
+		kevMutex.Lock()
+		kevIDs, kevUpdated = prev, prevAt
+		kevMutex.Unlock()
+	})
+}
+
+/* ---------------- CISA KEV ---------------- */
+
+func TestKEVFeedFlagsListedCVE(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"vulnerabilities": []map[string]string{{"cveID": "CVE-2024-0101"}, {"cveID": "CVE-2023-9999"}},
+		})
+	}))
+	defer srv.Close()
+
+	prev := kevFeedURL
+	kevFeedURL = srv.URL
+	t.Cleanup(func() { kevFeedURL = prev })
+
+	ids, err := fetchKEVCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKEVCatalog(t, ids...)
+
+	items := append([]CVE(nil), gateCVEs...)
+	markKnownExploited(items)
+
+	if !items[0].KnownExploited || items[1].KnownExploited {
+		t.Errorf("KnownExploited = %v/%v, want true for the listed CVE only", items[0].KnownExploited, items[1].KnownExploited)
+	}
+
+	if line := formatRagLine(items[0]); !strings.Contains(line, "[ACTIVELY EXPLOITED]") {
+		t.Errorf("RAG line %q not annotated", line)
+	}
+	if line := formatRagLine(items[1]); strings.Contains(line, "EXPLOITED") {
+		t.Errorf("RAG line %q annotated without KEV", line)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+/* ======================================================
+   🔥 EVENT LANGUAGE
+   ======================================================
+
+   Devices in non-English locales log in their own language.
+   The language is taken from Event.Language or detected
+   from the message, and non-English events get a prompt
+   line asking for the analysis in English anyway.
+*/
+
+const languageEnglish = "en"
+
+// languageNames maps the ISO 639-1 codes we detect to prompt names.
+var languageNames = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"pt": "Portuguese",
+	"it": "Italian",
+}
+
+// languageMarkers are common words, including device-log vocabulary,
+// that are unlikely to appear in an English message.
+var languageMarkers = map[string][]string{
+	"es": {"el", "la", "los", "las", "del", "en", "está", "esta", "interfaz", "caído", "caida", "caída", "enlace", "puerto", "conexión", "fallo", "sin", "por", "desconectado", "servidor", "memoria", "alta"},
+	"fr": {"le", "les", "est", "du", "une", "liaison", "panne", "hors", "connexion", "échec", "serveur", "mémoire", "élevée", "arrêté"},
+	"de": {"der", "das", "ist", "nicht", "und", "schnittstelle", "verbindung", "ausgefallen", "fehler", "getrennt", "speicher", "hoch", "unterbrochen"},
+	"pt": {"o", "os", "da", "está", "não", "enlace", "porta", "conexão", "falha", "caiu", "desconectado", "servidor", "memória"},
+	"it": {"il", "lo", "gli", "della", "è", "interfaccia", "collegamento", "porta", "connessione", "guasto", "errore", "disconnesso", "memoria"},
+}
+
+// minLanguageMarkers is how many marker words a message needs before
+// it is treated as non-English; short English messages share a few.
+const minLanguageMarkers = 2
+
+// detectLanguage guesses the ISO code of text, "en" unless another
+// language clearly wins.
+func detectLanguage(text string) string {
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	counts := map[string]int{}
+	for _, w := range words {
+		for lang, markers := range languageMarkers {
+			if containsString(markers, w) {
+				counts[lang]++
+			}
+		}
+	}
+
+	best, bestCount := languageEnglish, 0
+	for lang, n := range counts {
+		if n >= minLanguageMarkers && (n > bestCount || n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+
+	return best
+}
+
+// eventLanguage is the event's language name: Event.Language (a code or
+// a name) when set, otherwise the detected one when the
+// language_detection flag is on, otherwise "".
+func eventLanguage(event Event) string {
+
+	if forced := sanitizeMetadata(event.Language); forced != "" {
+		if name, ok := languageNames[strings.ToLower(forced)]; ok {
+			return name
+		}
+		return forced
+	}
+
+	if !FeatureEnabled(FlagLangDetect) {
+		return ""
+	}
+
+	return languageNames[detectLanguage(event.Message)]
+}
+
+// languageHint asks for an English analysis of a non-English message, or
+// renders nothing.
+func languageHint(event Event) string {
+
+	lang := eventLanguage(event)
+	if lang == "" || strings.EqualFold(lang, languageNames[languageEnglish]) {
+		return ""
+	}
+
+	return fmt.Sprintf("The event message is in %s; write every JSON value in English.\n", lang)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+/* ---------------- EVENT LANGUAGE ---------------- */
+
+func TestSpanishEventGetsEnglishAnalysis(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false, FlagLangDetect: true})
+
+	var prompt string
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		prompt = payload.Input
+		generationReply(`{"severity":"alta","explanation":"Interface Gi0/1 is down","recommended_action":"Check the cable"}`)(w, r)
+	})
+
+	w := postEvent(t, `{"type":"link_down","message":"La interfaz Gi0/1 está caída, enlace sin conexión"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	if !strings.Contains(prompt, "The event message is in Spanish; write every JSON value in English.") {
+		t.Errorf("prompt lacks the Spanish language hint:\n%s", prompt)
+	}
+
+	var got UnifiedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Severity != "high" || got.Explanation != "Interface Gi0/1 is down" {
+		t.Errorf("response = %+v, want high with the English explanation", got)
+	}
+}
+
+func TestEventLanguage(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagLangDetect: true})
+
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Message: "Interface Gi0/1 is down"}, "English"},
+		{Event{Message: "La interfaz Gi0/1 está caída"}, "Spanish"},
+		{Event{Message: "Die Schnittstelle ist ausgefallen"}, "German"},
+		{Event{Message: "Interface Gi0/1 is down", Language: "fr"}, "French"},
+	}
+
+	for _, tt := range tests {
+		if got := eventLanguage(tt.event); got != tt.want {
+			t.Errorf("eventLanguage(%+v) = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+
+	if hint := languageHint(Event{Message: "Interface Gi0/1 is down"}); hint != "" {
+		t.Errorf("English event got a language hint: %q", hint)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ---------------- LIFETIME COUNTERS ---------------- */
+
+var (
+	startedAt        = time.Now()
+	inFlightRequests atomic.Int64
+	eventsProcessed  atomic.Int64
+)
+
+// trackInFlight counts requests currently being served.
+func trackInFlight() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inFlightRequests.Add(1)
+		defer inFlightRequests.Add(-1)
+		c.Next()
+	}
+}
+
+/* ---------------- GRACEFUL SHUTDOWN ---------------- */
+
+// shutdownServer stops accepting connections, waits up to
+// SHUTDOWN_TIMEOUT (default 15s) for in-flight requests, cancels
+// background work and logs a shutdown report.
+func shutdownServer(srv *http.Server, cancelBackground context.CancelFunc) {
+
+	shuttingDown.Store(true)
+
+	inFlight := inFlightRequests.Load()
+
+	Logger.Printf("🛑 Shutting down (%d requests in flight)", inFlight)
+
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 15*time.Second))
+	defer cancel()
+
+	shutdownErr := srv.Shutdown(ctx)
+	cancelBackground()
+	closeResultSink()
+
+	logShutdownReport(inFlight, shutdownErr)
+}
+
+func logShutdownReport(inFlightAtStart int64, shutdownErr error) {
+
+	remaining := inFlightRequests.Load()
+
+	status := "clean"
+	if shutdownErr != nil {
+		status = shutdownErr.Error()
+	}
+
+	epssMutex.Lock()
+	epssSize := len(epssCache)
+	epssMutex.Unlock()
+
+	kevMutex.RLock()
+	kevSize := len(kevIDs)
+	kevMutex.RUnlock()
+
+	LogFields("📋 Shutdown report",
+		"status", status,
+		"uptime", time.Since(startedAt).Round(time.Second),
+		"in_flight_at_shutdown", inFlightAtStart,
+		"requests_drained", inFlightAtStart-remaining,
+		"requests_abandoned", remaining,
+		"events_processed", eventsProcessed.Load(),
+		"cve_cache_size", len(GetRecentCVEs()),
+		"kev_catalog_size", kevSize,
+		"epss_cache_size", epssSize,
+	)
+}
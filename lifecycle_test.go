@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+/* ---------------- SHUTDOWN REPORT ---------------- */
+
+func TestShutdownEmitsReport(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	go srv.Serve(ln)
+
+	t.Cleanup(func() { shuttingDown.Store(false) })
+
+	cancelled := false
+	out := captureLog(t, func() {
+		shutdownServer(srv, func() { cancelled = true })
+	})
+
+	if !cancelled {
+		t.Error("background work not cancelled")
+	}
+	if !shuttingDown.Load() {
+		t.Error("readiness not flipped")
+	}
+	if !containsAll(out, "Shutdown report", `status="clean"`, "requests_drained=", "events_processed=", "cve_cache_size=") {
+		t.Errorf("report missing or incomplete:\n%s", out)
+	}
+}
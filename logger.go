@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 var Logger *log.Logger
@@ -30,3 +32,49 @@ func InitLogger() {
 
 	Logger.Println("Logger initialized")
 }
+
+/* ---------------- FIELD LOGGING ---------------- */
+
+// High-volume fields dropped by default outside LOG_LEVEL=debug.
+var defaultDroppedLogFields = []string{"message", "prompt", "raw_output"}
+
+// droppedLogFields returns the set of field names to omit. LOG_DROP_FIELDS
+// (comma-separated) overrides the default; set it to "none" to keep all.
+func droppedLogFields() map[string]bool {
+
+	names := defaultDroppedLogFields
+
+	if raw := strings.TrimSpace(os.Getenv("LOG_DROP_FIELDS")); raw != "" {
+		names = strings.Split(raw, ",")
+	} else if strings.EqualFold(os.Getenv("LOG_LEVEL"), "debug") {
+		names = nil
+	}
+
+	out := map[string]bool{}
+	for _, n := range names {
+		if n = strings.TrimSpace(strings.ToLower(n)); n != "" && n != "none" {
+			out[n] = true
+		}
+	}
+
+	return out
+}
+
+// LogFields writes msg followed by key=value pairs, omitting dropped fields.
+func LogFields(msg string, kv ...interface{}) {
+
+	dropped := droppedLogFields()
+
+	var b strings.Builder
+	b.WriteString(msg)
+
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		if dropped[strings.ToLower(key)] {
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%q", key, fmt.Sprint(kv[i+1]))
+	}
+
+	_ = Logger.Output(2, b.String())
+}
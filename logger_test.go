@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// captureLog collects the log output of fn.
+func captureLog(t *testing.T, fn func()) string {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	prev := Logger
+	Logger = log.New(&buf, "", 0)
+	defer func() { Logger = prev }()
+
+	fn()
+
+	return buf.String()
+}
+
+/* ---------------- FIELD FILTERING ---------------- */
+
+func TestLogFieldsDropsHighVolumeFields(t *testing.T) {
+
+	t.Setenv("LOG_LEVEL", "info")
+	t.Setenv("LOG_DROP_FIELDS", "")
+
+	out := captureLog(t, func() {
+		LogFields("event", "type", "link_down", "severity", "high", "request_id", "r-1", "message", "secret body", "prompt", "long prompt")
+	})
+
+	if !containsAll(out, `type="link_down"`, `severity="high"`, `request_id="r-1"`) {
+		t.Errorf("kept fields missing: %s", out)
+	}
+	if strings.Contains(out, "secret body") || strings.Contains(out, "long prompt") {
+		t.Errorf("dropped fields logged: %s", out)
+	}
+}
+
+func TestLogFieldsOverrides(t *testing.T) {
+
+	t.Setenv("LOG_DROP_FIELDS", "Severity")
+	out := captureLog(t, func() { LogFields("event", "severity", "high", "message", "body") })
+
+	if strings.Contains(out, "severity=") || !strings.Contains(out, `message="body"`) {
+		t.Errorf("LOG_DROP_FIELDS=Severity: %s", out)
+	}
+
+	t.Setenv("LOG_DROP_FIELDS", "")
+	t.Setenv("LOG_LEVEL", "debug")
+	out = captureLog(t, func() { LogFields("event", "message", "body") })
+
+	if !strings.Contains(out, `message="body"`) {
+		t.Errorf("debug level dropped fields: %s", out)
+	}
+
+	t.Setenv("LOG_LEVEL", "info")
+	t.Setenv("LOG_DROP_FIELDS", "none")
+	out = captureLog(t, func() { LogFields("event", "message", "body") })
+
+	if !strings.Contains(out, `message="body"`) {
+		t.Errorf("LOG_DROP_FIELDS=none dropped fields: %s", out)
+	}
+}
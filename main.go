@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -27,68 +32,219 @@ func main() {
 	InitLogger()
 	Logger.Println("🚀 Agents API starting")
 
+	InitPromptTemplate()
+
+	if err := getWatsonConfig().Validate(); err != nil {
+		Logger.Fatalf("❌ Invalid Watsonx config: %v", err)
+	}
+
+	llm, err := InitLLMProvider()
+	if err != nil {
+		Logger.Fatalf("❌ LLM provider: %v", err)
+	}
+
+	/* ---------------- INIT TRACING ---------------- */
+
+	flushTraces, err := InitTracing(context.Background())
+	if err != nil {
+		Logger.Fatalf("❌ Tracing setup failed: %v", err)
+	}
+
 	/* =========================================================
 	   FORCE CVE INITIALIZATION (CRITICAL)
 	   ========================================================= */
 
 	Logger.Println("🌐 Initializing CVE cache...")
 
-	err := EnsureRecentNetworkCVEs()
+	err = EnsureRecentNetworkCVEs(context.Background())
 
 	if err != nil {
 		Logger.Printf("❌ CVE initialization FAILED: %v", err)
+
+		if err := CheckStartupCVEFreshness(); err != nil {
+			Logger.Fatalf("❌ Refusing to start: %v", err)
+		}
 	} else {
 		Logger.Println("✅ CVE cache initialized successfully")
 	}
 
 	/* =========================================================
 	   BACKGROUND REFRESH LOOP
-	   Checks every 5 minutes
+	   Checks every CVE_REFRESH_INTERVAL (default 5 minutes)
 	   Fetch occurs only if cache is stale (≤15 min policy)
 	   ========================================================= */
 
-	go func() {
+	bgCtx, cancelBackground := context.WithCancel(context.Background())
 
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
+	StartCVERefresher(bgCtx, envDuration("CVE_REFRESH_INTERVAL", 5*time.Minute))
 
-		for range ticker.C {
+	// Background alert retries stop at shutdown; the alerts are dead-lettered
+	StartAlertDelivery(bgCtx)
 
-			Logger.Println("🔄 Checking CVE cache freshness...")
+	if llm.Name() == providerWatsonx {
+		go CheckConfiguredModel(bgCtx)
+	}
 
-			if err := EnsureRecentNetworkCVEs(); err != nil {
-				Logger.Printf("⚠️ CVE refresh error: %v", err)
-				continue
-			}
+	/* ---------------- OPTIONAL FILE INGEST ---------------- */
 
-			Logger.Println("✅ CVE cache check complete")
+	if FeatureEnabled(FlagFileIngest) {
+		if err := StartFileIngest(bgCtx); err != nil {
+			Logger.Printf("❌ File ingest disabled: %v", err)
 		}
-	}()
+	}
+
+	/* ---------------- OPTIONAL KAFKA SOURCE ---------------- */
+
+	stopKafka := func() {}
+
+	if FeatureEnabled(FlagKafka) {
+		stop, err := StartKafkaIngest(bgCtx)
+		if err != nil {
+			Logger.Fatalf("❌ Kafka ingest: %v", err)
+		}
+		stopKafka = stop
+	}
+
+	/* ---------------- OPTIONAL gRPC API ---------------- */
+
+	stopGRPC := func() {}
+
+	if FeatureEnabled(FlagGRPC) {
+		stop, err := StartGRPCServer()
+		if err != nil {
+			Logger.Fatalf("❌ gRPC API: %v", err)
+		}
+		stopGRPC = stop
+	}
 
 	/* ---------------- GIN ROUTER ---------------- */
 
 	router := gin.Default()
+	router.Use(trackInFlight())
+
+	router.POST("/events", traceRequests(), observeEventDuration(), idempotent(), handleEvent)
+	router.POST("/events/stream", requireFeature(FlagStreaming), traceRequests(), observeEventDuration(), handleEventStream)
+	router.POST("/events/preview", handleEventPreview)
+	router.POST("/events/batch", requireFeature(FlagBatch), traceRequests(), observeEventDuration(), idempotent(), handleEventBatch)
+	router.GET("/health", handleHealth)
+	router.GET("/ready", handleReady)
+	router.GET("/live", handleLive)
+	router.GET("/flags", handleFlags)
+	router.GET("/metrics", handleMetrics())
+	router.GET("/stats", handleStats)
+	router.GET("/models", handleModels)
+
+	admin := router.Group("/admin", adminAuth())
+	admin.POST("/cache/purge", handleCachePurge)
+	admin.POST("/replay", handleAlertReplay)
+
+	debug := router.Group("/debug", adminAuth())
+	debug.GET("/recent", handleRecent)
+
+	/* ---------------- START SERVER ---------------- */
 
-	router.POST("/events", func(c *gin.Context) {
+	srv := &http.Server{Addr: ":9000", Handler: router}
 
-		var evt Event
+	go func() {
+		Logger.Println("🚀 Agents API running on :9000")
 
-		if err := c.ShouldBindJSON(&evt); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
-			return
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			Logger.Fatal("❌ Failed to start server:", err)
 		}
+	}()
 
-		result := DispatchEvent(evt)
-		c.JSON(http.StatusOK, result)
-	})
+	/* ---------------- GRACEFUL SHUTDOWN ---------------- */
 
-	/* ---------------- START SERVER ---------------- */
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
 
-	Logger.Println("🚀 Agents API running on :9000")
+	// Stop consuming before the HTTP server closes
+	stopKafka()
+	stopGRPC()
+	shutdownServer(srv, cancelBackground)
+	shutdownTracing(flushTraces)
+}
 
-	if err := router.Run(":9000"); err != nil {
-		Logger.Fatal("❌ Failed to start server:", err)
+/* ---------------- EVENT HANDLER ---------------- */
+
+func handleEvent(c *gin.Context) {
+
+	var evt Event
+
+	if err := c.ShouldBindJSON(&evt); err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidRequest, err.Error())
+		return
+	}
+
+	if err := validateEvent(evt); err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidEvent, err.Error())
+		return
+	}
+
+	result, err := DispatchCorrelated(c.Request.Context(), evt)
+	applyRawOutputPolicy(c, &result)
+
+	if err != nil {
+		respondDegraded(c, err, result)
+		return
 	}
+
+	signResponse(&result)
+
+	if result.Signature != "" {
+		c.Header("X-Signature", result.Signature)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+/* ---------------- STREAMING HANDLER ---------------- */
+
+// handleEventStream sends "chunk" server-sent events with generated text
+// as it arrives, then a final "result" event with the parsed response.
+func handleEventStream(c *gin.Context) {
+
+	var evt Event
+
+	if err := c.ShouldBindJSON(&evt); err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidRequest, err.Error())
+		return
+	}
+
+	if err := validateEvent(evt); err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidEvent, err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	chunks := make(chan string, 16)
+	done := make(chan UnifiedResponse, 1)
+
+	go func() {
+		result := DispatchEventStream(ctx, evt, func(delta string) {
+			select {
+			case chunks <- delta:
+			case <-ctx.Done():
+			}
+		})
+
+		done <- result
+		close(chunks)
+	}()
+
+	c.Stream(func(w io.Writer) bool {
+
+		if delta, ok := <-chunks; ok {
+			c.SSEvent("chunk", delta)
+			return true
+		}
+
+		result := <-done
+		applyRawOutputPolicy(c, &result)
+		signResponse(&result)
+
+		c.SSEvent("result", result)
+		return false
+	})
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMain keeps tests off the network and the log file: KEV and EPSS
+// lookups are off unless a test turns them on.
+func TestMain(m *testing.M) {
+
+	Logger = log.New(io.Discard, "", 0)
+	gin.SetMode(gin.TestMode)
+
+	os.Setenv("FEATURE_FLAGS", "kev=false,epss=false")
+
+	os.Exit(m.Run())
+}
+
+/* ---------------- HELPERS ---------------- */
+
+// setFlags overrides feature flags for the test.
+func setFlags(t *testing.T, flags map[string]bool) {
+
+	t.Helper()
+
+	prev := activeFeatureFlags()
+
+	next := make(map[string]bool, len(prev))
+	for k, v := range prev {
+		next[k] = v
+	}
+	for k, v := range flags {
+		next[k] = v
+	}
+
+	featureFlags = next
+	t.Cleanup(func() { featureFlags = prev })
+}
+
+// useWatsonConfig installs cfg as the loaded config with fresh key
+// rotation, token and result caches, restoring the previous one after
+// the test.
+func useWatsonConfig(t *testing.T, cfg WatsonConfig) {
+
+	t.Helper()
+
+	getWatsonConfig()
+	prev := watsonConfig
+
+	watsonConfig = cfg
+	resetWatsonState()
+
+	t.Cleanup(func() {
+		watsonConfig = prev
+		resetWatsonState()
+	})
+}
+
+func resetWatsonState() {
+
+	keyMutex.Lock()
+	apiKeys, keyWeights, keyCurrent = nil, nil, nil
+	keyBadTill = map[string]time.Time{}
+	keyMutex.Unlock()
+
+	tokenMutex.Lock()
+	tokenCache = map[string]tokenEntry{}
+	tokenMutex.Unlock()
+
+	PurgeResultCache()
+}
+
+// testWatsonConfig is a valid config with one key and no retry delays.
+func testWatsonConfig(baseURL string) WatsonConfig {
+
+	return WatsonConfig{
+		APIKeys:          []string{"test-key"},
+		KeyWeights:       []int{1},
+		ProjectID:        "project",
+		ModelID:          "ibm/granite-3-8b-instruct",
+		MLEndpointBase:   baseURL,
+		IAMURL:           baseURL + "/identity/token",
+		Temperature:      0.1,
+		MaxNewTokens:     400,
+		MaxContextTokens: 8192,
+		ParseRetries:     1,
+		TemperatureStep:  0.2,
+		MaxTemperature:   0.3,
+		MaxRetries:       3,
+	}
+}
+
+// stubWatsonx starts a server answering IAM token requests itself and
+// passing ML API requests to ml, and installs a config pointing at it.
+func stubWatsonx(t *testing.T, ml http.HandlerFunc) (*httptest.Server, WatsonConfig) {
+
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/identity/token" {
+			writeJSON(w, map[string]interface{}{"access_token": "token", "expires_in": 3600})
+			return
+		}
+		ml(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := testWatsonConfig(srv.URL)
+	useWatsonConfig(t, cfg)
+
+	return srv, cfg
+}
+
+// generationReply answers like text/generation with one result per text.
+func generationReply(texts ...string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		results := make([]map[string]interface{}, 0, len(texts))
+		for _, text := range texts {
+			results = append(results, map[string]interface{}{
+				"generated_text":        text,
+				"input_token_count":     100,
+				"generated_token_count": 20,
+			})
+		}
+
+		writeJSON(w, map[string]interface{}{"results": results})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// useRecentCVEs replaces the in-memory CVE list for the test.
+func useRecentCVEs(t *testing.T, items []CVE) {
+
+	t.Helper()
+
+	cveMutex.RLock()
+	prev, prevIndex, prevAt := recentCVEs, recentCVEIndex, cveUpdatedAt
+	cveMutex.RUnlock()
+
+	setRecentCVEs(items, time.Now())
+
+	t.Cleanup(func() {
+		cveMutex.Lock()
+		recentCVEs, recentCVEIndex, cveUpdatedAt = prev, prevIndex, prevAt
+		cveMutex.Unlock()
+	})
+}
+
+func containsAll(s string, parts ...string) bool {
+	for _, p := range parts {
+		if !strings.Contains(s, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// postEvent sends body to POST /events through the production middleware.
+func postEvent(t *testing.T, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return postEventTo(t, "/events", body, header...)
+}
+
+// postEventTo is postEvent with a query string, e.g. "/events?debug_raw=true".
+func postEventTo(t *testing.T, target, body string, header ...string) *httptest.ResponseRecorder {
+
+	t.Helper()
+
+	router := gin.New()
+	router.POST("/events", idempotent(), handleEvent)
+
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/* ======================================================
+   🔥 METRICS
+   ====================================================== */
+
+// Token buckets from 64 to 8192 (doubling)
+var tokenBuckets = prometheus.ExponentialBuckets(64, 2, 8)
+
+var (
+	ragBlockTokens = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "aicore_rag_block_tokens",
+		Help:    "Estimated token count of each RAG block built.",
+		Buckets: tokenBuckets,
+	})
+
+	promptTokens = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "aicore_prompt_tokens",
+		Help:    "Estimated token count of each full prompt sent to the model.",
+		Buckets: tokenBuckets,
+	})
+)
+
+var (
+	watsonRequestSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "aicore_watson_request_seconds",
+		Help:    "Latency of Watsonx generation requests, retries included.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	iamTokenSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "aicore_iam_token_seconds",
+		Help:    "Latency of IAM token fetches (cache hits excluded).",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	cveLookupSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "aicore_cve_lookup_seconds",
+		Help:    "Time spent selecting relevant CVEs for an event.",
+		Buckets: []float64{.0005, .001, .005, .01, .05, .1, .5, 1},
+	})
+
+	eventSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aicore_event_processing_seconds",
+		Help:    "Overall processing time of event endpoints.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+
+	watsonFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aicore_watson_failures_total",
+		Help: "Failed Watsonx analyses by reason.",
+	}, []string{"reason"})
+)
+
+// observeSince records the time elapsed since start; use with defer.
+func observeSince(h prometheus.Observer, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// observeEventDuration times the wrapped event route.
+func observeEventDuration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer observeSince(eventSeconds.WithLabelValues(c.FullPath()), time.Now())
+		c.Next()
+	}
+}
+
+const failureParse = "parse_failure"
+
+// failureReason buckets a Watson error for aicore_watson_failures_total.
+func failureReason(err error) string {
+
+	var se *statusError
+	if errors.As(err, &se) {
+		switch {
+		case se.Code == 429:
+			return "rate_limited"
+		case se.Code == 401 || se.Code == 403 || se.Service == "IAM auth":
+			return "auth"
+		}
+		return "http_error"
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout() {
+		return "timeout"
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+
+	return "other"
+}
+
+// estimateTokens is a cheap approximation (~4 characters per token for
+// English text); good enough to size RAG_MAX_CVES and truncation limits.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+func handleMetrics() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sampleCount sums the observations (histograms) or values (counters) of
+// the named metric whose labels include match.
+func sampleCount(t *testing.T, name string, match map[string]string) float64 {
+
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0.0
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			for k, v := range match {
+				if labels[k] != v {
+					continue metrics
+				}
+			}
+
+			switch {
+			case m.GetHistogram() != nil:
+				total += float64(m.GetHistogram().GetSampleCount())
+			case m.GetCounter() != nil:
+				total += m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return total
+}
+
+/* ---------------- PROMETHEUS METRICS ---------------- */
+
+func TestMetricsAfterOneAnalysis(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: true})
+	useRecentCVEs(t, nil)
+	stubWatsonx(t, generationReply(`{"severity":"low","explanation":"x","recommended_action":"y"}`))
+
+	names := []string{"aicore_watson_request_seconds", "aicore_iam_token_seconds", "aicore_cve_lookup_seconds"}
+	before := map[string]float64{}
+	for _, n := range names {
+		before[n] = sampleCount(t, n, nil)
+	}
+	eventsBefore := sampleCount(t, "aicore_event_processing_seconds", map[string]string{"route": "/events"})
+
+	router := gin.New()
+	router.POST("/events", observeEventDuration(), handleEvent)
+	router.GET("/metrics", handleMetrics())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"type":"link_down","message":"Gi0/1 down"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	for _, n := range names {
+		if got := sampleCount(t, n, nil) - before[n]; got != 1 {
+			t.Errorf("%s: %v new observations, want 1", n, got)
+		}
+	}
+	if got := sampleCount(t, "aicore_event_processing_seconds", map[string]string{"route": "/events"}) - eventsBefore; got != 1 {
+		t.Errorf("event processing: %v new observations, want 1", got)
+	}
+
+	scrape := httptest.NewRecorder()
+	router.ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !containsAll(scrape.Body.String(), append(names, "aicore_event_processing_seconds_bucket")...) {
+		t.Error("/metrics is missing the latency histograms")
+	}
+}
+
+func TestFailureReasonCounter(t *testing.T) {
+
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	before := sampleCount(t, "aicore_watson_failures_total", map[string]string{"reason": "rate_limited"})
+
+	cfg := testWatsonConfig(getWatsonConfig().MLEndpointBase)
+	cfg.MaxRetries = 0
+	useWatsonConfig(t, cfg)
+
+	if _, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, ""); err == nil {
+		t.Fatal("want the 429")
+	}
+
+	if got := sampleCount(t, "aicore_watson_failures_total", map[string]string{"reason": "rate_limited"}) - before; got != 1 {
+		t.Errorf("rate_limited failures: +%v, want +1", got)
+	}
+}
@@ -3,10 +3,95 @@ package main
 type Event struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
+
+	// Optional metadata passed into the prompt when present
+	SourceHost string `json:"source_host,omitempty"`
+	SourceIP   string `json:"source_ip,omitempty"`
+	Category   string `json:"category,omitempty"`
+
+	// Mode is "full" (default), "classify" for severity-only triage or
+	// "remediate" for remediation steps given an external Severity
+	Mode string `json:"mode,omitempty"`
+
+	// Severity is an upstream pre-classification: a hint the model may
+	// correct in full/classify mode, authoritative in remediate mode
+	Severity string `json:"severity,omitempty"`
+
+	// ModelID overrides WATSONX_MODEL_ID; must be in WATSONX_ALLOWED_MODELS
+	ModelID string `json:"model_id,omitempty"`
+
+	// Language of Message ("es" or "Spanish"); detected when empty
+	Language string `json:"language,omitempty"`
+
+	// AssetCriticality is how important the affected asset is: low,
+	// medium or high (see applyAssetCriticality)
+	AssetCriticality string `json:"asset_criticality,omitempty"`
 }
 
 type UnifiedResponse struct {
-	Severity          string `json:"severity"`
-	Explanation       string `json:"explanation"`
-	RecommendedAction string `json:"recommended_action"`
+	Severity          string   `json:"severity"`
+	Explanation       string   `json:"explanation"`
+	RecommendedAction string   `json:"recommended_action"`
+	RemediationSteps  []string `json:"remediation_steps,omitempty"`
+	RootCause         string   `json:"root_cause,omitempty"`
+	Impact            string   `json:"impact,omitempty"`
+
+	// Source is "rules" when the rule-based fallback produced the result
+	// because the model was unavailable
+	Source string `json:"source,omitempty"`
+
+	// SeverityLevel is Severity as a number on SEVERITY_SCALE for
+	// consumers that route or sort numerically (absent for unknown)
+	SeverityLevel int `json:"severity_level,omitempty"`
+
+	// ModelSeverity is the model's own severity when asset criticality
+	// raised Severity above it, a KEV/high-EPSS CVE escalated it, or it
+	// was the severity an injected instruction demanded and the rules
+	// overrode it
+	ModelSeverity string `json:"model_severity,omitempty"`
+
+	// Confidence is the model's certainty in Severity, 0–100
+	Confidence int `json:"confidence"`
+
+	// CorrelatedEvents is how many events were analyzed together as one
+	// incident (see correlation.go); absent for single events
+	CorrelatedEvents int `json:"correlated_events,omitempty"`
+
+	// RequiresReview marks results below REVIEW_BELOW_CONFIDENCE for an
+	// analyst rather than automatic action
+	RequiresReview bool `json:"requires_review,omitempty"`
+
+	// SeverityHint echoes Event.Severity; HintAgreed tells whether the
+	// model's severity matched it
+	SeverityHint string `json:"severity_hint,omitempty"`
+	HintAgreed   *bool  `json:"hint_agreed,omitempty"`
+
+	// Usage is the token count spent on this analysis (absent for cache
+	// hits and fallbacks)
+	Usage *TokenUsage `json:"usage,omitempty"`
+
+	// Truncated means the model output was cut off (max_new_tokens) and
+	// only the fields before the cut were parsed
+	Truncated bool `json:"truncated,omitempty"`
+
+	// AlertID is the alert receiver's id for the webhook alert of this
+	// result, with ALERT_SYNC on and a receiver that returns one
+	AlertID string `json:"alert_id,omitempty"`
+
+	// Fingerprint identifies equivalent events (see eventFingerprint)
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// AnalyzedAt is the RFC3339 UTC time the analysis completed (when the
+	// model answer was parsed), not when the request was received
+	AnalyzedAt string `json:"analyzed_at,omitempty"`
+
+	// RAGContext lists the CVEs given to the model, only returned with
+	// INCLUDE_RAG_CONTEXT=true
+	RAGContext *RAGContext `json:"rag_context,omitempty"`
+
+	// RawOutput is the model's generated_text, only returned with ?debug_raw=true
+	RawOutput string `json:"raw_output,omitempty"`
+
+	// Signature is the HMAC-SHA256 of the response (see signResponse)
+	Signature string `json:"signature,omitempty"`
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,25 +13,37 @@ import (
 /* ---------------- NVD RESPONSE STRUCT ---------------- */
 
 type nvdResponse struct {
-	Vulnerabilities []struct {
-		Cve struct {
-			ID        string `json:"id"`
-			Published string `json:"published"`
+	ResultsPerPage  int                `json:"resultsPerPage"`
+	StartIndex      int                `json:"startIndex"`
+	TotalResults    int                `json:"totalResults"`
+	Vulnerabilities []nvdVulnerability `json:"vulnerabilities"`
+}
+
+type nvdVulnerability struct {
+	Cve struct {
+		ID        string `json:"id"`
+		Published string `json:"published"`
+
+		Descriptions []struct {
+			Lang  string `json:"lang"`
+			Value string `json:"value"`
+		} `json:"descriptions"`
+
+		Metrics struct {
+			CvssMetricV31 []metric `json:"cvssMetricV31"`
+			CvssMetricV30 []metric `json:"cvssMetricV30"`
+			CvssMetricV2  []metric `json:"cvssMetricV2"`
+		} `json:"metrics"`
 
-			Descriptions []struct {
+		Weaknesses []struct {
+			Description []struct {
 				Lang  string `json:"lang"`
 				Value string `json:"value"`
-			} `json:"descriptions"`
+			} `json:"description"`
+		} `json:"weaknesses"`
 
-			Metrics struct {
-				CvssMetricV31 []metric `json:"cvssMetricV31"`
-				CvssMetricV30 []metric `json:"cvssMetricV30"`
-				CvssMetricV2  []metric `json:"cvssMetricV2"`
-			} `json:"metrics"`
-
-			Configurations interface{} `json:"configurations"` // 🔥 SAFE
-		} `json:"cve"`
-	} `json:"vulnerabilities"`
+		Configurations []nvdConfiguration `json:"configurations"`
+	} `json:"cve"`
 }
 
 type metric struct {
@@ -41,40 +54,163 @@ type metric struct {
 
 /* ---------------- FETCH FROM NVD ---------------- */
 
-func fetchRecentCVEsFromNVD(days int) ([]CVE, error) {
+// NVD asks unauthenticated clients to wait 6 seconds between requests
+const nvdPublicPageDelay = 6 * time.Second
+
+var nvdCVEsURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+
+// nvdPartialError reports a fetch that failed after some pages arrived;
+// fetchRecentCVEsFromNVD still returns those pages' CVEs.
+type nvdPartialError struct {
+	Fetched int
+	Total   int
+	Err     error
+}
+
+func (e *nvdPartialError) Error() string {
+	return fmt.Sprintf("NVD fetch incomplete (%d of %d CVEs): %v", e.Fetched, e.Total, e.Err)
+}
+
+func (e *nvdPartialError) Unwrap() error { return e.Err }
+
+// nvdRetryPolicy reads NVD_MAX_RETRIES (default 3) and
+// NVD_RETRY_BASE_DELAY (default 2s); 5xx, 429 and timeouts are retried.
+func nvdRetryPolicy() retryPolicy {
+	return retryPolicy{
+		MaxRetries:    envInt("NVD_MAX_RETRIES", 3),
+		BaseDelay:     envDuration("NVD_RETRY_BASE_DELAY", 2*time.Second),
+		RetryTimeouts: true,
+	}
+}
+
+// fetchRecentCVEsFromNVD returns the CVEs published in the last days.
+func fetchRecentCVEsFromNVD(ctx context.Context, days int) ([]CVE, error) {
 
 	end := time.Now().UTC()
 	start := end.AddDate(0, 0, -days)
 
-	url := fmt.Sprintf(
-		"https://services.nvd.nist.gov/rest/json/cves/2.0?pubStartDate=%s&pubEndDate=%s",
+	return fetchNVDCVEs(ctx, fmt.Sprintf(
+		"%s?pubStartDate=%s&pubEndDate=%s",
+		nvdCVEsURL,
 		start.Format(time.RFC3339),
 		end.Format(time.RFC3339),
-	)
+	))
+}
+
+// fetchModifiedCVEsFromNVD returns the CVEs published or changed since
+// since (at most 120 days ago, like publication windows).
+func fetchModifiedCVEsFromNVD(ctx context.Context, since time.Time) ([]CVE, error) {
+
+	return fetchNVDCVEs(ctx, fmt.Sprintf(
+		"%s?lastModStartDate=%s&lastModEndDate=%s",
+		nvdCVEsURL,
+		since.UTC().Format(time.RFC3339),
+		time.Now().UTC().Format(time.RFC3339),
+	))
+}
+
+// fetchNVDCVEs pages through the query in baseURL. Cancelling ctx stops
+// the page requests, their retries and the wait between pages.
+func fetchNVDCVEs(ctx context.Context, baseURL string) ([]CVE, error) {
+
+	client := newHTTPClient(30 * time.Second)
+	apiKey := os.Getenv("NVD_API_KEY")
+
+	var vulns []nvdVulnerability
+	total := 0
+
+	for startIndex := 0; ; {
+
+		page, err := fetchNVDPage(ctx, client, baseURL, apiKey, startIndex)
+		if err != nil {
+
+			// A failed later page keeps what already arrived
+			if len(vulns) == 0 {
+				return nil, err
+			}
 
-	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	req.Header.Set("User-Agent", "ai-core/1.0")
+			LogFields("⚠️ NVD page failed — keeping pages already fetched",
+				"fetched", len(vulns),
+				"total", total,
+				"error", err,
+			)
 
-	if key := os.Getenv("NVD_API_KEY"); key != "" {
-		req.Header.Set("apiKey", key)
+			return parseNVDVulnerabilities(vulns), &nvdPartialError{Fetched: len(vulns), Total: total, Err: err}
+		}
+
+		total = page.TotalResults
+
+		vulns = append(vulns, page.Vulnerabilities...)
+		startIndex = page.StartIndex + len(page.Vulnerabilities)
+
+		if len(page.Vulnerabilities) == 0 || startIndex >= page.TotalResults {
+			break
+		}
+
+		LogFields("📄 NVD page fetched",
+			"fetched", startIndex,
+			"total", page.TotalResults,
+		)
+
+		if apiKey == "" {
+			select {
+			case <-ctx.Done():
+				return parseNVDVulnerabilities(vulns), &nvdPartialError{Fetched: len(vulns), Total: total, Err: ctx.Err()}
+			case <-time.After(nvdPublicPageDelay):
+			}
+		}
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	return parseNVDVulnerabilities(vulns), nil
+}
+
+func fetchNVDPage(ctx context.Context, client *http.Client, baseURL, apiKey string, startIndex int) (*nvdResponse, error) {
+
+	url := fmt.Sprintf("%s&startIndex=%d", baseURL, startIndex)
+
+	resp, err := doWithRetry(ctx, client, nvdRetryPolicy(), "NVD", func() (*http.Request, error) {
 
-	resp, err := client.Do(req)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("User-Agent", "ai-core/1.0")
+
+		if apiKey != "" {
+			req.Header.Set("apiKey", apiKey)
+		}
+
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NVD request failed: %s", resp.Status)
+	}
+
 	var result nvdResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
-	items := make([]CVE, 0, len(result.Vulnerabilities))
+	return &result, nil
+}
+
+/* ---------------- NVD PARSING ---------------- */
+
+func parseNVDVulnerabilities(vulns []nvdVulnerability) []CVE {
+
+	items := make([]CVE, 0, len(vulns))
+	seen := make(map[string]int, len(vulns))
+
+	vendorOverrides := envMap("CVE_VENDOR_OVERRIDES")
+	productOverrides := envMap("CVE_PRODUCT_OVERRIDES")
 
-	for _, v := range result.Vulnerabilities {
+	for _, v := range vulns {
 
 		item := CVE{
 			ID:        v.Cve.ID,
@@ -95,45 +231,191 @@ func fetchRecentCVEsFromNVD(days int) ([]CVE, error) {
 		switch {
 		case len(v.Cve.Metrics.CvssMetricV31) > 0:
 			item.CVSSScore = v.Cve.Metrics.CvssMetricV31[0].CvssData.BaseScore
+			item.HasScore = true
 		case len(v.Cve.Metrics.CvssMetricV30) > 0:
 			item.CVSSScore = v.Cve.Metrics.CvssMetricV30[0].CvssData.BaseScore
+			item.HasScore = true
 		case len(v.Cve.Metrics.CvssMetricV2) > 0:
 			item.CVSSScore = v.Cve.Metrics.CvssMetricV2[0].CvssData.BaseScore
+			item.HasScore = true
+		}
+
+		/* -------- CWE Weakness Types -------- */
+
+		for _, w := range v.Cve.Weaknesses {
+			for _, d := range w.Description {
+				if isCWEID(d.Value) && !containsString(item.CWEs, d.Value) {
+					item.CWEs = append(item.CWEs, d.Value)
+				}
+			}
 		}
 
 		/* -------- Extract Vendor/Product from CPE -------- */
 
 		extractVendorProduct(&item, v.Cve.Configurations)
+		applyCPEOverrides(&item, vendorOverrides, productOverrides)
 
+		/* -------- Dedup by ID (prefer the better record) -------- */
+
+		if i, ok := seen[item.ID]; ok {
+			if betterCVE(item, items[i]) {
+				items[i] = item
+			}
+			continue
+		}
+
+		seen[item.ID] = len(items)
 		items = append(items, item)
 	}
 
-	return items, nil
+	return items
+}
+
+/* ---------------- CWE HELPERS ---------------- */
+
+// isCWEID skips NVD placeholders such as "NVD-CWE-Other" and
+// "NVD-CWE-noinfo", which carry no attack-class information.
+func isCWEID(s string) bool {
+	return strings.HasPrefix(s, "CWE-")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 /* ---------------- CPE PARSER ---------------- */
 
-func extractVendorProduct(item *CVE, cfg interface{}) {
+type nvdConfiguration struct {
+	Nodes []nvdNode `json:"nodes"`
+}
 
-	data, err := json.Marshal(cfg)
-	if err != nil {
-		return
+type nvdNode struct {
+	CpeMatch []struct {
+		Vulnerable bool   `json:"vulnerable"`
+		Criteria   string `json:"criteria"`
+	} `json:"cpeMatch"`
+
+	// Older feeds nest nodes under children
+	Children []nvdNode `json:"children"`
+}
+
+// extractVendorProduct walks every configuration node and records each
+// distinct vendor and product from the CPE criteria, vulnerable entries
+// first. Vendor/Product keep the first of each for older callers.
+func extractVendorProduct(item *CVE, cfgs []nvdConfiguration) {
+
+	var vulnerable, other []string
+
+	var walk func(nodes []nvdNode)
+	walk = func(nodes []nvdNode) {
+		for _, n := range nodes {
+			for _, m := range n.CpeMatch {
+				if m.Vulnerable {
+					vulnerable = append(vulnerable, m.Criteria)
+				} else {
+					other = append(other, m.Criteria)
+				}
+			}
+			walk(n.Children)
+		}
+	}
+
+	for _, cfg := range cfgs {
+		walk(cfg.Nodes)
 	}
 
-	text := string(data)
+	for _, cpe := range append(vulnerable, other...) {
+
+		vendor, product, ok := parseCPE(cpe)
+		if !ok {
+			continue
+		}
 
-	// Find first CPE string
-	idx := strings.Index(text, "cpe:2.3:")
-	if idx == -1 {
-		return
+		if !containsString(item.Vendors, vendor) {
+			item.Vendors = append(item.Vendors, vendor)
+		}
+		if !containsString(item.Products, product) {
+			item.Products = append(item.Products, product)
+		}
 	}
 
-	cpe := text[idx:]
+	if len(item.Vendors) > 0 {
+		item.Vendor = item.Vendors[0]
+	}
+	if len(item.Products) > 0 {
+		item.Product = item.Products[0]
+	}
+}
+
+// parseCPE returns the vendor and product of a CPE 2.3 formatted string
+// (cpe:2.3:part:vendor:product:version:...), honoring "\:" escapes.
+func parseCPE(cpe string) (vendor, product string, ok bool) {
 
-	parts := strings.Split(cpe, ":")
+	if !strings.HasPrefix(cpe, "cpe:2.3:") {
+		return "", "", false
+	}
 
-	if len(parts) >= 5 {
-		item.Vendor = parts[3]
-		item.Product = parts[4]
+	var fields []string
+	var cur strings.Builder
+
+	for i := 0; i < len(cpe); i++ {
+		switch {
+		case cpe[i] == '\\' && i+1 < len(cpe):
+			i++
+			cur.WriteByte(cpe[i])
+		case cpe[i] == ':':
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(cpe[i])
+		}
+	}
+	fields = append(fields, cur.String())
+
+	if len(fields) < 5 || fields[3] == "" || fields[3] == "*" || fields[4] == "" || fields[4] == "*" {
+		return "", "", false
+	}
+
+	return fields[3], fields[4], true
+}
+
+/* ---------------- CPE OVERRIDES ---------------- */
+
+// applyCPEOverrides rewrites known-bad NVD vendor/product strings to
+// canonical ones, e.g. CVE_VENDOR_OVERRIDES=cisco_systems=cisco.
+func applyCPEOverrides(item *CVE, vendors, products map[string]string) {
+
+	for i, v := range item.Vendors {
+		if to, ok := vendors[strings.ToLower(v)]; ok && to != v {
+			LogFields("🔁 Vendor override",
+				"cve", item.ID,
+				"vendor", v,
+				"to", to,
+			)
+			item.Vendors[i] = to
+		}
+	}
+
+	for i, p := range item.Products {
+		if to, ok := products[strings.ToLower(p)]; ok && to != p {
+			LogFields("🔁 Product override",
+				"cve", item.ID,
+				"product", p,
+				"to", to,
+			)
+			item.Products[i] = to
+		}
+	}
+
+	if len(item.Vendors) > 0 {
+		item.Vendor = item.Vendors[0]
+	}
+	if len(item.Products) > 0 {
+		item.Product = item.Products[0]
 	}
 }
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseNVDPayload parses the vulnerabilities of an NVD 2.0 response body.
+func parseNVDPayload(t *testing.T, body string) []CVE {
+
+	t.Helper()
+
+	var res nvdResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	return parseNVDVulnerabilities(res.Vulnerabilities)
+}
+
+/* ---------------- CWE WEAKNESSES ---------------- */
+
+const nvdWeaknessPayload = `{"vulnerabilities":[{"cve":{
+	"id":"CVE-2024-1000",
+	"published":"2024-05-01T10:00:00.000",
+	"descriptions":[{"lang":"en","value":"Buffer overflow in the web UI."}],
+	"weaknesses":[
+		{"source":"nvd@nist.gov","type":"Primary","description":[{"lang":"en","value":"CWE-787"},{"lang":"en","value":"NVD-CWE-Other"}]},
+		{"source":"psirt@vendor.com","type":"Secondary","description":[{"lang":"en","value":"CWE-120"},{"lang":"en","value":"CWE-787"}]}
+	]
+}}]}`
+
+func TestParseNVDWeaknesses(t *testing.T) {
+
+	items := parseNVDPayload(t, nvdWeaknessPayload)
+	if len(items) != 1 {
+		t.Fatalf("items = %d, want 1", len(items))
+	}
+
+	want := []string{"CWE-787", "CWE-120"}
+	if !reflect.DeepEqual(items[0].CWEs, want) {
+		t.Errorf("CWEs = %v, want %v (placeholders skipped, duplicates once)", items[0].CWEs, want)
+	}
+}
+
+func TestParseNVDWithoutWeaknesses(t *testing.T) {
+
+	items := parseNVDPayload(t, `{"vulnerabilities":[{"cve":{"id":"CVE-2024-1001"}}]}`)
+
+	if len(items) != 1 || items[0].CWEs != nil {
+		t.Errorf("got %+v, want one CVE without CWEs", items)
+	}
+}
+
+func TestRagLineCWEBehindFlag(t *testing.T) {
+
+	c := CVE{ID: "CVE-2024-1000", Vendor: "cisco", Product: "ios", CVSSScore: 9.8, HasScore: true, CWEs: []string{"CWE-787"}}
+
+	setFlags(t, map[string]bool{FlagRAGCWE: false})
+	if strings.Contains(formatRagLine(c), "CWE-787") {
+		t.Error("CWE included with rag_cwe off")
+	}
+
+	setFlags(t, map[string]bool{FlagRAGCWE: true})
+	if !strings.Contains(formatRagLine(c), "CWE-787") {
+		t.Error("CWE missing with rag_cwe on")
+	}
+}
+
+/* ---------------- DUPLICATE IDS ---------------- */
+
+func TestParseNVDDeduplicatesIDs(t *testing.T) {
+
+	items := parseNVDPayload(t, `{"vulnerabilities":[
+		{"cve":{"id":"CVE-2024-2000"}},
+		{"cve":{"id":"CVE-2024-2001"}},
+		{"cve":{"id":"CVE-2024-2000","metrics":{"cvssMetricV31":[{"cvssData":{"baseScore":7.5}}]}}}
+	]}`)
+
+	if len(items) != 2 {
+		t.Fatalf("items = %d, want 2", len(items))
+	}
+	if items[0].ID != "CVE-2024-2000" || items[0].CVSSScore != 7.5 {
+		t.Errorf("got %+v, want the scored duplicate in the first slot", items[0])
+	}
+}
+
+/* ---------------- VENDOR OVERRIDES ---------------- */
+
+const nvdCiscoSystemsPayload = `{"vulnerabilities":[{"cve":{
+	"id":"CVE-2024-3000",
+	"configurations":[{"nodes":[{"cpeMatch":[
+		{"vulnerable":true,"criteria":"cpe:2.3:o:cisco_systems:ios_xe_software:17.9:*:*:*:*:*:*:*"}
+	]}]}]
+}}]}`
+
+func TestParseNVDAppliesOverrides(t *testing.T) {
+
+	t.Setenv("CVE_VENDOR_OVERRIDES", "Cisco_Systems=cisco")
+	t.Setenv("CVE_PRODUCT_OVERRIDES", "ios_xe_software=ios_xe")
+
+	items := parseNVDPayload(t, nvdCiscoSystemsPayload)
+	if len(items) != 1 {
+		t.Fatalf("items = %d, want 1", len(items))
+	}
+
+	got := items[0]
+	if got.Vendor != "cisco" || got.Product != "ios_xe" ||
+		!reflect.DeepEqual(got.Vendors, []string{"cisco"}) || !reflect.DeepEqual(got.Products, []string{"ios_xe"}) {
+		t.Errorf("got %s/%s (%v/%v), want cisco/ios_xe", got.Vendor, got.Product, got.Vendors, got.Products)
+	}
+}
+
+func TestParseNVDWithoutOverrides(t *testing.T) {
+
+	t.Setenv("CVE_VENDOR_OVERRIDES", "")
+	t.Setenv("CVE_PRODUCT_OVERRIDES", "")
+
+	items := parseNVDPayload(t, nvdCiscoSystemsPayload)
+	if len(items) != 1 || items[0].Vendor != "cisco_systems" || items[0].Product != "ios_xe_software" {
+		t.Errorf("got %+v, want the CPE values unchanged", items)
+	}
+}
+
+/* ---------------- CVSS 0.0 VS MISSING ---------------- */
+
+func TestZeroScoreVersusUnscored(t *testing.T) {
+
+	items := parseNVDPayload(t, `{"vulnerabilities":[
+		{"cve":{"id":"CVE-2024-4000","metrics":{"cvssMetricV31":[{"cvssData":{"baseScore":0.0}}]}}},
+		{"cve":{"id":"CVE-2024-4001"}}
+	]}`)
+
+	if len(items) != 2 {
+		t.Fatalf("items = %d, want 2", len(items))
+	}
+	if !items[0].HasScore || items[1].HasScore {
+		t.Errorf("HasScore = %v/%v, want true/false", items[0].HasScore, items[1].HasScore)
+	}
+
+	if line := formatRagLine(items[0]); !strings.Contains(line, "CVSS 0.0") {
+		t.Errorf("scored 0.0 rendered as %q", line)
+	}
+	if line := formatRagLine(items[1]); !strings.Contains(line, "CVSS N/A") {
+		t.Errorf("unscored rendered as %q", line)
+	}
+}
+
+/* ---------------- CPE CONFIGURATION NODES ---------------- */
+
+// Trimmed from the NVD record of CVE-2023-20198: an AND node whose
+// children list the vulnerable IOS XE builds and the platform.
+const nvdNestedConfigPayload = `{"vulnerabilities":[{"cve":{
+	"id":"CVE-2023-20198",
+	"configurations":[
+		{"operator":"AND","nodes":[{"operator":"OR","negate":false,"cpeMatch":[],"children":[
+			{"operator":"OR","cpeMatch":[
+				{"vulnerable":true,"criteria":"cpe:2.3:o:cisco:ios_xe:17.9.1:*:*:*:*:*:*:*","matchCriteriaId":"A"},
+				{"vulnerable":true,"criteria":"cpe:2.3:o:cisco:ios_xe:16.12.4:*:*:*:*:*:*:*","matchCriteriaId":"B"}
+			]},
+			{"operator":"OR","cpeMatch":[
+				{"vulnerable":false,"criteria":"cpe:2.3:h:cisco:catalyst_9300:-:*:*:*:*:*:*:*","matchCriteriaId":"C"}
+			]}
+		]}]},
+		{"nodes":[{"operator":"OR","cpeMatch":[
+			{"vulnerable":true,"criteria":"cpe:2.3:a:juniper\\:networks:junos\\:evolved:22.1:*:*:*:*:*:*:*","matchCriteriaId":"D"}
+		]}]}
+	]
+}}]}`
+
+func TestParseNVDNestedConfigurations(t *testing.T) {
+
+	t.Setenv("CVE_VENDOR_OVERRIDES", "")
+	t.Setenv("CVE_PRODUCT_OVERRIDES", "")
+
+	items := parseNVDPayload(t, nvdNestedConfigPayload)
+	if len(items) != 1 {
+		t.Fatalf("items = %d, want 1", len(items))
+	}
+
+	got := items[0]
+	if !reflect.DeepEqual(got.Vendors, []string{"cisco", "juniper:networks"}) {
+		t.Errorf("Vendors = %q, want every vendor once, vulnerable first", got.Vendors)
+	}
+	if !reflect.DeepEqual(got.Products, []string{"ios_xe", "junos:evolved", "catalyst_9300"}) {
+		t.Errorf("Products = %q, want vulnerable products before the platform", got.Products)
+	}
+	if got.Vendor != "cisco" || got.Product != "ios_xe" {
+		t.Errorf("Vendor/Product = %s/%s, want the first of each", got.Vendor, got.Product)
+	}
+}
+
+func TestParseCPE(t *testing.T) {
+
+	vendor, product, ok := parseCPE(`cpe:2.3:a:vendor\:x:product\:y:1.0\:beta:*:*:*:*:*:*:*`)
+	if !ok || vendor != "vendor:x" || product != "product:y" {
+		t.Errorf("escaped colons: %q %q %v", vendor, product, ok)
+	}
+
+	for _, bad := range []string{"cpe:/o:cisco:ios", "cpe:2.3:o:*:*:*", "cpe:2.3:o"} {
+		if _, _, ok := parseCPE(bad); ok {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+/* ---------------- NVD RESILIENCY ---------------- */
+
+func TestFetchNVDKeepsPagesBeforeAFailedOne(t *testing.T) {
+
+	calls := 0
+	pages := nvdPages([]string{"CVE-2024-0001", "CVE-2024-0002", "CVE-2024-0003"}, 2, &calls)
+	stubNVD(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("startIndex") != "0" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		pages(w, r)
+	})
+
+	items, err := fetchRecentCVEsFromNVD(context.Background(), 7)
+
+	var partial *nvdPartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("err = %v, want *nvdPartialError", err)
+	}
+	if partial.Fetched != 2 || partial.Total != 3 {
+		t.Errorf("partial = %d of %d, want 2 of 3", partial.Fetched, partial.Total)
+	}
+	if got := cveIDs(items); fmt.Sprint(got) != "[CVE-2024-0001 CVE-2024-0002]" {
+		t.Errorf("got %v, want page 1's CVEs", got)
+	}
+}
+
+func TestFetchNVDStopsWhenCancelledBetweenPages(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	pages := nvdPages([]string{"CVE-2024-0001", "CVE-2024-0002"}, 1, &calls)
+	stubNVD(t, func(w http.ResponseWriter, r *http.Request) {
+		pages(w, r)
+		time.AfterFunc(50*time.Millisecond, cancel) // once page 1 is in, during the wait
+	})
+	t.Setenv("NVD_API_KEY", "") // public rate limit: wait between pages
+
+	start := time.Now()
+	items, err := fetchRecentCVEsFromNVD(ctx, 7)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed >= nvdPublicPageDelay {
+		t.Errorf("took %v, want the page delay cut short", elapsed)
+	}
+	if calls != 1 || len(items) != 1 {
+		t.Errorf("%d requests, %d CVEs; want 1 and page 1's CVE", calls, len(items))
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+/* ======================================================
+   🔥 OPENAI-COMPATIBLE PROVIDER
+   ======================================================
+
+   LLM_PROVIDER=openai sends the same prompt to
+   OPENAI_BASE_URL/chat/completions. Generation settings
+   (temperature, max tokens, stops, top_p, retries and
+   timeouts) come from the WATSONX_* variables so both
+   backends behave alike.
+*/
+
+type openAIProvider struct {
+	BaseURL string // e.g. http://localhost:8000/v1
+	APIKey  string // optional; local servers usually need none
+	Model   string
+}
+
+func newOpenAIProvider() (openAIProvider, error) {
+
+	p := openAIProvider{
+		BaseURL: strings.TrimRight(envString("OPENAI_BASE_URL", ""), "/"),
+		APIKey:  envString("OPENAI_API_KEY", ""),
+		Model:   envString("OPENAI_MODEL", ""),
+	}
+
+	if p.BaseURL == "" || p.Model == "" {
+		return p, errors.New("LLM_PROVIDER=openai needs OPENAI_BASE_URL and OPENAI_MODEL")
+	}
+
+	return p, nil
+}
+
+func (openAIProvider) Name() string { return providerOpenAI }
+
+func (p openAIProvider) Analyze(ctx context.Context, event Event, ragData string) (UnifiedResponse, error) {
+
+	cfg := getWatsonConfig().forEvent(event)
+	if event.ModelID == "" {
+		cfg.ModelID = p.Model
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return UnifiedResponse{}, err
+	}
+
+	return analyzeWithModel(ctx, "OpenAI", cfg, event, ragData, func(ctx context.Context, prompt string, temperature float64, maxNewTokens int) (_ []string, _ TokenUsage, err error) {
+
+		ctx, span := startSpan(ctx, "openai.chat",
+			attribute.String("llm.model", cfg.ModelID),
+			attribute.Float64("llm.temperature", temperature),
+		)
+		defer endSpan(span, &err)
+
+		return p.chat(ctx, cfg, prompt, temperature, maxNewTokens)
+	})
+}
+
+func (p openAIProvider) chat(ctx context.Context, cfg WatsonConfig, prompt string, temperature float64, maxNewTokens int) (_ []string, _ TokenUsage, err error) {
+
+	defer observeSince(watsonRequestSeconds, time.Now())
+
+	ctx, cancel := withStepTimeout(ctx, cfg.GenerationTimeout, errGenerationTimeout)
+	defer cancel()
+	defer func() { err = stepTimeoutError(ctx, cfg.GenerationTimeout, err) }()
+
+	body, _ := json.Marshal(openAIPayload(cfg, prompt, temperature, maxNewTokens))
+
+	resp, err := doWithRetry(ctx, newHTTPClient(0), cfg.retryPolicy(), "OpenAI", func() (*http.Request, error) {
+
+		req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/chat/completions", bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
+
+		if p.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+p.APIKey)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		return req, nil
+	})
+	if err != nil {
+		return nil, TokenUsage{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+
+		if rejectedResponseFormat(cfg, resp.StatusCode, string(body)) {
+			cfg.EnforceJSON = false
+			return p.chat(ctx, cfg, prompt, temperature, maxNewTokens)
+		}
+
+		return nil, TokenUsage{}, &statusError{Service: "OpenAI", Code: resp.StatusCode, Body: string(body)}
+	}
+
+	return decodeChatResponse(cfg, resp.Body)
+}
+
+// openAIPayload is chatPayload in OpenAI's shape: "model", no project.
+func openAIPayload(cfg WatsonConfig, prompt string, temperature float64, maxNewTokens int) map[string]interface{} {
+
+	payload := map[string]interface{}{
+		"model": cfg.ModelID,
+		"messages": []map[string]string{
+			{"role": "system", "content": chatSystemPrompt},
+			{"role": "user", "content": prompt},
+		},
+		"temperature": temperature,
+		"max_tokens":  maxNewTokens,
+	}
+
+	if len(cfg.StopSequences) > 0 {
+		payload["stop"] = cfg.StopSequences
+	}
+	if cfg.TopP > 0 {
+		payload["top_p"] = cfg.TopP
+	}
+	if format := responseFormat(cfg); format != nil {
+		payload["response_format"] = format
+	}
+
+	return payload
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+/* ---------------- OPENAI-COMPATIBLE PROVIDER ---------------- */
+
+func TestOpenAIProviderAgainstMockServer(t *testing.T) {
+
+	var path, auth, model string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+
+		var payload struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		model = payload.Model
+
+		writeJSON(w, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": `{"severity":"high","explanation":"uplink lost","recommended_action":"check optics"}`}},
+			},
+			"usage": map[string]int{"prompt_tokens": 100, "completion_tokens": 20},
+		})
+	}))
+	defer srv.Close()
+
+	useWatsonConfig(t, testWatsonConfig(srv.URL))
+	t.Setenv("OPENAI_BASE_URL", srv.URL+"/v1/")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("OPENAI_MODEL", "llama-3-8b")
+
+	p, err := newLLMProvider(providerOpenAI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.Analyze(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/v1/chat/completions" || auth != "Bearer sk-test" || model != "llama-3-8b" {
+		t.Errorf("request = %s, auth %q, model %q", path, auth, model)
+	}
+	if got.Severity != "high" || got.Explanation != "uplink lost" || got.RecommendedAction != "check optics" {
+		t.Errorf("response = %+v", got)
+	}
+}
+
+func TestOpenAIProviderNeedsBaseURLAndModel(t *testing.T) {
+
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("OPENAI_MODEL", "llama-3-8b")
+
+	if _, err := newLLMProvider(providerOpenAI); err == nil {
+		t.Error("provider created without OPENAI_BASE_URL")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ======================================================
+   🔥 PROMPT PREVIEW
+   ======================================================
+
+   POST /events/preview runs CVE selection and prompt
+   building exactly as /events would, then returns the prompt
+   and generation settings without calling Watsonx. It makes
+   no outbound calls at all: EPSS scores come from the cache
+   only, so a CVE not scored yet may rank lower than it would
+   in the real analysis.
+*/
+
+type promptPreview struct {
+	Prompt          string                 `json:"prompt"`
+	PromptTokens    int                    `json:"estimated_prompt_tokens"`
+	CVEIDs          []string               `json:"cve_ids"`
+	Template        string                 `json:"template"`
+	Endpoint        string                 `json:"endpoint"`
+	ModelID         string                 `json:"model_id"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	ResultCacheable bool                   `json:"result_cacheable"`
+}
+
+func handleEventPreview(c *gin.Context) {
+
+	var evt Event
+
+	if err := c.ShouldBindJSON(&evt); err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidRequest, err.Error())
+		return
+	}
+
+	if err := validateEvent(evt); err != nil {
+		respondError(c, http.StatusBadRequest, errInvalidEvent, err.Error())
+		return
+	}
+
+	cfg := getWatsonConfig().forEvent(evt)
+
+	cves := previewCVEs(evt)
+
+	ragData := fitRagBlock(cfg, evt, buildRagBlock(evt, cves))
+	prompt := renderPrompt(evt, ragData)
+
+	// Only the CVEs that survived the RAG budgets are in the prompt
+	ids := renderedCVEIDs(ragData)
+	if ids == nil {
+		ids = []string{}
+	}
+
+	endpoint, _ := generationRequest(cfg, prompt, cfg.Temperature, maxNewTokensForMode(cfg, evt.Mode))
+
+	c.JSON(http.StatusOK, promptPreview{
+		Prompt:       prompt,
+		PromptTokens: estimateTokens(prompt),
+		CVEIDs:       ids,
+		Template:     promptTemplateName(evt),
+		Endpoint:     endpoint,
+		ModelID:      cfg.ModelID,
+		Parameters: map[string]interface{}{
+			"temperature":    cfg.Temperature,
+			"max_new_tokens": maxNewTokensForMode(cfg, evt.Mode),
+			"parse_retries":  cfg.ParseRetries,
+			"chat_api":       cfg.UseChatAPI,
+		},
+		ResultCacheable: resultCacheTTL() > 0,
+	})
+}
+
+// previewCVEs is selectCVEs with EPSS scores from the cache only.
+func previewCVEs(event Event) []CVE {
+
+	if event.Mode == ModeClassify || !FeatureEnabled(FlagRAG) {
+		return nil
+	}
+
+	cves := findRelevantCVEs(event.Message).CVEs
+
+	if FeatureEnabled(FlagEPSS) {
+		applyCachedEPSS(cves)
+	}
+
+	return cves
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubEPSS points EPSS lookups at a server counting them in calls, with
+// an empty cache restored after the test.
+func stubEPSS(t *testing.T, calls *atomic.Int32) {
+
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	prevURL := epssAPIURL
+	epssAPIURL = srv.URL
+
+	epssMutex.Lock()
+	prevCache := epssCache
+	epssCache = map[string]epssEntry{}
+	epssMutex.Unlock()
+
+	t.Cleanup(func() {
+		epssAPIURL = prevURL
+		epssMutex.Lock()
+		epssCache = prevCache
+		epssMutex.Unlock()
+	})
+}
+
+/* ---------------- OUTBOUND CALLS ---------------- */
+
+func TestPreviewMakesNoEPSSCalls(t *testing.T) {
+
+	var calls atomic.Int32
+	stubEPSS(t, &calls)
+
+	setFlags(t, map[string]bool{FlagRAG: true, FlagEPSS: true})
+	useWatsonConfig(t, testWatsonConfig("http://watsonx.invalid"))
+	useRecentCVEs(t, ciscoCVEs(2))
+
+	// One CVE already scored, one not
+	epssMutex.Lock()
+	epssCache["CVE-2024-1000"] = epssEntry{score: 0.42, percentile: 0.9, fetched: time.Now()}
+	epssMutex.Unlock()
+
+	router := gin.New()
+	router.POST("/events/preview", handleEventPreview)
+
+	req := httptest.NewRequest(http.MethodPost, "/events/preview",
+		strings.NewReader(`{"type":"bgp_down","message":"cisco ios_xe router lost BGP peer"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("preview: %d %s", w.Code, w.Body)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("preview made %d EPSS calls, want none", n)
+	}
+	if !containsAll(w.Body.String(), "CVE-2024-1000", "CVE-2024-1001", "EPSS 42.0%") {
+		t.Errorf("preview should list both CVEs with the cached score: %s", w.Body)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+/* ---------------- ANALYSIS MODES ---------------- */
+
+const (
+	ModeFull      = "full"
+	ModeClassify  = "classify"
+	ModeRemediate = "remediate"
+)
+
+const classifyMaxNewTokens = 40
+
+// maxNewTokensForMode keeps classify-only calls short and cheap; other
+// modes get the configured WATSONX_MAX_NEW_TOKENS.
+func maxNewTokensForMode(cfg WatsonConfig, mode string) int {
+	if mode == ModeClassify {
+		return min(classifyMaxNewTokens, cfg.MaxNewTokens)
+	}
+	return cfg.MaxNewTokens
+}
+
+// validateEvent checks the request options: the mode (remediate needs the
+// externally determined severity), asset criticality and any requested
+// model.
+func validateEvent(event Event) error {
+
+	if event.ModelID != "" && !getWatsonConfig().modelAllowed(event.ModelID) {
+		return fmt.Errorf("model not allowed: %s", event.ModelID)
+	}
+
+	switch event.AssetCriticality {
+	case "", criticalityLow, criticalityMedium, criticalityHigh:
+	default:
+		return fmt.Errorf("invalid asset_criticality: %s (want low, medium or high)", event.AssetCriticality)
+	}
+
+	switch event.Mode {
+	case "", ModeFull, ModeClassify:
+		return nil
+	case ModeRemediate:
+		if strings.TrimSpace(event.Severity) == "" {
+			return fmt.Errorf("mode %q requires severity", ModeRemediate)
+		}
+		if normalizeSeverity(event.Severity) == severityUnknown {
+			return fmt.Errorf("mode %q: unrecognized severity %q", ModeRemediate, event.Severity)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("invalid mode: %s", event.Mode)
+}
+
+/* ---------------- PROMPT BUILDER ---------------- */
+
+func buildPrompt(event Event, ragData string) string {
+
+	prompt := renderPrompt(event, ragData)
+	promptTokens.Observe(float64(estimateTokens(prompt)))
+
+	return prompt
+}
+
+func renderPrompt(event Event, ragData string) string {
+
+	switch event.Mode {
+	case ModeClassify:
+		return buildClassifyPrompt(event)
+	case ModeRemediate:
+		return buildRemediatePrompt(event, ragData)
+	}
+
+	if prompt, ok := renderCustomPrompt(event, ragData); ok {
+		return prompt
+	}
+
+	return fmt.Sprintf(
+		`%s<System data>
+Event type: %s
+Event message:
+%s
+%s</System data>
+
+<Instructions>
+Analyze the event.
+%s%s%s
+Use CVE data ONLY if relevant.
+Do NOT mention RAG or system data.
+
+Respond ONLY with valid JSON.
+No extra text.
+
+Format:
+{
+  "severity": "info | low | medium | high | critical",
+  "explanation": "brief reason",
+  "root_cause": "likely underlying cause",
+  "impact": "affected services or users",
+  "recommended_action": "clear action",
+  "confidence": 75
+}
+confidence is an integer from 0 to 100: how certain you are of the severity.
+</Instructions>
+
+<Question>
+Determine severity and recommended action.
+</Question>`,
+		ragSection(ragData),
+		event.Type,
+		untrustedMessage(event.Message),
+		eventMetadata(event),
+		untrustedInstruction,
+		severityHint(event),
+		languageHint(event),
+	)
+}
+
+// buildClassifyPrompt asks for severity only, with the confidence the
+// review policy needs. CVE context is skipped to keep triage prompts
+// minimal.
+func buildClassifyPrompt(event Event) string {
+
+	return fmt.Sprintf(
+		`<System data>
+Event type: %s
+Event message:
+%s
+%s</System data>
+
+<Instructions>
+Classify the severity of the event.
+%s%s%s
+Respond ONLY with valid JSON.
+No extra text.
+
+Format:
+{
+  "severity": "info | low | medium | high | critical",
+  "explanation": "one-word reason",
+  "confidence": 75
+}
+confidence is an integer from 0 to 100: how certain you are of the severity.
+</Instructions>`,
+		event.Type,
+		untrustedMessage(event.Message),
+		eventMetadata(event),
+		untrustedInstruction,
+		severityHint(event),
+		languageHint(event),
+	)
+}
+
+// buildRemediatePrompt skips classification: severity comes from another
+// system and only remediation steps are requested.
+func buildRemediatePrompt(event Event, ragData string) string {
+
+	return fmt.Sprintf(
+		`%s<System data>
+Event type: %s
+Event message:
+%s
+Severity: %s
+%s</System data>
+
+<Instructions>
+The severity has already been determined. Do NOT reassess it.
+%s%s
+Use CVE data ONLY if relevant.
+Do NOT mention RAG or system data.
+
+Respond ONLY with valid JSON.
+No extra text.
+
+Format:
+{
+  "recommended_action": "one-line summary of the fix",
+  "remediation_steps": ["step 1", "step 2"]
+}
+</Instructions>
+
+<Question>
+What should be done to remediate this event?
+</Question>`,
+		ragSection(ragData),
+		event.Type,
+		untrustedMessage(event.Message),
+		sanitizeMetadata(event.Severity),
+		eventMetadata(event),
+		untrustedInstruction,
+		languageHint(event),
+	)
+}
+
+// ragSection places the RAG block ahead of <System data>, followed by a
+// blank line. Without CVE context (empty string or an empty <Rag></Rag>)
+// it renders nothing, so the prompt starts directly with <System data>.
+func ragSection(ragData string) string {
+
+	rag := strings.TrimSpace(ragData)
+
+	inner := strings.TrimPrefix(rag, "<Rag>")
+	inner = strings.TrimSuffix(inner, "</Rag>")
+	if strings.TrimSpace(inner) == "" {
+		return ""
+	}
+
+	return rag + "\n\n"
+}
+
+// severityHint renders the upstream severity as an instruction line the
+// model may override, or nothing when there is none.
+func severityHint(event Event) string {
+
+	hint := sanitizeMetadata(event.Severity)
+	if hint == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("The monitoring system pre-classified this event as %q; confirm or correct it.\n", hint)
+}
+
+/* ---------------- CONTEXT WINDOW BUDGET ---------------- */
+
+// fitRagBlock drops the lowest-ranked RAG lines (the block is written best
+// first) until the prompt leaves room for the answer within
+// cfg.MaxContextTokens. Otherwise the API would truncate the prompt
+// silently, usually cutting the instructions and the event at the end.
+func fitRagBlock(cfg WatsonConfig, event Event, ragData string) string {
+
+	if cfg.MaxContextTokens <= 0 || ragData == "" {
+		return ragData
+	}
+
+	budget := cfg.MaxContextTokens - maxNewTokensForMode(cfg, event.Mode)
+
+	tokens := estimateTokens(renderPrompt(event, ragData))
+	if tokens <= budget {
+		return ragData
+	}
+
+	lines := ragLines(ragData)
+	total := len(lines)
+
+	for tokens > budget && len(lines) > 0 {
+		lines = lines[:len(lines)-1]
+		ragData = joinRagLines(lines)
+		tokens = estimateTokens(renderPrompt(event, ragData))
+	}
+
+	LogFields("✂️ RAG block trimmed to fit context window",
+		"type", event.Type,
+		"dropped", total-len(lines),
+		"kept", len(lines),
+		"prompt_tokens", tokens,
+		"budget", budget,
+	)
+
+	return ragData
+}
+
+// ragLines splits a <Rag> block into its lines, newline included.
+func ragLines(block string) []string {
+
+	body := strings.TrimPrefix(block, "<Rag>\n")
+	body = strings.TrimSuffix(body, "</Rag>\n")
+
+	lines := strings.SplitAfter(body, "\n")
+	return lines[:len(lines)-1] // body ends with a newline
+}
+
+func joinRagLines(lines []string) string {
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return "<Rag>\n" + strings.Join(lines, "") + "</Rag>\n"
+}
+
+/* ---------------- EVENT METADATA ---------------- */
+
+const maxMetadataLen = 128
+
+// eventMetadata renders the non-empty metadata fields as extra
+// <System data> lines, one per field.
+func eventMetadata(event Event) string {
+
+	fields := []struct{ label, value string }{
+		{"Source host", event.SourceHost},
+		{"Source IP", event.SourceIP},
+		{"Category", event.Category},
+		{"Asset criticality", event.AssetCriticality},
+	}
+
+	var b strings.Builder
+	for _, f := range fields {
+		if v := sanitizeMetadata(f.value); v != "" {
+			fmt.Fprintf(&b, "%s: %s\n", f.label, v)
+		}
+	}
+
+	return b.String()
+}
+
+// sanitizeMetadata keeps metadata on a single line, drops control
+// characters and prompt tag delimiters, and caps the length.
+func sanitizeMetadata(s string) string {
+
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case r == '<' || r == '>':
+			return -1
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, s)
+
+	s = strings.TrimSpace(s)
+
+	if r := []rune(s); len(r) > maxMetadataLen {
+		s = string(r[:maxMetadataLen])
+	}
+
+	return s
+}
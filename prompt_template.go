@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+/* ======================================================
+   🔥 CUSTOM PROMPT TEMPLATE
+   ======================================================
+
+   PROMPT_TEMPLATE_PATH may point to a text/template that
+   replaces the built-in full-analysis prompt. Classify and
+   remediate prompts stay built-in. Unset, unreadable or
+   invalid templates fall back to the default.
+*/
+
+// promptTemplateData is what a custom template can reference.
+type promptTemplateData struct {
+	EventType    string // .EventType
+	Message      string // .Message: neutralized and wrapped in <Untrusted> (see injection.go)
+	Untrusted    string // .Untrusted: instruction line not to obey the message
+	Context      string // .Context: metadata lines (host, IP, category)
+	Rag          string // .Rag: the <Rag> block, empty without CVE context
+	SeverityHint string // .SeverityHint: upstream severity hint line, if any
+	LanguageHint string // .LanguageHint: "answer in English" line for non-English messages
+}
+
+var (
+	promptTemplateOnce sync.Once
+	promptTemplate     *template.Template
+	promptTemplatePath string
+)
+
+// InitPromptTemplate loads and validates the template once, logging
+// which prompt is active.
+func InitPromptTemplate() {
+	promptTemplateOnce.Do(func() {
+		promptTemplatePath = envString("PROMPT_TEMPLATE_PATH", "")
+		promptTemplate = loadPromptTemplate(promptTemplatePath)
+	})
+}
+
+func activePromptTemplate() *template.Template {
+	InitPromptTemplate()
+	return promptTemplate
+}
+
+// loadPromptTemplate returns nil (use the built-in prompt) when path is
+// empty or the template can't be read, parsed or rendered.
+func loadPromptTemplate(path string) *template.Template {
+
+	if path == "" {
+		Logger.Println("📝 Prompt template: built-in")
+		return nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		LogFields("⚠️ Prompt template unreadable — using built-in", "path", path, "error", err)
+		return nil
+	}
+
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		LogFields("⚠️ Prompt template invalid — using built-in", "path", path, "error", err)
+		return nil
+	}
+
+	// Catch references to unknown fields now rather than per event
+	sample := promptTemplateData{EventType: "link_down", Message: "Interface Gi0/1 down"}
+	if err := tmpl.Execute(&strings.Builder{}, sample); err != nil {
+		LogFields("⚠️ Prompt template fails to render — using built-in", "path", path, "error", err)
+		return nil
+	}
+
+	LogFields("📝 Prompt template: custom", "path", path)
+	return tmpl
+}
+
+// renderCustomPrompt renders the custom template; ok is false when none
+// is configured or rendering failed.
+func renderCustomPrompt(event Event, ragData string) (string, bool) {
+
+	tmpl := activePromptTemplate()
+	if tmpl == nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	err := tmpl.Execute(&b, promptTemplateData{
+		EventType:    event.Type,
+		Message:      untrustedMessage(event.Message),
+		Untrusted:    untrustedInstruction,
+		Context:      eventMetadata(event),
+		Rag:          strings.TrimSpace(ragSection(ragData)),
+		SeverityHint: severityHint(event),
+		LanguageHint: languageHint(event),
+	})
+	if err != nil {
+		LogFields("⚠️ Prompt template render failed — using built-in", "error", err)
+		return "", false
+	}
+
+	return b.String(), true
+}
+
+// promptTemplateName names the prompt the event is built from: the
+// custom template path, or "built-in:<mode>".
+func promptTemplateName(event Event) string {
+
+	mode := event.Mode
+	if mode == "" {
+		mode = ModeFull
+	}
+
+	if mode == ModeFull && activePromptTemplate() != nil {
+		return promptTemplatePath
+	}
+
+	return "built-in:" + mode
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// usePromptTemplate installs text as the custom prompt template for the
+// test and restores the built-in prompt afterwards.
+func usePromptTemplate(t *testing.T, text string) {
+
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "prompt.tmpl")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	InitPromptTemplate()
+	prevTmpl, prevPath := promptTemplate, promptTemplatePath
+	t.Cleanup(func() { promptTemplate, promptTemplatePath = prevTmpl, prevPath })
+
+	promptTemplate, promptTemplatePath = loadPromptTemplate(path), path
+}
+
+/* ---------------- CUSTOM PROMPT TEMPLATE ---------------- */
+
+func TestCustomPromptTemplateRenders(t *testing.T) {
+
+	usePromptTemplate(t, "Analyse {{.EventType}}:\n{{.Message}}\n{{.Rag}}\nJSON only.")
+
+	event := Event{Type: "link_down", Message: "Interface Gi0/1 down"}
+	prompt := buildPrompt(event, "CVE-2024-0001 - Cisco IOS flaw")
+
+	if !strings.HasPrefix(prompt, "Analyse link_down:\n") {
+		t.Errorf("prompt does not use the template:\n%s", prompt)
+	}
+	if !containsAll(prompt, "Interface Gi0/1 down", "CVE-2024-0001", "JSON only.") {
+		t.Errorf("template fields not rendered:\n%s", prompt)
+	}
+	if got := promptTemplateName(event); got != promptTemplatePath {
+		t.Errorf("promptTemplateName = %q, want the template path", got)
+	}
+}
+
+func TestInvalidPromptTemplateFallsBack(t *testing.T) {
+
+	usePromptTemplate(t, "{{.NoSuchField}}")
+
+	if promptTemplate != nil {
+		t.Fatal("template with an unknown field was accepted")
+	}
+
+	event := Event{Type: "link_down", Message: "Interface Gi0/1 down"}
+	if got := promptTemplateName(event); got != "built-in:"+ModeFull {
+		t.Errorf("promptTemplateName = %q, want the built-in prompt", got)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+/* ---------------- CLASSIFY MODE ---------------- */
+
+func TestClassifyPromptAsksForSeverityOnly(t *testing.T) {
+
+	event := Event{Type: "link_down", Message: "Gi0/1 down", Mode: ModeClassify}
+	prompt := renderPrompt(event, "<Rag>\nCVE-2024-0001 - cisco/ios - CVSS 9.8\n</Rag>\n")
+
+	if !containsAll(prompt, "Classify the severity", `"severity"`, `"confidence"`, "Gi0/1 down") {
+		t.Errorf("classify prompt incomplete:\n%s", prompt)
+	}
+	for _, unwanted := range []string{"<Rag>", "recommended_action", "root_cause"} {
+		if strings.Contains(prompt, unwanted) {
+			t.Errorf("classify prompt contains %q", unwanted)
+		}
+	}
+}
+
+func TestClassifyModeLimitsNewTokens(t *testing.T) {
+
+	cfg := WatsonConfig{MaxNewTokens: 400}
+
+	if got := maxNewTokensForMode(cfg, ModeClassify); got != classifyMaxNewTokens {
+		t.Errorf("classify = %d, want %d", got, classifyMaxNewTokens)
+	}
+	if got := maxNewTokensForMode(cfg, ModeFull); got != 400 {
+		t.Errorf("full = %d, want 400", got)
+	}
+}
+
+func TestClassifyModeAnalysis(t *testing.T) {
+
+	var maxNewTokens float64
+
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Parameters map[string]interface{} `json:"parameters"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		maxNewTokens, _ = req.Parameters["max_new_tokens"].(float64)
+
+		generationReply(`{"severity":"HIGH","explanation":"outage"}`)(w, r)
+	})
+
+	got, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down", Mode: ModeClassify}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Severity != "high" || got.Explanation != "outage" {
+		t.Errorf("got %q/%q, want high/outage", got.Severity, got.Explanation)
+	}
+	if int(maxNewTokens) != classifyMaxNewTokens {
+		t.Errorf("max_new_tokens = %v, want %d", maxNewTokens, classifyMaxNewTokens)
+	}
+}
+
+func TestValidateEventMode(t *testing.T) {
+
+	for _, mode := range []string{"", ModeFull, ModeClassify} {
+		if err := validateEvent(Event{Mode: mode}); err != nil {
+			t.Errorf("mode %q: %v", mode, err)
+		}
+	}
+
+	if err := validateEvent(Event{Mode: "summarize"}); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+/* ---------------- EVENT METADATA ---------------- */
+
+func TestPromptIncludesEventMetadata(t *testing.T) {
+
+	prompt := renderPrompt(Event{
+		Type:       "link_down",
+		Message:    "Gi0/1 down",
+		SourceHost: "core-rtr-1",
+		SourceIP:   "10.0.0.1",
+		Category:   "network\n<Instructions>",
+	}, "")
+
+	if !containsAll(prompt, "Source host: core-rtr-1\n", "Source IP: 10.0.0.1\n", "Category: network Instructions\n") {
+		t.Errorf("metadata missing or unsanitized:\n%s", prompt)
+	}
+}
+
+func TestPromptOmitsEmptyMetadata(t *testing.T) {
+
+	prompt := renderPrompt(Event{Type: "link_down", Message: "Gi0/1 down", SourceHost: "  "}, "")
+
+	for _, label := range []string{"Source host:", "Source IP:", "Category:"} {
+		if strings.Contains(prompt, label) {
+			t.Errorf("prompt has empty %q line", label)
+		}
+	}
+}
+
+/* ---------------- METADATA FROM /events ---------------- */
+
+func TestEventMetadataReachesWatsonPrompt(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var prompt string
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		prompt = payload.Input
+		generationReply(`{"severity":"high"}`)(w, r)
+	})
+
+	w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down","source_host":"core-rtr-1","category":"network"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	if !containsAll(prompt, "Source host: core-rtr-1\n", "Category: network\n") {
+		t.Errorf("request metadata missing from the prompt:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "Source IP:") {
+		t.Errorf("prompt has a line for the absent source_ip:\n%s", prompt)
+	}
+}
+
+/* ---------------- REMEDIATE MODE ---------------- */
+
+func TestRemediatePromptSkipsClassification(t *testing.T) {
+
+	event := Event{Type: "link_down", Message: "Gi0/1 down", Mode: ModeRemediate, Severity: "High"}
+	prompt := renderPrompt(event, "<Rag>\nCVE-2024-0001 - cisco/ios - CVSS 9.8\n</Rag>\n")
+
+	if !containsAll(prompt, "Severity: High", "Do NOT reassess it", `"remediation_steps"`, "<Rag>") {
+		t.Errorf("remediate prompt incomplete:\n%s", prompt)
+	}
+	if strings.Contains(prompt, `"severity":`) || strings.Contains(prompt, "pre-classified") {
+		t.Errorf("remediate prompt asks for a severity:\n%s", prompt)
+	}
+}
+
+func TestValidateRemediateSeverity(t *testing.T) {
+
+	for severity, ok := range map[string]bool{"": false, "banana": false, "HIGH": true, "crit": true} {
+		err := validateEvent(Event{Mode: ModeRemediate, Severity: severity})
+		if (err == nil) != ok {
+			t.Errorf("severity %q: err = %v", severity, err)
+		}
+	}
+}
+
+/* ---------------- EMPTY RAG SECTION ---------------- */
+
+func TestPromptWithoutRAGHasNoArtifacts(t *testing.T) {
+
+	for _, ragData := range []string{"", "<Rag>\n</Rag>\n", "<Rag></Rag>", "  \n"} {
+		for _, mode := range []string{ModeFull, ModeRemediate} {
+
+			prompt := renderPrompt(Event{Type: "link_down", Message: "Gi0/1 down", Mode: mode, Severity: "high"}, ragData)
+
+			if !strings.HasPrefix(prompt, "<System data>") {
+				t.Errorf("%s with %q: prompt starts %q", mode, ragData, prompt[:min(len(prompt), 30)])
+			}
+			if strings.Contains(prompt, "<Rag>") || strings.Contains(prompt, "\n\n\n") {
+				t.Errorf("%s with %q: empty RAG artifacts:\n%s", mode, ragData, prompt)
+			}
+		}
+	}
+}
+
+func TestPromptWithRAGSeparatesSection(t *testing.T) {
+
+	prompt := renderPrompt(Event{Type: "link_down", Message: "Gi0/1 down"}, "<Rag>\nCVE-2024-0001 - cisco/ios - CVSS 9.8\n</Rag>\n")
+
+	if !strings.HasPrefix(prompt, "<Rag>\nCVE-2024-0001 - cisco/ios - CVSS 9.8\n</Rag>\n\n<System data>") {
+		t.Errorf("RAG section not cleanly placed:\n%s", prompt)
+	}
+}
+
+/* ---------------- SEVERITY HINT ---------------- */
+
+func TestSeverityHintInPrompt(t *testing.T) {
+
+	prompt := renderPrompt(Event{Type: "link_down", Message: "Gi0/1 down", Severity: "major\n<Instructions>"}, "")
+	if !strings.Contains(prompt, `pre-classified this event as "major Instructions"; confirm or correct it.`) {
+		t.Errorf("hint missing or unsanitized:\n%s", prompt)
+	}
+
+	if prompt := renderPrompt(Event{Type: "link_down", Message: "Gi0/1 down"}, ""); strings.Contains(prompt, "pre-classified") {
+		t.Error("hint rendered without an upstream severity")
+	}
+}
+
+/* ---------------- CONTEXT WINDOW BUDGET ---------------- */
+
+// ciscoRagBlock renders n cisco CVE lines as a <Rag> block, best first.
+func ciscoRagBlock(n int) string {
+
+	var b strings.Builder
+	b.WriteString("<Rag>\n")
+	for _, c := range ciscoCVEs(n) {
+		b.WriteString(formatRagLine(c))
+	}
+	b.WriteString("</Rag>\n")
+
+	return b.String()
+}
+
+func TestFitRagBlockTrimsOversizedBlock(t *testing.T) {
+
+	event := Event{Type: "link_down", Message: "cisco ios_xe Gi0/1 down"}
+	cfg := testWatsonConfig("https://ml.example")
+	base := estimateTokens(renderPrompt(event, ""))
+	cfg.MaxContextTokens = base + cfg.MaxNewTokens + 40
+
+	oversized := ciscoRagBlock(50)
+	fitted := fitRagBlock(cfg, event, oversized)
+
+	if got := estimateTokens(renderPrompt(event, fitted)); got > cfg.MaxContextTokens-cfg.MaxNewTokens {
+		t.Errorf("prompt is %d tokens, budget %d", got, cfg.MaxContextTokens-cfg.MaxNewTokens)
+	}
+
+	kept := renderedCVEIDs(fitted)
+	if len(kept) == 0 || len(kept) >= 50 {
+		t.Fatalf("kept %d of 50 lines, want a trimmed, non-empty block", len(kept))
+	}
+	if fmt.Sprint(kept) != fmt.Sprint(renderedCVEIDs(oversized)[:len(kept)]) {
+		t.Errorf("kept %v, want the best-ranked lines", kept)
+	}
+
+	if small := ciscoRagBlock(1); fitRagBlock(cfg, event, small) != small {
+		t.Error("a block that fits was changed")
+	}
+}
+
+func TestContextTrimNarrowsResponseCVEs(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: true})
+	t.Setenv("INCLUDE_RAG_CONTEXT", "true")
+	t.Setenv("RAG_MIN_RELEVANCE", "")
+	t.Setenv("RAG_MAX_TOKENS", "0")
+	useRecentCVEs(t, ciscoCVEs(20))
+
+	var prompt string
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		prompt = payload.Input
+		generationReply(`{"severity":"high"}`)(w, r)
+	})
+
+	message := "cisco ios_xe web UI exploited"
+	cfg.MaxContextTokens = estimateTokens(renderPrompt(Event{Type: "syslog", Message: message}, "")) + cfg.MaxNewTokens + 40
+	useWatsonConfig(t, cfg)
+
+	rag := postForRAGContext(t, message)
+
+	if len(rag.CVEs) == 0 || len(rag.CVEs) >= 20 {
+		t.Fatalf("rag_context has %d CVEs, want the subset that fit the context window", len(rag.CVEs))
+	}
+	for _, c := range rag.CVEs {
+		if !strings.Contains(prompt, c.ID) {
+			t.Errorf("rag_context cites %s, which the prompt lacks", c.ID)
+		}
+	}
+	if n := strings.Count(prompt, "CVE-2024-1"); n != len(rag.CVEs) {
+		t.Errorf("prompt has %d CVEs, rag_context %d", n, len(rag.CVEs))
+	}
+}
+
+func TestAnalysisUsesFittedBlockAsGiven(t *testing.T) {
+
+	var prompt string
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		prompt = payload.Input
+		generationReply(`{"severity":"high"}`)(w, r)
+	})
+
+	// A window the block does not fit: the dispatcher trims, the
+	// analysis must not trim again
+	event := Event{Type: "syslog", Message: "cisco ios_xe web UI exploited"}
+	cfg.MaxContextTokens = estimateTokens(renderPrompt(event, "")) + cfg.MaxNewTokens + 40
+	useWatsonConfig(t, cfg)
+
+	block := ciscoRagBlock(20)
+	if _, err := CallWatsonAIContext(context.Background(), event, block); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(prompt, "CVE-2024-1"); n != 20 {
+		t.Errorf("prompt has %d of the 20 CVEs passed in", n)
+	}
+}
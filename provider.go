@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/* ======================================================
+   🔥 LLM PROVIDERS
+   ======================================================
+
+   LLM_PROVIDER picks the model backend: "watsonx" (default)
+   or "openai" for any OpenAI-compatible chat/completions
+   server (vLLM, Ollama, ...). Both share the prompt, the
+   result cache and response parsing (analyzeWithModel).
+*/
+
+const (
+	providerWatsonx = "watsonx"
+	providerOpenAI  = "openai"
+)
+
+// LLMProvider analyzes one event with the assembled <Rag> block.
+type LLMProvider interface {
+	Name() string
+	Analyze(ctx context.Context, event Event, ragData string) (UnifiedResponse, error)
+}
+
+type watsonxProvider struct{}
+
+func (watsonxProvider) Name() string { return providerWatsonx }
+
+func (watsonxProvider) Analyze(ctx context.Context, event Event, ragData string) (UnifiedResponse, error) {
+	return CallWatsonAIContext(ctx, event, ragData)
+}
+
+var (
+	providerOnce sync.Once
+	provider     LLMProvider
+	providerErr  error
+)
+
+// InitLLMProvider selects the provider from LLM_PROVIDER once; main
+// refuses to start on an error.
+func InitLLMProvider() (LLMProvider, error) {
+
+	providerOnce.Do(func() {
+		provider, providerErr = newLLMProvider(strings.ToLower(envString("LLM_PROVIDER", providerWatsonx)))
+		if providerErr == nil {
+			LogFields("🤖 LLM provider selected", "provider", provider.Name())
+		}
+	})
+
+	return provider, providerErr
+}
+
+func newLLMProvider(name string) (LLMProvider, error) {
+
+	switch name {
+	case providerWatsonx:
+		return watsonxProvider{}, nil
+	case providerOpenAI:
+		return newOpenAIProvider()
+	}
+
+	return nil, fmt.Errorf("unknown LLM_PROVIDER %q (want %s or %s)", name, providerWatsonx, providerOpenAI)
+}
+
+// activeProvider is the configured provider, Watsonx if it failed to
+// initialize (main has already refused to start in that case).
+func activeProvider() LLMProvider {
+
+	if p, err := InitLLMProvider(); err == nil {
+		return p
+	}
+
+	return watsonxProvider{}
+}
+
+// analyzeWithProvider is the analyzeFunc for the active provider.
+func analyzeWithProvider(ctx context.Context, event Event, ragData string) (UnifiedResponse, error) {
+	return activeProvider().Analyze(ctx, event, ragData)
+}
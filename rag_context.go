@@ -0,0 +1,106 @@
+package main
+
+/* ======================================================
+   🔥 RAG CONTEXT
+   ======================================================
+
+   With INCLUDE_RAG_CONTEXT=true every analysis carries the
+   CVEs that went into its prompt and how they were picked,
+   so a verdict can be audited without replaying the event.
+*/
+
+// How the RAG CVEs for an event were picked.
+const (
+	ragSourceMatch    = "vendor_match"
+	ragSourceFallback = "priority_fallback"
+	ragSourceShared   = "shared_batch"
+	ragSourceNone     = "none"
+)
+
+// ragSelection is the CVE list chosen for one event and its source.
+type ragSelection struct {
+	CVEs   []CVE
+	Source string
+
+	// Matched holds the IDs of the CVEs that scored against the event
+	// itself, not those added by the priority fallback or the top-up
+	Matched map[string]bool
+}
+
+// matched returns the CVEs of the selection that scored against the
+// event, in selection order.
+func (r ragSelection) matched() []CVE {
+
+	var out []CVE
+	for _, c := range r.CVEs {
+		if r.Matched[c.ID] {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// rendered narrows the selection to the CVEs written into block, best
+// first: the RAG token budget can leave some of the selected CVEs out,
+// and the response must not cite or escalate on what the model never saw.
+func (r ragSelection) rendered(block string) ragSelection {
+
+	byID := make(map[string]CVE, len(r.CVEs))
+	for _, c := range r.CVEs {
+		byID[c.ID] = c
+	}
+
+	out := ragSelection{Source: r.Source, Matched: r.Matched}
+	for _, id := range renderedCVEIDs(block) {
+		if c, ok := byID[id]; ok {
+			out.CVEs = append(out.CVEs, c)
+			delete(byID, id)
+		}
+	}
+
+	return out
+}
+
+// RAGContext is the rag_context field of UnifiedResponse.
+type RAGContext struct {
+	Source string          `json:"source"`
+	CVEs   []RAGContextCVE `json:"cves"`
+}
+
+// RAGContextCVE is one CVE of the prompt; CVSS is null when unscored.
+type RAGContextCVE struct {
+	ID     string   `json:"id"`
+	Vendor string   `json:"vendor,omitempty"`
+	CVSS   *float64 `json:"cvss"`
+}
+
+// ragContextEnabled reads INCLUDE_RAG_CONTEXT (default false).
+func ragContextEnabled() bool {
+	return envBool("INCLUDE_RAG_CONTEXT", false)
+}
+
+func newRAGContext(rag ragSelection) *RAGContext {
+
+	out := &RAGContext{
+		Source: rag.Source,
+		CVEs:   make([]RAGContextCVE, 0, len(rag.CVEs)),
+	}
+
+	if out.Source == "" {
+		out.Source = ragSourceNone
+	}
+
+	for _, c := range rag.CVEs {
+
+		item := RAGContextCVE{ID: c.ID, Vendor: c.Vendor}
+		if c.scored() {
+			score := c.CVSSScore
+			item.CVSS = &score
+		}
+
+		out.CVEs = append(out.CVEs, item)
+	}
+
+	return out
+}
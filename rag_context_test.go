@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+/* ---------------- RAG CONTEXT ---------------- */
+
+// postForRAGContext analyzes message with INCLUDE_RAG_CONTEXT on and
+// returns the response's rag_context.
+func postForRAGContext(t *testing.T, message string) *RAGContext {
+
+	t.Helper()
+
+	w := postEvent(t, `{"type":"syslog","message":"`+message+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var got UnifiedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RAGContext == nil {
+		t.Fatalf("no rag_context in %s", w.Body)
+	}
+
+	return got.RAGContext
+}
+
+func TestRAGContextSource(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: true})
+	t.Setenv("INCLUDE_RAG_CONTEXT", "true")
+	t.Setenv("RAG_MIN_RELEVANCE", "")
+	useRecentCVEs(t, []CVE{ciscoIOS})
+	stubWatsonx(t, generationReply(`{"severity":"medium"}`))
+
+	matched := postForRAGContext(t, "cisco ios crashed")
+	if matched.Source != ragSourceMatch {
+		t.Errorf("vendor event: source = %q, want %q", matched.Source, ragSourceMatch)
+	}
+
+	fallback := postForRAGContext(t, "disk almost full on backup server")
+	if fallback.Source != ragSourceFallback {
+		t.Errorf("no vendor match: source = %q, want %q", fallback.Source, ragSourceFallback)
+	}
+	if len(fallback.CVEs) != 1 || fallback.CVEs[0].ID != ciscoIOS.ID || fallback.CVEs[0].CVSS == nil {
+		t.Errorf("fallback CVEs = %+v, want %s with its CVSS", fallback.CVEs, ciscoIOS.ID)
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ======================================================
+   🔥 RAG SOURCES
+   ======================================================
+
+   The <Rag> block is assembled by a RAGPipeline from any
+   number of RAGSources: the selected CVEs (CVESource) and
+   optional text snippets such as vendor manual excerpts or
+   past incident resolutions (RAG_SNIPPETS_PATH).
+
+   Chunks from all sources are rescored by word overlap with
+   the event message and a per-source weight
+   (RAG_SOURCE_WEIGHTS), near-duplicates are dropped, and
+   the best chunks are kept until RAG_MAX_TOKENS is spent.
+   A chunk that does not fit is skipped, not the rest: a
+   short high-value line still makes it in after a long one.
+*/
+
+// nearDuplicateSimilarity is the word-set Jaccard similarity above which
+// two chunks count as the same text.
+const nearDuplicateSimilarity = 0.8
+
+// Chunk is one line of RAG context. Score is the source's relevance
+// estimate between 0 and 1.
+type Chunk struct {
+	Source string
+	ID     string
+	Text   string
+	Score  float64
+}
+
+// RAGSource retrieves context chunks for an event.
+type RAGSource interface {
+	Name() string
+	Retrieve(event Event) []Chunk
+}
+
+// RAGPipeline queries every source and merges their chunks.
+type RAGPipeline struct {
+	Sources       []RAGSource
+	SourceWeights map[string]float64 // by Chunk.Source; missing = 1
+	MaxRAGTokens  int                // 0 = no budget
+}
+
+// Retrieve returns the merged chunks, best first, within the token budget.
+func (p RAGPipeline) Retrieve(event Event) []Chunk {
+
+	var all []Chunk
+	for _, src := range p.Sources {
+		all = append(all, src.Retrieve(event)...)
+	}
+
+	eventWords := wordSet(event.Type + " " + event.Message)
+	for w := range eventWords {
+		if len(w) < 3 {
+			delete(eventWords, w)
+		}
+	}
+	for i := range all {
+		all[i].Score = p.relevance(all[i], eventWords)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Score > all[j].Score
+	})
+
+	var (
+		kept    []Chunk
+		seenIDs = map[string]bool{}
+		spent   int
+	)
+
+	for _, c := range all {
+
+		id := c.Source + "\x00" + c.ID
+		if c.ID != "" && seenIDs[id] {
+			continue
+		}
+
+		if isNearDuplicate(c, kept) {
+			continue
+		}
+
+		cost := estimateTokens(c.Text)
+		if p.MaxRAGTokens > 0 && spent+cost > p.MaxRAGTokens {
+			continue
+		}
+
+		seenIDs[id] = true
+		kept = append(kept, c)
+		spent += cost
+	}
+
+	return kept
+}
+
+// relevance averages the source's own score with the share of event
+// words the chunk mentions, scaled by the source weight.
+func (p RAGPipeline) relevance(c Chunk, eventWords map[string]bool) float64 {
+
+	overlap := 0.0
+	if len(eventWords) > 0 {
+		text := strings.ToLower(c.Text)
+		hits := 0
+		for w := range eventWords {
+			if containsWord(text, w, 1) {
+				hits++
+			}
+		}
+		overlap = float64(hits) / float64(len(eventWords))
+	}
+
+	weight := 1.0
+	if w, ok := p.SourceWeights[c.Source]; ok {
+		weight = w
+	}
+
+	return weight * (c.Score + overlap) / 2
+}
+
+// isNearDuplicate compares c with the chunks already kept. CVE lines are
+// only deduplicated by ID: two CVEs with near-identical lines are still
+// two vulnerabilities.
+func isNearDuplicate(c Chunk, kept []Chunk) bool {
+
+	for _, k := range kept {
+		if c.Source == cveSourceName && k.Source == cveSourceName {
+			continue
+		}
+		if descriptionOverlap(c.Text, k.Text) >= nearDuplicateSimilarity {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Block renders the merged chunks as the prompt's <Rag> block, or ""
+// when there are none.
+func (p RAGPipeline) Block(event Event) string {
+	return renderRagBlock(p.Retrieve(event))
+}
+
+// renderRagBlock writes one line per chunk. CVE lines are written as-is;
+// other sources are labelled so the model can tell them apart.
+func renderRagBlock(chunks []Chunk) string {
+
+	if len(chunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<Rag>\n")
+
+	for _, c := range chunks {
+		if c.Source != cveSourceName {
+			b.WriteString("[" + c.Source + "] ")
+		}
+		// One line per chunk: the budgeter trims the block line by line
+		b.WriteString(strings.Join(strings.Fields(c.Text), " "))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("</Rag>\n")
+
+	block := b.String()
+	ragBlockTokens.Observe(float64(estimateTokens(block)))
+
+	return block
+}
+
+// renderedCVEIDs lists the CVE IDs written into block, in block order.
+// A grouped line ("CVE-A, CVE-B - ...") yields each of its IDs.
+func renderedCVEIDs(block string) []string {
+
+	var ids []string
+
+	for _, line := range ragLines(block) {
+		if strings.HasPrefix(line, "[") {
+			continue // labelled line of another source
+		}
+
+		head, _, _ := strings.Cut(line, " - ")
+		for _, id := range strings.Split(head, ", ") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// buildRagBlock assembles the <Rag> block for one analysis from the CVEs
+// the dispatcher selected and the configured snippets. Classify mode and
+// rag=off send no context at all.
+func buildRagBlock(event Event, cves []CVE) string {
+
+	if event.Mode == ModeClassify || !FeatureEnabled(FlagRAG) {
+		return ""
+	}
+
+	return ragPipelineFor(cves).Block(event)
+}
+
+// ragPipelineFor is the pipeline for one analysis.
+func ragPipelineFor(cves []CVE) RAGPipeline {
+
+	sources := []RAGSource{CVESource{CVEs: cves}}
+	if s := activeSnippetSource(); s != nil {
+		sources = append(sources, s)
+	}
+
+	return RAGPipeline{
+		Sources:       sources,
+		SourceWeights: ragSourceWeights(),
+		MaxRAGTokens:  envInt("RAG_MAX_TOKENS", 400),
+	}
+}
+
+// ragSourceWeights reads RAG_SOURCE_WEIGHTS ("cve=1,manual=0.8").
+func ragSourceWeights() map[string]float64 {
+
+	weights := map[string]float64{}
+
+	for source, raw := range envMap("RAG_SOURCE_WEIGHTS") {
+		w, err := strconv.ParseFloat(raw, 64)
+		if err != nil || w < 0 {
+			LogFields("⚠️ Invalid RAG source weight — ignoring", "source", source, "value", raw)
+			continue
+		}
+		weights[source] = w
+	}
+
+	return weights
+}
+
+/* ---------------- CVE SOURCE ---------------- */
+
+const cveSourceName = "cve"
+
+// CVESource serves already selected CVEs, deduplicated, ranked and
+// optionally grouped by advisory. The pipeline's token budget, not a
+// fixed count, decides how many make it into the block.
+type CVESource struct {
+	CVEs []CVE
+}
+
+func (CVESource) Name() string { return cveSourceName }
+
+func (s CVESource) Retrieve(Event) []Chunk {
+
+	if len(s.CVEs) == 0 {
+		return nil
+	}
+
+	// dedupeCVEs returns a copy: batches share one CVE list across goroutines
+	items := dedupeCVEs(s.CVEs)
+
+	// Highest priority first (CVSS, KEV, EPSS, recency)
+	rankCVEs(items)
+
+	if FeatureEnabled(FlagRAGGrouping) {
+		items = groupRelatedCVEs(items)
+	}
+
+	w := loadRankWeights()
+	total := w.CVSS + w.Recency + w.KEV + w.EPSS
+	now := time.Now()
+
+	chunks := make([]Chunk, 0, len(items))
+	for _, c := range items {
+
+		score := cvePriority(c, w, now)
+		if total > 0 {
+			score /= total
+		}
+
+		chunks = append(chunks, Chunk{
+			Source: cveSourceName,
+			ID:     c.ID,
+			Text:   formatRagLine(c),
+			Score:  score,
+		})
+	}
+
+	return chunks
+}
+
+/* ---------------- SNIPPET SOURCE ---------------- */
+
+// ragSnippet is one entry of the RAG_SNIPPETS_PATH JSON array. It is
+// offered when any keyword appears in the event type or message.
+type ragSnippet struct {
+	ID       string   `json:"id"`
+	Source   string   `json:"source"` // label, e.g. "manual" or "incident"
+	Text     string   `json:"text"`
+	Keywords []string `json:"keywords"`
+}
+
+// snippetSource matches snippets by keyword; the score is the share of
+// a snippet's keywords found in the event.
+type snippetSource struct {
+	snippets []ragSnippet
+}
+
+func (snippetSource) Name() string { return "snippets" }
+
+func (s snippetSource) Retrieve(event Event) []Chunk {
+
+	text := strings.ToLower(event.Type + " " + event.Message)
+
+	var chunks []Chunk
+	for _, sn := range s.snippets {
+
+		if len(sn.Keywords) == 0 {
+			continue
+		}
+
+		hits := 0
+		for _, k := range sn.Keywords {
+			if containsWord(text, k, 1) {
+				hits++
+			}
+		}
+
+		if hits == 0 {
+			continue
+		}
+
+		chunks = append(chunks, Chunk{
+			Source: sn.Source,
+			ID:     sn.ID,
+			Text:   sn.Text,
+			Score:  float64(hits) / float64(len(sn.Keywords)),
+		})
+	}
+
+	return chunks
+}
+
+var (
+	snippetsOnce sync.Once
+	snippets     *snippetSource
+)
+
+// activeSnippetSource loads RAG_SNIPPETS_PATH once; nil when unset or
+// unreadable.
+func activeSnippetSource() *snippetSource {
+
+	snippetsOnce.Do(func() {
+
+		path := envString("RAG_SNIPPETS_PATH", "")
+		if path == "" {
+			return
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			LogFields("⚠️ RAG snippets unreadable — skipping", "path", path, "error", err)
+			return
+		}
+
+		var list []ragSnippet
+		if err := json.Unmarshal(raw, &list); err != nil {
+			LogFields("⚠️ RAG snippets invalid — skipping", "path", path, "error", err)
+			return
+		}
+
+		for i := range list {
+			if list[i].Source == "" {
+				list[i].Source = "snippet"
+			}
+		}
+
+		snippets = &snippetSource{snippets: list}
+		LogFields("📚 RAG snippets loaded", "path", path, "snippets", len(list))
+	})
+
+	return snippets
+}
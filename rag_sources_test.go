@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// mockSource is a RAGSource serving fixed chunks.
+type mockSource struct {
+	name   string
+	chunks []Chunk
+}
+
+func (m mockSource) Name() string { return m.name }
+
+func (m mockSource) Retrieve(Event) []Chunk {
+
+	out := make([]Chunk, len(m.chunks))
+	for i, c := range m.chunks {
+		c.Source = m.name
+		out[i] = c
+	}
+
+	return out
+}
+
+// chunkIDs lists the chunks as "source:id".
+func chunkIDs(chunks []Chunk) []string {
+
+	ids := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		ids = append(ids, c.Source+":"+c.ID)
+	}
+
+	return ids
+}
+
+/* ---------------- RAG SOURCES ---------------- */
+
+func TestPipelineMergesAndRanksSources(t *testing.T) {
+
+	manuals := mockSource{name: "manual", chunks: []Chunk{
+		{ID: "m1", Text: "Replace the SFP optic when an interface flaps repeatedly", Score: 0.9},
+		{ID: "m2", Text: "Licensing overview for the platform", Score: 0.2},
+	}}
+	incidents := mockSource{name: "incident", chunks: []Chunk{
+		{ID: "i1", Text: "Interface flaps on core switch fixed by reseating the optic", Score: 0.8},
+		{ID: "i2", Text: "Printer queue stuck after driver update", Score: 0.1},
+	}}
+
+	p := RAGPipeline{Sources: []RAGSource{manuals, incidents}}
+	event := Event{Type: "link_flap", Message: "Interface Gi0/1 flaps, optic errors"}
+
+	got := chunkIDs(p.Retrieve(event))
+	if fmt.Sprint(got) != "[manual:m1 incident:i1 manual:m2 incident:i2]" {
+		t.Errorf("merged order = %v, want both sources' relevant chunks first", got)
+	}
+
+	block := p.Block(event)
+	if !containsAll(block, "<Rag>\n", "[manual] Replace the SFP optic", "[incident] Interface flaps on core switch", "</Rag>\n") {
+		t.Errorf("block:\n%s", block)
+	}
+	if strings.Index(block, "[manual] Replace") > strings.Index(block, "[manual] Licensing") {
+		t.Errorf("block is not ranked best first:\n%s", block)
+	}
+}
+
+/* ---------------- RANKING, DEDUP AND BUDGET ---------------- */
+
+func TestPipelineBudgetSkipsLongChunkNotRest(t *testing.T) {
+
+	long := strings.Repeat("generic platform documentation text ", 20)
+	src := mockSource{name: "manual", chunks: []Chunk{
+		{ID: "long", Text: "interface down " + long, Score: 0.9},
+		{ID: "short", Text: "interface down: reseat the optic", Score: 0.8},
+		{ID: "tail", Text: "check interface counters", Score: 0.1},
+	}}
+
+	p := RAGPipeline{Sources: []RAGSource{src}, MaxRAGTokens: 30}
+	got := chunkIDs(p.Retrieve(Event{Type: "link_down", Message: "interface down"}))
+
+	if fmt.Sprint(got) != "[manual:short manual:tail]" {
+		t.Errorf("kept %v, want the short chunks around the over-budget one", got)
+	}
+}
+
+func TestPipelineDropsNearDuplicates(t *testing.T) {
+
+	manuals := mockSource{name: "manual", chunks: []Chunk{
+		{ID: "m1", Text: "Reseat the SFP optic and clean the fiber connector", Score: 0.9},
+	}}
+	incidents := mockSource{name: "incident", chunks: []Chunk{
+		{ID: "i1", Text: "reseat the SFP optic and clean the fiber connector now", Score: 0.5},
+		{ID: "i2", Text: "Replaced the line card after repeated CRC errors", Score: 0.5},
+	}}
+	cves := mockSource{name: cveSourceName, chunks: []Chunk{
+		{ID: "CVE-2024-0001", Text: "CVE-2024-0001 - cisco/ios - CVSS 9.8", Score: 0.5},
+		{ID: "CVE-2024-0002", Text: "CVE-2024-0002 - cisco/ios - CVSS 9.8", Score: 0.5},
+	}}
+
+	p := RAGPipeline{Sources: []RAGSource{manuals, incidents, cves}}
+	got := chunkIDs(p.Retrieve(Event{Type: "link_flap", Message: "optic errors"}))
+
+	if strings.Contains(fmt.Sprint(got), "incident:i1") {
+		t.Errorf("near-duplicate kept: %v", got)
+	}
+	if !containsAll(fmt.Sprint(got), "manual:m1", "incident:i2", "cve:CVE-2024-0001", "cve:CVE-2024-0002") {
+		t.Errorf("distinct chunks dropped: %v", got)
+	}
+}
+
+func TestRenderedCVEIDs(t *testing.T) {
+
+	block := "<Rag>\n" +
+		"CVE-2024-0001, CVE-2024-0002 - cisco/ios - CVSS 9.8\n" +
+		"[manual] Reseat the optic - then check counters\n" +
+		"CVE-2024-0003 - juniper/junos - CVSS N/A\n" +
+		"</Rag>\n"
+
+	if got := fmt.Sprint(renderedCVEIDs(block)); got != "[CVE-2024-0001 CVE-2024-0002 CVE-2024-0003]" {
+		t.Errorf("renderedCVEIDs = %s", got)
+	}
+	if ids := renderedCVEIDs(""); len(ids) != 0 {
+		t.Errorf("empty block gave %v", ids)
+	}
+}
+
+func TestRAGBudgetNarrowsResponseCVEs(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: true})
+	t.Setenv("INCLUDE_RAG_CONTEXT", "true")
+	t.Setenv("RAG_MIN_RELEVANCE", "")
+	t.Setenv("RAG_MAX_TOKENS", "25") // two CVE lines
+	useRecentCVEs(t, ciscoCVEs(5))
+
+	var prompt string
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		prompt = payload.Input
+		generationReply(`{"severity":"high"}`)(w, r)
+	})
+
+	rag := postForRAGContext(t, "cisco ios_xe web UI exploited")
+
+	if len(rag.CVEs) == 0 || len(rag.CVEs) >= 5 {
+		t.Fatalf("rag_context has %d CVEs, want the budgeted subset of 5", len(rag.CVEs))
+	}
+
+	cited := map[string]bool{}
+	for _, c := range rag.CVEs {
+		cited[c.ID] = true
+		if !strings.Contains(prompt, c.ID) {
+			t.Errorf("rag_context cites %s, which the prompt lacks", c.ID)
+		}
+	}
+	for _, c := range ciscoCVEs(5) {
+		if strings.Contains(prompt, c.ID) && !cited[c.ID] {
+			t.Errorf("%s is in the prompt but not in rag_context", c.ID)
+		}
+	}
+}
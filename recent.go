@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/* ======================================================
+   🔥 RECENT ANALYSES
+   ======================================================
+
+   The last RECENT_BUFFER_SIZE event/result pairs are kept
+   in memory and served at GET /debug/recent (admin token
+   required), so a bad answer can be inspected without
+   grepping logs. REDACT_DEBUG=true blanks event messages.
+*/
+
+const redactedMessage = "[redacted]"
+
+type recentEntry struct {
+	RecordedAt string          `json:"recorded_at"`
+	LatencyMS  int64           `json:"latency_ms"`
+	Event      Event           `json:"event"`
+	Result     UnifiedResponse `json:"result"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// recentBuffer is a fixed-size ring; once full, each add overwrites the
+// oldest entry.
+type recentBuffer struct {
+	mu      sync.Mutex
+	entries []recentEntry
+	next    int
+	full    bool
+}
+
+func newRecentBuffer(size int) *recentBuffer {
+	return &recentBuffer{entries: make([]recentEntry, size)}
+}
+
+func (b *recentBuffer) add(e recentEntry) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// snapshot returns the entries newest first.
+func (b *recentBuffer) snapshot() []recentEntry {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := b.next
+	if b.full {
+		n = len(b.entries)
+	}
+
+	out := make([]recentEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, b.entries[(b.next-i+len(b.entries))%len(b.entries)])
+	}
+
+	return out
+}
+
+var (
+	recentOnce sync.Once
+	recent     *recentBuffer
+)
+
+// getRecentBuffer returns the buffer, or nil when RECENT_BUFFER_SIZE is
+// 0 (default 100).
+func getRecentBuffer() *recentBuffer {
+
+	recentOnce.Do(func() {
+		if size := envInt("RECENT_BUFFER_SIZE", 100); size > 0 {
+			recent = newRecentBuffer(size)
+		}
+	})
+
+	return recent
+}
+
+// recordRecent adds one analysis to the buffer. Raw model output is left
+// out; messages are redacted with REDACT_DEBUG.
+func recordRecent(event Event, result UnifiedResponse, latency time.Duration, analyzeErr error) {
+
+	b := getRecentBuffer()
+	if b == nil {
+		return
+	}
+
+	result.RawOutput = ""
+	if envBool("REDACT_DEBUG", false) {
+		event.Message = redactedMessage
+	}
+
+	e := recentEntry{
+		RecordedAt: analyzedAt(),
+		LatencyMS:  latency.Milliseconds(),
+		Event:      event,
+		Result:     result,
+	}
+	if analyzeErr != nil {
+		e.Error = analyzeErr.Error()
+	}
+
+	b.add(e)
+}
+
+func handleRecent(c *gin.Context) {
+
+	entries := []recentEntry{}
+	if b := getRecentBuffer(); b != nil {
+		entries = b.snapshot()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":   len(entries),
+		"entries": entries,
+	})
+}
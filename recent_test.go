@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+/* ---------------- RECENT ANALYSES ---------------- */
+
+func TestRecentBufferEvictsOldest(t *testing.T) {
+
+	buf := newRecentBuffer(3)
+	for i := 1; i <= 4; i++ {
+		buf.add(recentEntry{Event: Event{Message: fmt.Sprintf("event %d", i)}})
+	}
+
+	got := buf.snapshot()
+
+	var messages []string
+	for _, e := range got {
+		messages = append(messages, e.Event.Message)
+	}
+
+	if fmt.Sprint(messages) != "[event 4 event 3 event 2]" {
+		t.Errorf("snapshot = %v, want newest first with event 1 evicted", messages)
+	}
+}
+
+func TestRecentBufferBeforeFull(t *testing.T) {
+
+	buf := newRecentBuffer(3)
+	buf.add(recentEntry{Event: Event{Message: "only"}})
+
+	if got := buf.snapshot(); len(got) != 1 || got[0].Event.Message != "only" {
+		t.Errorf("snapshot = %+v, want the single entry", got)
+	}
+}
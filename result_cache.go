@@ -0,0 +1,165 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+/* ======================================================
+   🔥 RESULT CACHE
+   ======================================================
+
+   Repeated identical alerts (a flapping interface, the same
+   auth failure) reuse the previous analysis for AI_CACHE_TTL
+   instead of another IAM + Watson round-trip. Disabled when
+   AI_CACHE_TTL is 0 (default). At most AI_CACHE_SIZE entries
+   are kept, least recently used evicted first.
+*/
+
+// Timestamps and similar volatile tokens are dropped from the message
+// before hashing, so "10:00:01 link down" and "10:00:07 link down" share
+// an entry.
+var volatileTokens = []*regexp.Regexp{
+	regexp.MustCompile(`\d{4}-\d{2}-\d{2}[t ]\d{2}:\d{2}:\d{2}(\.\d+)?(z|[+-]\d{2}:?\d{2})?`),
+	regexp.MustCompile(`\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)\s+\d{1,2}\s+\d{2}:\d{2}:\d{2}\b`),
+	regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(\.\d+)?\b`),
+	regexp.MustCompile(`\b1\d{9}(\d{3})?\b`), // Unix seconds / milliseconds
+}
+
+var resultCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "aicore_result_cache_requests_total",
+	Help: "Result cache lookups by outcome (hit or miss).",
+}, []string{"result"})
+
+type resultCacheEntry struct {
+	key      string
+	response UnifiedResponse
+	expires  time.Time
+}
+
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+}
+
+var results = &resultCache{
+	entries: map[string]*list.Element{},
+	order:   list.New(),
+}
+
+func resultCacheTTL() time.Duration {
+	return envDuration("AI_CACHE_TTL", 0)
+}
+
+var cveIDPattern = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,}\b`)
+
+// resultCacheKey hashes everything that shapes the analysis: the event
+// fields of its fingerprint (message without volatile tokens), metadata,
+// mode, severity hint, language, model and the CVEs in ragData.
+func resultCacheKey(event Event, model, ragData string) string {
+
+	fields := eventKeyFields(event)
+	for _, re := range volatileTokens {
+		fields[1] = re.ReplaceAllString(fields[1], "")
+	}
+	fields[1] = normalizeEventField(fields[1])
+
+	key := strings.Join(append(fields,
+		normalizeEventField(event.SourceIP),
+		normalizeEventField(event.Category),
+		normalizeEventField(event.Mode),
+		normalizeEventField(event.Severity),
+		normalizeEventField(event.AssetCriticality),
+		eventLanguage(event),
+		model,
+		ragCVESet(ragData),
+	), "\x00")
+
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ragCVESet lists the CVE IDs in ragData, sorted, so the same CVEs in a
+// different order share a cache entry.
+func ragCVESet(ragData string) string {
+
+	seen := map[string]bool{}
+	for _, id := range cveIDPattern.FindAllString(ragData, -1) {
+		seen[strings.ToUpper(id)] = true
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return strings.Join(ids, ",")
+}
+
+func (rc *resultCache) get(key string) (UnifiedResponse, bool) {
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	el, ok := rc.entries[key]
+	if !ok {
+		resultCacheRequests.WithLabelValues("miss").Inc()
+		return UnifiedResponse{}, false
+	}
+
+	entry := el.Value.(*resultCacheEntry)
+	if time.Now().After(entry.expires) {
+		rc.order.Remove(el)
+		delete(rc.entries, key)
+		resultCacheRequests.WithLabelValues("miss").Inc()
+		return UnifiedResponse{}, false
+	}
+
+	rc.order.MoveToFront(el)
+	resultCacheRequests.WithLabelValues("hit").Inc()
+
+	return entry.response, true
+}
+
+func (rc *resultCache) put(key string, response UnifiedResponse, ttl time.Duration) {
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry := &resultCacheEntry{key: key, response: response, expires: time.Now().Add(ttl)}
+
+	if el, ok := rc.entries[key]; ok {
+		el.Value = entry
+		rc.order.MoveToFront(el)
+		return
+	}
+
+	rc.entries[key] = rc.order.PushFront(entry)
+
+	for max := envInt("AI_CACHE_SIZE", 1000); rc.order.Len() > max && max > 0; {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*resultCacheEntry).key)
+	}
+}
+
+// PurgeResultCache drops every cached analysis.
+func PurgeResultCache() {
+
+	results.mu.Lock()
+	defer results.mu.Unlock()
+
+	results.entries = map[string]*list.Element{}
+	results.order.Init()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+/* ---------------- RESULT CACHE ---------------- */
+
+func TestIdenticalEventsCallWatsonOnce(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	t.Setenv("AI_CACHE_TTL", "1m")
+	PurgeResultCache()
+	t.Cleanup(PurgeResultCache)
+
+	var calls atomic.Int32
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		generationReply(`{"severity":"medium","explanation":"flapping"}`)(w, r)
+	})
+
+	first := postEvent(t, `{"type":"link_down","message":"10:00:01 Gi0/1 down"}`)
+	second := postEvent(t, `{"type":"Link_Down","message":"10:00:07  gi0/1 DOWN "}`)
+
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("status %d/%d", first.Code, second.Code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Watsonx calls = %d, want 1", n)
+	}
+}
+
+func TestResultCacheKey(t *testing.T) {
+
+	event := Event{Type: "link_down", Message: "Gi0/1 down", SourceHost: "sw1"}
+	rag := "CVE-2024-0001 - flaw\nCVE-2024-0002 - flaw"
+	key := resultCacheKey(event, "m", rag)
+
+	if got := resultCacheKey(event, "m", "CVE-2024-0002 - flaw\nCVE-2024-0001 - other text"); got != key {
+		t.Error("same CVE set in another order changed the key")
+	}
+
+	spanish := event
+	spanish.Language = "es"
+
+	for name, other := range map[string]string{
+		"language": resultCacheKey(spanish, "m", rag),
+		"CVE set":  resultCacheKey(event, "m", "CVE-2024-0001 - flaw"),
+		"model":    resultCacheKey(event, "other", rag),
+	} {
+		if other == key {
+			t.Errorf("different %s shares the cache key", name)
+		}
+	}
+}
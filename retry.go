@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/* ---------------- HTTP RETRY ---------------- */
+
+type retryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+
+	// RetryTimeouts also retries requests that timed out in transit
+	// (not ctx expiring), for idempotent GETs
+	RetryTimeouts bool
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// doWithRetry sends the request built by newReq, retrying retryable
+// statuses with exponential backoff and jitter. Retry-After is honored on
+// 429. Once the budget is spent the last response is returned as-is so the
+// caller reports it like any other non-200. Backoff waits end early when
+// ctx is cancelled.
+func doWithRetry(ctx context.Context, client *http.Client, policy retryPolicy, name string, newReq func() (*http.Request, error)) (*http.Response, error) {
+
+	for attempt := 0; ; attempt++ {
+
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if !policy.RetryTimeouts || !isTimeout(err) || ctx.Err() != nil || attempt >= policy.MaxRetries {
+				return nil, err
+			}
+
+			delay := backoffDelay(policy.BaseDelay, attempt)
+
+			Logger.Printf("⚠️ %s timed out — retry %d/%d in %s",
+				name, attempt+1, policy.MaxRetries, delay)
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		if !isRetryableStatus(resp.StatusCode) || attempt >= policy.MaxRetries {
+			return resp, nil
+		}
+
+		delay := backoffDelay(policy.BaseDelay, attempt)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if ra, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = ra
+			}
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		Logger.Printf("⚠️ %s returned %d — retry %d/%d in %s",
+			name, resp.StatusCode, attempt+1, policy.MaxRetries, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+
+	if base <= 0 {
+		return 0
+	}
+
+	delay := base << attempt
+	jitter := time.Duration(rand.Int63n(int64(base)))
+
+	return delay + jitter
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date forms.
+func parseRetryAfter(v string) (time.Duration, bool) {
+
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,31 @@
+package main
+
+/* ======================================================
+   🔥 HUMAN REVIEW
+   ======================================================
+
+   Results the model is unsure of should go to an analyst
+   instead of being auto-actioned. With REVIEW_BELOW_CONFIDENCE
+   set (1–100; 0 disables, the default), results whose
+   Confidence is below it get requires_review. With
+   REVIEW_MANUAL_ACTION the recommended action is also marked
+   for manual verification, so automation keyed on it holds off.
+*/
+
+const manualVerificationPrefix = "Manual verification recommended: "
+
+// applyReviewPolicy flags result for review when its confidence is below
+// REVIEW_BELOW_CONFIDENCE.
+func applyReviewPolicy(result *UnifiedResponse) {
+
+	threshold := envInt("REVIEW_BELOW_CONFIDENCE", 0)
+	if threshold <= 0 || result.Confidence >= threshold {
+		return
+	}
+
+	result.RequiresReview = true
+
+	if envBool("REVIEW_MANUAL_ACTION", false) && result.RecommendedAction != "" {
+		result.RecommendedAction = manualVerificationPrefix + result.RecommendedAction
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+/* ---------------- HUMAN REVIEW ---------------- */
+
+func TestReviewThreshold(t *testing.T) {
+
+	t.Setenv("REVIEW_BELOW_CONFIDENCE", "60")
+	t.Setenv("REVIEW_MANUAL_ACTION", "true")
+
+	for confidence, review := range map[int]bool{59: true, 60: false, 61: false} {
+
+		result := UnifiedResponse{Confidence: confidence, RecommendedAction: "Restart the port"}
+		applyReviewPolicy(&result)
+
+		if result.RequiresReview != review {
+			t.Errorf("confidence %d: requires_review = %v, want %v", confidence, result.RequiresReview, review)
+		}
+
+		action := "Restart the port"
+		if review {
+			action = manualVerificationPrefix + action
+		}
+		if result.RecommendedAction != action {
+			t.Errorf("confidence %d: action %q, want %q", confidence, result.RecommendedAction, action)
+		}
+	}
+}
+
+func TestLowConfidenceResponseRequiresReview(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	t.Setenv("REVIEW_BELOW_CONFIDENCE", "60")
+	stubWatsonx(t, generationReply(`{"severity":"high","explanation":"maybe a loop","confidence":40}`))
+
+	w := postEvent(t, `{"type":"stp_change","message":"topology change on Vlan10"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var resp UnifiedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.RequiresReview {
+		t.Errorf("confidence %d answered without requires_review: %s", resp.Confidence, w.Body)
+	}
+}
+
+func TestConfidentClassifyNotFlagged(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	t.Setenv("REVIEW_BELOW_CONFIDENCE", "60")
+	stubWatsonx(t, generationReply(`{"severity":"high","explanation":"outage","confidence":90}`))
+
+	w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down","mode":"classify"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var resp UnifiedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Confidence != 90 || resp.RequiresReview {
+		t.Errorf("classify result: confidence %d, requires_review %v; want 90 and no review", resp.Confidence, resp.RequiresReview)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+/* ======================================================
+   🔥 RULE-BASED FALLBACK
+   ======================================================
+
+   When Watson is unreachable the fallback result is still
+   triaged: the first rule whose pattern matches the event
+   type or message sets severity and action, and the result
+   is marked source "rules". FALLBACK_RULES_PATH replaces
+   the built-in rules with a JSON array of the same shape.
+*/
+
+const sourceRules = "rules"
+
+type classifierRule struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern"` // case-insensitive regexp
+	Severity string `json:"severity"`
+	Action   string `json:"action"`
+
+	re *regexp.Regexp
+}
+
+var defaultClassifierRules = []classifierRule{
+	{Name: "device_down", Pattern: `\b(device|node|host|router|switch|firewall)\b.*\b(down|unreachable)\b`, Severity: "critical", Action: "Check power and upstream connectivity of the device"},
+	{Name: "bgp_down", Pattern: `\b(bgp|ospf)\b.*\b(down|dropped|idle|reset)\b`, Severity: "high", Action: "Check the routing peer and the path to it"},
+	{Name: "link_down", Pattern: `\b(link|interface|port)\b.*\bdown\b|link_down|interface_down`, Severity: "high", Action: "Check cabling, optics and the remote port"},
+	{Name: "auth_failure", Pattern: `authentication fail|login fail|invalid (user|password)|auth_fail`, Severity: "medium", Action: "Review the source of the failed logins and lock out if repeated"},
+	{Name: "cpu_high", Pattern: `\bcpu\b.*\b(high|\d{2,3}\s*%)|cpu_high`, Severity: "medium", Action: "Identify the process or traffic driving CPU load"},
+	{Name: "memory_high", Pattern: `\bmemory\b.*\b(high|low|exhausted|\d{2,3}\s*%)`, Severity: "medium", Action: "Check for memory leaks and plan a maintenance reload"},
+	{Name: "config_change", Pattern: `config(uration)? (change|changed|modified)`, Severity: "low", Action: "Confirm the change was authorized"},
+	{Name: "link_up", Pattern: `\b(link|interface|port)\b.*\bup\b|link_up`, Severity: "info", Action: "No action needed"},
+}
+
+var (
+	rulesOnce       sync.Once
+	classifierRules []classifierRule
+)
+
+func activeClassifierRules() []classifierRule {
+
+	rulesOnce.Do(func() {
+		classifierRules = loadClassifierRules(envString("FALLBACK_RULES_PATH", ""))
+	})
+
+	return classifierRules
+}
+
+// loadClassifierRules reads the rule file at path, falling back to the
+// built-in rules when path is empty or the file is unusable.
+func loadClassifierRules(path string) []classifierRule {
+
+	builtIn, _ := compileRules(defaultClassifierRules)
+
+	if path == "" {
+		return builtIn
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		LogFields("⚠️ Fallback rules unreadable — using built-in", "path", path, "error", err)
+		return builtIn
+	}
+
+	var rules []classifierRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		LogFields("⚠️ Fallback rules invalid — using built-in", "path", path, "error", err)
+		return builtIn
+	}
+
+	compiled, err := compileRules(rules)
+	if err != nil {
+		LogFields("⚠️ Fallback rules invalid — using built-in", "path", path, "error", err)
+		return builtIn
+	}
+
+	LogFields("📏 Fallback rules loaded", "path", path, "rules", len(compiled))
+	return compiled
+}
+
+// compileRules compiles each pattern and checks each severity.
+func compileRules(rules []classifierRule) ([]classifierRule, error) {
+
+	out := make([]classifierRule, 0, len(rules))
+
+	for _, r := range rules {
+
+		re, err := regexp.Compile("(?i)" + r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+
+		if severityRank[r.Severity] == 0 {
+			return nil, fmt.Errorf("rule %q: invalid severity %q", r.Name, r.Severity)
+		}
+
+		r.re = re
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+// classifyByRules triages event with the first matching rule; ok is false
+// when none matches.
+func classifyByRules(event Event) (UnifiedResponse, bool) {
+
+	text := event.Type + "\n" + event.Message
+
+	for _, r := range activeClassifierRules() {
+
+		if !r.re.MatchString(text) {
+			continue
+		}
+
+		return UnifiedResponse{
+			Severity:          r.Severity,
+			Explanation:       fmt.Sprintf("Rule-based triage (%s); AI analysis unavailable", r.Name),
+			RecommendedAction: r.Action,
+			Source:            sourceRules,
+		}, true
+	}
+
+	return UnifiedResponse{}, false
+}
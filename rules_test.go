@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/* ---------------- RULE-BASED FALLBACK ---------------- */
+
+func TestClassifyByRules(t *testing.T) {
+
+	tests := []struct {
+		event    Event
+		severity string
+	}{
+		{Event{Type: "syslog", Message: "Interface GigabitEthernet0/1 changed state to down"}, "high"},
+		{Event{Type: "syslog", Message: "BGP neighbor 10.0.0.1 Down - hold timer expired"}, "high"},
+		{Event{Type: "auth", Message: "Authentication failed for user admin from 10.1.1.1"}, "medium"},
+		{Event{Type: "cpu_high", Message: "CPU utilization 97%"}, "medium"},
+		{Event{Type: "syslog", Message: "Router core-1 unreachable"}, "critical"},
+		{Event{Type: "syslog", Message: "Interface Gi0/1 changed state to up"}, "info"},
+	}
+
+	for _, tt := range tests {
+		got, ok := classifyByRules(tt.event)
+		if !ok {
+			t.Errorf("%q matched no rule", tt.event.Message)
+			continue
+		}
+		if got.Severity != tt.severity || got.Source != sourceRules || got.RecommendedAction == "" {
+			t.Errorf("%q → %+v, want %s from rules with an action", tt.event.Message, got, tt.severity)
+		}
+	}
+
+	if got, ok := classifyByRules(Event{Type: "syslog", Message: "NTP clock synchronized"}); ok {
+		t.Errorf("unmatched event classified as %+v", got)
+	}
+}
+
+func TestRuleFallbackWhenWatsonFails(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false, FlagRuleFallback: true})
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	cfg.MaxRetries = 0
+	useWatsonConfig(t, cfg)
+
+	tests := map[string]string{
+		"Interface Gi0/1 changed state to down": "high",
+		"NTP clock synchronized":                severityUnknown,
+	}
+
+	for message, want := range tests {
+		w := postEvent(t, `{"type":"syslog","message":"`+message+`"}`)
+
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.DegradedResponse == nil {
+			t.Fatalf("%q: no degraded_response in %s", message, w.Body)
+		}
+		if got := resp.DegradedResponse.Severity; got != want {
+			t.Errorf("%q: degraded severity = %q, want %q", message, got, want)
+		}
+	}
+}
+
+func TestLoadClassifierRulesFromFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `[{"name":"ntp","pattern":"ntp .*unsynchronized","severity":"low","action":"Check the NTP servers"}]`
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := loadClassifierRules(path)
+	if len(loaded) != 1 || loaded[0].Name != "ntp" || !loaded[0].re.MatchString("NTP clock unsynchronized") {
+		t.Errorf("loaded rules = %+v", loaded)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"name":"bad","pattern":"x","severity":"purple"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadClassifierRules(path); len(got) != len(defaultClassifierRules) {
+		t.Errorf("invalid file gave %d rules, want the %d built-in", len(got), len(defaultClassifierRules))
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* ======================================================
+   🔥 SEVERITY NORMALIZATION
+   ======================================================
+
+   The model answers in free text ("Severe", "warning",
+   "P1"). Results are mapped onto critical, high, medium,
+   low or info; anything else becomes "unknown".
+*/
+
+const severityUnknown = "unknown"
+
+var severitySynonyms = map[string]string{
+	"critical": "critical", "crit": "critical", "severe": "critical",
+	"emergency": "critical", "emerg": "critical", "fatal": "critical",
+	"urgent": "critical", "p0": "critical", "p1": "critical",
+	"sev0": "critical", "sev1": "critical",
+
+	"high": "high", "major": "high", "error": "high", "important": "high",
+	"p2": "high", "sev2": "high",
+
+	"medium": "medium", "med": "medium", "moderate": "medium",
+	"warning": "medium", "warn": "medium", "p3": "medium", "sev3": "medium",
+
+	"low": "low", "minor": "low", "notice": "low",
+	"p4": "low", "sev4": "low",
+
+	"info": "info", "informational": "info", "information": "info",
+	"none": "info", "p5": "info", "sev5": "info",
+
+	// Models sometimes answer non-English events in the event's language
+	"crítico": "critical", "critico": "critical", "critique": "critical",
+	"kritisch": "critical", "grave": "critical",
+	"alto": "high", "alta": "high", "élevé": "high", "élevée": "high",
+	"hoch": "high", "elevato": "high",
+	"medio": "medium", "media": "medium", "moyen": "medium",
+	"moyenne": "medium", "mittel": "medium", "médio": "medium",
+	"bajo": "low", "baja": "low", "faible": "low", "niedrig": "low",
+	"baixo": "low", "basso": "low",
+	"informativo": "info", "informatif": "info",
+}
+
+// normalizeSeverity maps s onto the canonical severities, ignoring case,
+// spaces, "-" and "_" ("SEV-1" → critical).
+func normalizeSeverity(s string) string {
+
+	key := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(s)))
+
+	if canonical, ok := severitySynonyms[key]; ok {
+		return canonical
+	}
+
+	return severityUnknown
+}
+
+// severityRank orders the canonical severities; unknown ranks lowest.
+var severityRank = map[string]int{
+	"info": 1, "low": 2, "medium": 3, "high": 4, "critical": 5,
+}
+
+// severityAtLeast reports whether severity is at or above threshold.
+func severityAtLeast(severity, threshold string) bool {
+	return severityRank[severity] > 0 && severityRank[severity] >= severityRank[threshold]
+}
+
+/* ---------------- NUMERIC LEVELS ---------------- */
+
+// SEVERITY_SCALE selects the numbering of SeverityLevel: "priority" is
+// 1 (critical) to 5 (info) like P1–P5, "syslog" uses the RFC 5424
+// levels crit (2) to info (6). Lower is more severe on both.
+const (
+	scalePriority = "priority"
+	scaleSyslog   = "syslog"
+)
+
+var severityScales = map[string]map[string]int{
+	scalePriority: {"critical": 1, "high": 2, "medium": 3, "low": 4, "info": 5},
+	scaleSyslog:   {"critical": 2, "high": 3, "medium": 4, "low": 5, "info": 6},
+}
+
+// severityLevel is severity on SEVERITY_SCALE; 0 for unknown. An
+// unrecognized scale falls back to priority.
+func severityLevel(severity string) int {
+
+	scale, ok := severityScales[strings.ToLower(envString("SEVERITY_SCALE", scalePriority))]
+	if !ok {
+		scale = severityScales[scalePriority]
+	}
+
+	return scale[severity]
+}
+
+/* ---------------- ASSET CRITICALITY ---------------- */
+
+const (
+	criticalityLow    = "low"
+	criticalityMedium = "medium"
+	criticalityHigh   = "high"
+)
+
+// applyAssetCriticality adjusts the model's severity for the asset. The
+// model already sees the criticality in the prompt but tends to weigh the
+// event text alone, so a high-criticality asset is raised one level when
+// the model said medium or above (medium → high, high → critical). Low and
+// medium criticality, and low/info verdicts, are left to the model: noise
+// on an important asset stays noise.
+func applyAssetCriticality(severity, criticality string) string {
+
+	if criticality != criticalityHigh || !severityAtLeast(severity, "medium") {
+		return severity
+	}
+
+	switch severity {
+	case "medium":
+		return "high"
+	case "high":
+		return "critical"
+	}
+
+	return severity
+}
+
+/* ---------------- EXPLOIT ESCALATION ---------------- */
+
+// escalateForExploits keeps severity at or above EXPLOIT_ESCALATION_FLOOR
+// (default high; "off" disables) when a matched CVE is in the CISA KEV
+// catalog or has an EPSS probability of at least EXPLOIT_ESCALATION_EPSS
+// (default 0.5; 0 ignores EPSS). The model tends to rate the event text
+// alone, and an actively exploited CVE on the device is not "low".
+// cves must be the CVEs that matched the event: a fallback CVE says
+// nothing about it. An "unknown" verdict is left alone.
+func escalateForExploits(response *UnifiedResponse, cves []CVE) {
+
+	floor := normalizeSeverity(envString("EXPLOIT_ESCALATION_FLOOR", "high"))
+	if severityRank[floor] == 0 || severityRank[response.Severity] == 0 || severityAtLeast(response.Severity, floor) {
+		return
+	}
+
+	epssThreshold := envFloat("EXPLOIT_ESCALATION_EPSS", 0.5)
+
+	var reason string
+	for _, c := range cves {
+		if c.KnownExploited {
+			reason = c.ID + " is in the CISA KEV catalog"
+			break
+		}
+		if reason == "" && epssThreshold > 0 && c.EPSSScore >= epssThreshold {
+			reason = fmt.Sprintf("%s has an EPSS exploit probability of %.0f%%", c.ID, c.EPSSScore*100)
+		}
+	}
+	if reason == "" {
+		return
+	}
+
+	LogFields("⬆️ Severity escalated for exploited CVE",
+		"severity", response.Severity,
+		"escalated_to", floor,
+		"reason", reason,
+	)
+
+	if response.ModelSeverity == "" {
+		response.ModelSeverity = response.Severity
+	}
+	response.Severity = floor
+	response.Explanation = strings.TrimSpace(fmt.Sprintf("%s (Escalated to %s: %s.)", response.Explanation, floor, reason))
+}
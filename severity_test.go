@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+/* ---------------- NUMERIC LEVELS ---------------- */
+
+func TestSeverityLevelScales(t *testing.T) {
+
+	want := map[string]map[string]int{
+		scalePriority: {"critical": 1, "high": 2, "medium": 3, "low": 4, "info": 5, severityUnknown: 0},
+		scaleSyslog:   {"critical": 2, "high": 3, "medium": 4, "low": 5, "info": 6, severityUnknown: 0},
+		"bogus":       {"critical": 1, "info": 5},
+	}
+
+	for scale, levels := range want {
+		t.Setenv("SEVERITY_SCALE", scale)
+		for severity, level := range levels {
+			if got := severityLevel(severity); got != level {
+				t.Errorf("%s scale: %s = %d, want %d", scale, severity, got, level)
+			}
+		}
+	}
+}
+
+/* ---------------- ASSET CRITICALITY ---------------- */
+
+func TestApplyAssetCriticality(t *testing.T) {
+
+	want := map[string]map[string]string{
+		"":                {"info": "info", "low": "low", "medium": "medium", "high": "high", "critical": "critical"},
+		criticalityLow:    {"info": "info", "low": "low", "medium": "medium", "high": "high", "critical": "critical"},
+		criticalityMedium: {"info": "info", "low": "low", "medium": "medium", "high": "high", "critical": "critical"},
+		criticalityHigh: {"info": "info", "low": "low", "medium": "high", "high": "critical", "critical": "critical",
+			severityUnknown: severityUnknown},
+	}
+
+	for criticality, bumps := range want {
+		for severity, expected := range bumps {
+			if got := applyAssetCriticality(severity, criticality); got != expected {
+				t.Errorf("criticality %q: %s → %s, want %s", criticality, severity, got, expected)
+			}
+		}
+	}
+}
+
+/* ---------------- EXPLOIT ESCALATION ---------------- */
+
+func TestKEVCVEEscalatesLowVerdict(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: true, FlagEPSS: false})
+	t.Setenv("EXPLOIT_ESCALATION_FLOOR", "")
+	stubWatsonx(t, generationReply(`{"severity":"low","explanation":"single reload"}`))
+
+	exploited := ciscoIOS
+	useKEVCatalog(t, exploited.ID)
+	items := []CVE{exploited}
+	markKnownExploited(items)
+	useRecentCVEs(t, items)
+
+	analyze := func(message string) UnifiedResponse {
+		w := postEvent(t, `{"type":"reload","message":"`+message+`"}`)
+		var resp UnifiedResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		return resp
+	}
+
+	resp := analyze("cisco ios crashed and reloaded")
+	if resp.Severity != "high" || resp.ModelSeverity != "low" || !strings.Contains(resp.Explanation, exploited.ID+" is in the CISA KEV catalog") {
+		t.Errorf("matched KEV CVE: severity %q (model %q), explanation %q", resp.Severity, resp.ModelSeverity, resp.Explanation)
+	}
+
+	// The same CVE as a priority fallback says nothing about the event
+	resp = analyze("fan tray 2 removed")
+	if resp.Severity != "low" || resp.ModelSeverity != "" {
+		t.Errorf("fallback KEV CVE escalated: severity %q (model %q)", resp.Severity, resp.ModelSeverity)
+	}
+}
+
+func TestEscalationLeavesUnknownAlone(t *testing.T) {
+
+	t.Setenv("EXPLOIT_ESCALATION_FLOOR", "high")
+
+	kev := CVE{ID: "CVE-2024-0001", KnownExploited: true}
+	for severity, want := range map[string]string{"low": "high", "critical": "critical", severityUnknown: severityUnknown} {
+		response := UnifiedResponse{Severity: severity}
+		escalateForExploits(&response, []CVE{kev})
+		if response.Severity != want {
+			t.Errorf("%s → %s, want %s", severity, response.Severity, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+)
+
+/* ---------------- RESPONSE SIGNING ---------------- */
+
+// signResponse sets Signature when RESPONSE_SIGNING_KEY is configured.
+//
+// The signature is hex(HMAC-SHA256(key, canonical)), where canonical is
+// the compact JSON encoding of the response with "signature" omitted.
+// Fields are encoded in struct order and empty optional fields are left
+// out, so a verifier re-encodes the received object the same way (drop
+// "signature", keep key order, no whitespace) and compares.
+func signResponse(r *UnifiedResponse) {
+
+	key := os.Getenv("RESPONSE_SIGNING_KEY")
+	if key == "" {
+		return
+	}
+
+	r.Signature = computeSignature(*r, []byte(key))
+}
+
+func computeSignature(r UnifiedResponse, key []byte) string {
+
+	r.Signature = ""
+
+	canonical, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(canonical)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+// verifySignature checks r.Signature the way a consumer would.
+func verifySignature(r UnifiedResponse, key string) bool {
+
+	got, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return false
+	}
+
+	want, _ := hex.DecodeString(computeSignature(r, []byte(key)))
+
+	return hmac.Equal(got, want)
+}
+
+/* ---------------- RESPONSE SIGNING ---------------- */
+
+func TestSignAndVerify(t *testing.T) {
+
+	t.Setenv("RESPONSE_SIGNING_KEY", "k1")
+
+	r := UnifiedResponse{Severity: "high", Explanation: "uplink lost", Confidence: 80}
+	signResponse(&r)
+
+	if len(r.Signature) != 64 {
+		t.Fatalf("signature = %q, want hex SHA-256", r.Signature)
+	}
+	if !verifySignature(r, "k1") {
+		t.Error("valid signature rejected")
+	}
+	if verifySignature(r, "k2") {
+		t.Error("signature verified with the wrong key")
+	}
+}
+
+func TestTamperedResponseFailsVerification(t *testing.T) {
+
+	t.Setenv("RESPONSE_SIGNING_KEY", "k1")
+
+	r := UnifiedResponse{Severity: "high", Explanation: "uplink lost"}
+	signResponse(&r)
+
+	r.Severity = "low"
+	if verifySignature(r, "k1") {
+		t.Error("tampered response verified")
+	}
+}
+
+func TestSigningOffWithoutKey(t *testing.T) {
+
+	t.Setenv("RESPONSE_SIGNING_KEY", "")
+
+	r := UnifiedResponse{Severity: "high"}
+	signResponse(&r)
+
+	if r.Signature != "" {
+		t.Errorf("signed without a key: %q", r.Signature)
+	}
+}
+
+func TestSignatureHeader(t *testing.T) {
+
+	t.Setenv("RESPONSE_SIGNING_KEY", "k1")
+	setFlags(t, map[string]bool{FlagRAG: false})
+	stubWatsonx(t, generationReply(`{"severity":"low","explanation":"x","recommended_action":"y"}`))
+
+	w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`)
+	if w.Code != http.StatusOK || len(w.Header().Get("X-Signature")) != 64 {
+		t.Errorf("status %d, X-Signature %q", w.Code, w.Header().Get("X-Signature"))
+	}
+}
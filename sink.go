@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/* ======================================================
+   🔥 RESULT FILE SINK
+   ======================================================
+
+   For air-gapped deployments every analyzed event can be
+   appended as one JSON line to RESULT_SINK_PATH and
+   uploaded later. The file is rotated (renamed with a
+   timestamp suffix) once it reaches RESULT_SINK_MAX_BYTES
+   or is older than RESULT_SINK_MAX_AGE.
+*/
+
+type sinkRecord struct {
+	RecordedAt string          `json:"recorded_at"`
+	Event      Event           `json:"event"`
+	Result     UnifiedResponse `json:"result"`
+	Error      string          `json:"error,omitempty"`
+}
+
+type resultSink struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxAge   time.Duration
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+var (
+	sinkOnce sync.Once
+	sink     *resultSink
+)
+
+// getResultSink returns the configured sink, or nil when
+// RESULT_SINK_PATH is unset.
+func getResultSink() *resultSink {
+
+	sinkOnce.Do(func() {
+
+		path := envString("RESULT_SINK_PATH", "")
+		if path == "" {
+			return
+		}
+
+		sink = &resultSink{
+			path:     path,
+			maxBytes: int64(envInt("RESULT_SINK_MAX_BYTES", 100<<20)),
+			maxAge:   envDuration("RESULT_SINK_MAX_AGE", 24*time.Hour),
+		}
+
+		Logger.Printf("🗄️ Result sink enabled: %s", path)
+	})
+
+	return sink
+}
+
+// recordResult appends the event and its result to the sink, if any.
+// Raw model output is left out to keep lines small.
+func recordResult(event Event, result UnifiedResponse, analyzeErr error) {
+
+	s := getResultSink()
+	if s == nil {
+		return
+	}
+
+	result.RawOutput = ""
+
+	rec := sinkRecord{
+		RecordedAt: analyzedAt(),
+		Event:      event,
+		Result:     result,
+	}
+	if analyzeErr != nil {
+		rec.Error = analyzeErr.Error()
+	}
+
+	if err := s.write(rec); err != nil {
+		Logger.Printf("⚠️ Result sink write failed: %v", err)
+	}
+}
+
+func (s *resultSink) write(rec sinkRecord) error {
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil && s.needsRotation(int64(len(line))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+
+	return err
+}
+
+func (s *resultSink) needsRotation(next int64) bool {
+
+	if s.maxBytes > 0 && s.size > 0 && s.size+next > s.maxBytes {
+		return true
+	}
+
+	return s.maxAge > 0 && time.Since(s.openedAt) >= s.maxAge
+}
+
+func (s *resultSink) open() error {
+
+	if dir := filepath.Dir(s.path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.file = f
+	s.size = info.Size()
+	s.openedAt = time.Now()
+
+	// An existing file keeps its age across restarts
+	if info.Size() > 0 {
+		s.openedAt = info.ModTime()
+	}
+
+	return nil
+}
+
+// rotate closes the current file and renames it to
+// <path>.<UTC timestamp>; the next write opens a fresh file.
+func (s *resultSink) rotate() error {
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	rotated := fmt.Sprintf("%s.%s", s.path, time.Now().UTC().Format("20060102T150405.000000000Z"))
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+
+	Logger.Printf("🔄 Result sink rotated to %s", rotated)
+	return nil
+}
+
+// closeResultSink flushes and closes the sink file on shutdown.
+func closeResultSink() {
+
+	if sink == nil {
+		return
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.file != nil {
+		sink.file.Close()
+		sink.file = nil
+	}
+}
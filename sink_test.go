@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readJSONLines decodes every line of path as a sinkRecord.
+func readJSONLines(t *testing.T, path string) []sinkRecord {
+
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var recs []sinkRecord
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec sinkRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+
+	return recs
+}
+
+/* ---------------- RESULT FILE SINK ---------------- */
+
+func TestSinkWritesValidJSONLines(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	s := &resultSink{path: path}
+	defer func() { s.file.Close() }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.write(sinkRecord{Event: Event{Type: "link_down", Message: "Gi0/1 down"}, Result: UnifiedResponse{Severity: "high"}})
+		}()
+	}
+	wg.Wait()
+
+	if recs := readJSONLines(t, path); len(recs) != 20 || recs[0].Result.Severity != "high" {
+		t.Errorf("got %d records, want 20 complete lines", len(recs))
+	}
+}
+
+func TestSinkRotatesBySize(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.jsonl")
+	s := &resultSink{path: path, maxBytes: 300}
+	defer func() { s.file.Close() }()
+
+	for i := 0; i < 5; i++ {
+		if err := s.write(sinkRecord{Event: Event{Type: "link_down", Message: "Gi0/1 down"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) == 0 {
+		t.Fatal("no rotation past RESULT_SINK_MAX_BYTES")
+	}
+
+	total := len(readJSONLines(t, path))
+	for _, r := range rotated {
+		total += len(readJSONLines(t, r))
+		if info, _ := os.Stat(r); info.Size() > 300 {
+			t.Errorf("%s is %d bytes, over the limit", r, info.Size())
+		}
+	}
+	if total != 5 {
+		t.Errorf("%d records across files, want 5", total)
+	}
+}
+
+func TestSinkRotatesByAge(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	s := &resultSink{path: path, maxAge: time.Hour}
+	defer func() { s.file.Close() }()
+
+	if err := s.write(sinkRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.openedAt = time.Now().Add(-2 * time.Hour)
+	if err := s.write(sinkRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated, _ := filepath.Glob(path + ".*"); len(rotated) != 1 {
+		t.Errorf("rotated files = %v, want one past RESULT_SINK_MAX_AGE", rotated)
+	}
+}
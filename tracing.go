@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/* ======================================================
+   🔥 TRACING
+   ======================================================
+
+   OpenTelemetry spans for event → CVE selection → IAM →
+   Watson generation. Export is enabled by setting
+   OTEL_EXPORTER_OTLP_ENDPOINT (OTLP over HTTP); without it
+   spans are no-ops. Inbound W3C traceparent headers are
+   always honoured.
+*/
+
+const tracerName = "agents_api"
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// InitTracing installs the OTLP exporter when configured and returns a
+// function flushing pending spans on shutdown.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if envString("OTEL_EXPORTER_OTLP_ENDPOINT", "") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Endpoint, headers and service name come from the standard OTEL_* env
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+
+	Logger.Println("🔭 OpenTelemetry tracing enabled")
+
+	return provider.Shutdown, nil
+}
+
+// traceRequests starts the root span of an event request, continuing
+// the caller's trace when it sent trace context headers.
+func traceRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		ctx, span := tracer().Start(ctx, "POST "+c.FullPath(), trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+}
+
+// startSpan starts a child span of whatever span ctx carries.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err (if any) and ends the span; use with defer.
+func endSpan(span trace.Span, err *error) {
+
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+
+	span.End()
+}
+
+// shutdownTracing flushes spans, giving up after 5 seconds.
+func shutdownTracing(flush func(context.Context) error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := flush(ctx); err != nil {
+		Logger.Printf("⚠️ Trace flush failed: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs an in-memory span recorder for the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+
+	t.Helper()
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return sr
+}
+
+/* ---------------- OPENTELEMETRY SPANS ---------------- */
+
+func TestEventSpanTree(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: true})
+	useRecentCVEs(t, ciscoCVEs(2))
+	stubWatsonx(t, generationReply(`{"severity":"high","explanation":"x","recommended_action":"y"}`))
+
+	sr := recordSpans(t)
+
+	router := gin.New()
+	router.POST("/events", traceRequests(), handleEvent)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"type":"crash","message":"Cisco IOS XE crashed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range sr.Ended() {
+		spans[s.Name()] = s
+	}
+
+	root, ok := spans["POST /events"]
+	if !ok {
+		t.Fatalf("no root span; got %v", spanNames(sr.Ended()))
+	}
+	if root.SpanContext().TraceID().String() != traceID {
+		t.Errorf("root trace %s, want the inbound %s", root.SpanContext().TraceID(), traceID)
+	}
+
+	for _, name := range []string{"cve.select", "iam.token", "watson.generate"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("%s span missing; got %v", name, spanNames(sr.Ended()))
+			continue
+		}
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the event span", name)
+		}
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range spans["cve.select"].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["cve.count"] != "2" {
+		t.Errorf("cve.select attributes = %v, want cve.count=2", attrs)
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+
+	return names
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+/* ======================================================
+   🔥 PER-MODEL USAGE
+   ======================================================
+
+   Request and token counts per model for cost allocation,
+   served on GET /stats. With MODEL_USAGE_WINDOW set (e.g.
+   24h) the /stats counters restart every window; 0 keeps
+   them cumulative. Prometheus counters are always
+   cumulative.
+*/
+
+type modelUsage struct {
+	Requests        int64 `json:"requests"`
+	InputTokens     int64 `json:"input_tokens"`
+	GeneratedTokens int64 `json:"generated_tokens"`
+}
+
+type usageTracker struct {
+	mu            sync.Mutex
+	models        map[string]*modelUsage
+	windowStarted time.Time
+}
+
+var usage = &usageTracker{
+	models:        map[string]*modelUsage{},
+	windowStarted: time.Now(),
+}
+
+var (
+	modelRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aicore_model_requests_total",
+		Help: "Generation requests per model.",
+	}, []string{"model"})
+
+	modelTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aicore_model_tokens_total",
+		Help: "Tokens per model, by kind (input or generated).",
+	}, []string{"model", "kind"})
+)
+
+// TokenUsage is what one analysis consumed, as reported by Watsonx.
+type TokenUsage struct {
+	Model           string `json:"model"`
+	InputTokens     int    `json:"input_tokens"`
+	GeneratedTokens int    `json:"generated_tokens"`
+}
+
+func (u *TokenUsage) add(o TokenUsage) {
+	if o.Model != "" {
+		u.Model = o.Model
+	}
+	u.InputTokens += o.InputTokens
+	u.GeneratedTokens += o.GeneratedTokens
+}
+
+// recordModelUsage counts one generation request against its model.
+func recordModelUsage(tu TokenUsage) {
+
+	model, inputTokens, generatedTokens := tu.Model, tu.InputTokens, tu.GeneratedTokens
+
+	modelRequests.WithLabelValues(model).Inc()
+	modelTokens.WithLabelValues(model, "input").Add(float64(inputTokens))
+	modelTokens.WithLabelValues(model, "generated").Add(float64(generatedTokens))
+
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+
+	usage.rollWindowLocked()
+
+	u, ok := usage.models[model]
+	if !ok {
+		u = &modelUsage{}
+		usage.models[model] = u
+	}
+
+	u.Requests++
+	u.InputTokens += int64(inputTokens)
+	u.GeneratedTokens += int64(generatedTokens)
+}
+
+// rollWindowLocked starts a new window once MODEL_USAGE_WINDOW elapsed.
+func (t *usageTracker) rollWindowLocked() {
+
+	window := envDuration("MODEL_USAGE_WINDOW", 0)
+	if window <= 0 || time.Since(t.windowStarted) < window {
+		return
+	}
+
+	t.models = map[string]*modelUsage{}
+	t.windowStarted = time.Now()
+}
+
+// snapshot copies the current counters.
+func (t *usageTracker) snapshot() (map[string]modelUsage, time.Time) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rollWindowLocked()
+
+	out := make(map[string]modelUsage, len(t.models))
+	for model, u := range t.models {
+		out[model] = *u
+	}
+
+	return out, t.windowStarted
+}
+
+func handleStats(c *gin.Context) {
+
+	models, since := usage.snapshot()
+
+	window := "cumulative"
+	if w := envDuration("MODEL_USAGE_WINDOW", 0); w > 0 {
+		window = w.String()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"models":           models,
+		"window":           window,
+		"window_starts_at": since.UTC().Format(time.RFC3339),
+		"events_processed": eventsProcessed.Load(),
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useFreshUsage replaces the usage tracker for the test.
+func useFreshUsage(t *testing.T) {
+
+	prev := usage
+	usage = &usageTracker{models: map[string]*modelUsage{}, windowStarted: time.Now()}
+	t.Cleanup(func() { usage = prev })
+}
+
+/* ---------------- TOKEN USAGE ---------------- */
+
+func TestDecodedTokenCountsReachCounters(t *testing.T) {
+
+	useFreshUsage(t)
+
+	const model = "usage-test-model"
+	labels := func(kind string) map[string]string { return map[string]string{"model": model, "kind": kind} }
+	input, generated := sampleCount(t, "aicore_model_tokens_total", labels("input")),
+		sampleCount(t, "aicore_model_tokens_total", labels("generated"))
+
+	body := `{"results":[{"generated_text":"{}","input_token_count":120,"generated_token_count":30}]}`
+	_, tu, err := decodeGenerationResponse(WatsonConfig{ModelID: model}, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tu.InputTokens != 120 || tu.GeneratedTokens != 30 {
+		t.Errorf("usage = %+v, want 120 input / 30 generated", tu)
+	}
+	if d := sampleCount(t, "aicore_model_tokens_total", labels("input")) - input; d != 120 {
+		t.Errorf("input token counter += %v, want 120", d)
+	}
+	if d := sampleCount(t, "aicore_model_tokens_total", labels("generated")) - generated; d != 30 {
+		t.Errorf("generated token counter += %v, want 30", d)
+	}
+
+	snap, _ := usage.snapshot()
+	if got := snap[model]; got.Requests != 1 || got.InputTokens != 120 || got.GeneratedTokens != 30 {
+		t.Errorf("per-model totals = %+v", got)
+	}
+}
+
+/* ---------------- PER-MODEL USAGE ---------------- */
+
+// Run with -race: updates and snapshots from many goroutines.
+func TestConcurrentModelUsage(t *testing.T) {
+
+	useFreshUsage(t)
+	t.Setenv("MODEL_USAGE_WINDOW", "")
+
+	models := []string{"granite", "llama"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				recordModelUsage(TokenUsage{Model: models[i%2], InputTokens: 10, GeneratedTokens: 2})
+				if j%10 == 0 {
+					usage.snapshot()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, _ := usage.snapshot()
+	for _, m := range models {
+		if u := got[m]; u.Requests != 1600 || u.InputTokens != 16000 || u.GeneratedTokens != 3200 {
+			t.Errorf("%s = %+v, want 1600 requests, 16000/3200 tokens", m, u)
+		}
+	}
+}
+
+func TestModelUsageWindowResets(t *testing.T) {
+
+	useFreshUsage(t)
+	t.Setenv("MODEL_USAGE_WINDOW", "1h")
+
+	recordModelUsage(TokenUsage{Model: "granite", InputTokens: 10})
+	usage.windowStarted = time.Now().Add(-2 * time.Hour)
+
+	if got, _ := usage.snapshot(); len(got) != 0 {
+		t.Errorf("got %+v after the window elapsed, want reset counters", got)
+	}
+
+	t.Setenv("MODEL_USAGE_WINDOW", "")
+	recordModelUsage(TokenUsage{Model: "granite", InputTokens: 10})
+	usage.windowStarted = time.Now().Add(-48 * time.Hour)
+
+	if got, _ := usage.snapshot(); got["granite"].Requests != 1 {
+		t.Errorf("cumulative counters reset: %+v", got)
+	}
+}
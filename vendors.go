@@ -0,0 +1,75 @@
+package main
+
+import "strings"
+
+/* ======================================================
+   🔥 VENDOR ALIASES
+   ======================================================
+
+   Event text, CVE_VENDORS and NVD CPE data spell vendors
+   differently ("palo alto", "paloalto", "palo_alto_networks").
+   Every spelling resolves to one canonical name before
+   vendors are compared.
+*/
+
+// vendorAliases maps a canonical vendor name to its other spellings.
+// Comparisons ignore case, spaces, "-" and "_"; aliases are also looked
+// for in event text, so list the spellings people write ("tp-link").
+var vendorAliases = map[string][]string{
+	"paloalto": {"palo alto", "palo alto networks", "paloaltonetworks"},
+	"tplink":   {"tp-link", "tp_link"},
+	"dlink":    {"d-link", "d_link"},
+	"ubiquiti": {"ubiquiti networks"},
+	"arista":   {"arista networks"},
+	"juniper":  {"juniper networks"},
+}
+
+// compactVendor lowercases v and drops spaces, "-" and "_".
+func compactVendor(v string) string {
+
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(v)))
+}
+
+// canonicalVendor resolves any known spelling of a vendor to its
+// canonical name; unknown vendors come back compacted.
+func canonicalVendor(v string) string {
+
+	c := compactVendor(v)
+
+	if _, ok := vendorAliases[c]; ok {
+		return c
+	}
+
+	for canonical, aliases := range vendorAliases {
+		for _, a := range aliases {
+			if compactVendor(a) == c {
+				return canonical
+			}
+		}
+	}
+
+	return c
+}
+
+// sameVendor reports whether a and b name the same vendor.
+func sameVendor(a, b string) bool {
+	return a != "" && canonicalVendor(a) == canonicalVendor(b)
+}
+
+// vendorSpellings returns the spellings of v to look for in event text:
+// v itself, its canonical name and every alias.
+func vendorSpellings(v string) []string {
+
+	canonical := canonicalVendor(v)
+
+	out := []string{v, canonical}
+	out = append(out, vendorAliases[canonical]...)
+
+	return out
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+/* ---------------- VENDOR ALIASES ---------------- */
+
+func TestCanonicalVendor(t *testing.T) {
+
+	for spelling, want := range map[string]string{
+		"tplink":             "tplink",
+		"tp-link":            "tplink",
+		"tp_link":            "tplink",
+		"TP Link":            "tplink",
+		"palo alto":          "paloalto",
+		"paloalto":           "paloalto",
+		"palo_alto_networks": "paloalto",
+		"d-link":             "dlink",
+		"dlink":              "dlink",
+		"Cisco":              "cisco",
+	} {
+		if got := canonicalVendor(spelling); got != want {
+			t.Errorf("canonicalVendor(%q) = %q, want %q", spelling, got, want)
+		}
+	}
+}
+
+func TestSameVendor(t *testing.T) {
+
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{"tp-link", "TP Link", true},
+		{"tp_link", "tplink", true},
+		{"palo_alto_networks", "palo alto", true},
+		{"d-link", "dlink", true},
+		{"dlink", "tplink", false},
+		{"", "", false},
+		{"", "cisco", false},
+	} {
+		if got := sameVendor(c.a, c.b); got != c.want {
+			t.Errorf("sameVendor(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestVendorFromEventText(t *testing.T) {
+
+	for text, want := range map[string]string{
+		"Palo Alto PA-3220 HA failover":    "paloalto",
+		"TP-Link switch port 4 down":       "tplink",
+		"D-Link DGS-1210 reboot":           "dlink",
+		"juniper networks mx480 fpc crash": "juniper",
+		"linksys router reboot":            "",
+	} {
+		if got := extractVendorFromEvent(text); got != want {
+			t.Errorf("%q: vendor %q, want %q", text, got, want)
+		}
+	}
+}
@@ -2,41 +2,438 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+)
+
+/* ---------------- CONFIG ---------------- */
+
+type WatsonConfig struct {
+	APIKeys    []string
+	KeyWeights []int // parallel to APIKeys, from the "key:weight" form
+	Region     string
+	ProjectID  string
+	ModelID    string
+
+	// MLEndpointBase replaces the region-derived https://<region>.ml.cloud.ibm.com
+	// (dedicated, private or test environments); IAMURL is the token endpoint
+	MLEndpointBase string
+	IAMURL         string
+
+	// AllowedModels may be requested per event (Event.ModelID); ModelID
+	// itself is always allowed
+	AllowedModels []string
+
+	// Temperature must be within [0, 1]; MaxNewTokens must be positive
+	Temperature  float64
+	MaxNewTokens int
+
+	// MaxContextTokens is the model's context window; the RAG block is
+	// trimmed so prompt plus MaxNewTokens fit in it. Zero disables trimming.
+	MaxContextTokens int
+
+	// Optional sampling parameters, omitted from the payload when unset:
+	// TopP within (0, 1], RepetitionPenalty within [1, 2]
+	StopSequences     []string
+	TopP              float64
+	RepetitionPenalty float64
+
+	// Parse-failure retries escalate the temperature by TemperatureStep,
+	// never above MaxTemperature
+	ParseRetries    int
+	TemperatureStep float64
+	MaxTemperature  float64
+
+	// HTTP retries on 429 and 5xx with exponential backoff
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+
+	// UseChatAPI sends a messages payload to text/chat (chat/instruct
+	// models) instead of a plain prompt to text/generation
+	UseChatAPI bool
+
+	// EnforceJSON sends a JSON schema as response_format so the answer is
+	// valid JSON. Only chat endpoints accept one (text/chat and the
+	// OpenAI provider); text/generation has no such parameter.
+	EnforceJSON bool
+
+	// mode is the analysis mode of the event (see forEvent), selecting
+	// the EnforceJSON schema
+	mode string
+
+	// IAMTimeout bounds one token fetch and GenerationTimeout one
+	// generation call (retries included); RequestTimeout caps the whole
+	// analysis. Zero disables a limit.
+	IAMTimeout        time.Duration
+	GenerationTimeout time.Duration
+	RequestTimeout    time.Duration
+}
+
+func DefaultWatsonConfig() WatsonConfig {
+
+	keys, weights := parseAPIKeys(os.Getenv("WATSONX_API_KEYS"))
+
+	return WatsonConfig{
+		APIKeys:    keys,
+		KeyWeights: weights,
+		Region:     os.Getenv("WATSONX_REGION"),
+		ProjectID:  os.Getenv("WATSONX_PROJECT_ID"),
+		ModelID:    envString("WATSONX_MODEL_ID", "ibm/granite-3-8b-instruct"),
+
+		MLEndpointBase: strings.TrimRight(envString("WATSONX_ML_URL", ""), "/"),
+		IAMURL:         envString("WATSONX_IAM_URL", iamTokenURL),
+
+		AllowedModels: envList("WATSONX_ALLOWED_MODELS", nil),
+
+		Temperature:  clampTemperature("WATSONX_TEMPERATURE", envFloat("WATSONX_TEMPERATURE", 0.1)),
+		MaxNewTokens: envInt("WATSONX_MAX_NEW_TOKENS", defaultMaxNewTokens),
+
+		MaxContextTokens: envInt("WATSONX_MAX_CONTEXT_TOKENS", 8192),
+
+		StopSequences:     parseStopSequences(envList("WATSONX_STOP_SEQUENCES", nil)),
+		TopP:              envFloat("WATSONX_TOP_P", 0),
+		RepetitionPenalty: envFloat("WATSONX_REPETITION_PENALTY", 0),
+
+		ParseRetries:    envInt("WATSONX_PARSE_RETRIES", 1),
+		TemperatureStep: envFloat("WATSONX_TEMPERATURE_STEP", 0.2),
+		MaxTemperature:  clampTemperature("WATSONX_MAX_TEMPERATURE", envFloat("WATSONX_MAX_TEMPERATURE", 0.3)),
+
+		MaxRetries:     envInt("WATSONX_MAX_RETRIES", 3),
+		RetryBaseDelay: envDuration("WATSONX_RETRY_BASE_DELAY", 500*time.Millisecond),
+
+		UseChatAPI:  envBool("WATSONX_USE_CHAT", false),
+		EnforceJSON: envBool("WATSONX_ENFORCE_JSON", false),
+
+		IAMTimeout:        envTimeout("WATSONX_IAM_TIMEOUT", 10*time.Second),
+		GenerationTimeout: envTimeout("WATSONX_GENERATION_TIMEOUT", 30*time.Second),
+		RequestTimeout:    envTimeout("WATSONX_REQUEST_TIMEOUT", 90*time.Second),
+	}
+}
+
+// modelAllowed reports whether an event may request model.
+func (c WatsonConfig) modelAllowed(model string) bool {
+	return model == c.ModelID || containsString(c.AllowedModels, model)
+}
+
+// forEvent applies the event's model override, if any, and records its
+// mode. Callers validate the override with validateEvent first.
+func (c WatsonConfig) forEvent(event Event) WatsonConfig {
+	if event.ModelID != "" {
+		c.ModelID = event.ModelID
+	}
+	c.mode = event.Mode
+	return c
+}
+
+const (
+	minTemperature      = 0.0
+	maxTemperature      = 1.0
+	defaultMaxNewTokens = 400
+)
+
+// clampTemperature keeps env-supplied temperatures within range, warning
+// when it had to.
+func clampTemperature(name string, t float64) float64 {
+
+	clamped := math.Max(minTemperature, math.Min(maxTemperature, t))
+	if clamped != t {
+		LogFields("⚠️ Temperature out of range — clamped",
+			"var", name,
+			"value", t,
+			"using", clamped,
+		)
+	}
+
+	return clamped
+}
+
+// Validate rejects configs the API would fail on or answer with empty
+// output, for callers building a WatsonConfig themselves.
+func (c WatsonConfig) Validate() error {
+
+	switch {
+	case c.Temperature < minTemperature || c.Temperature > maxTemperature:
+		return fmt.Errorf("temperature %v out of range [%v, %v]", c.Temperature, minTemperature, maxTemperature)
+	case c.MaxTemperature < minTemperature || c.MaxTemperature > maxTemperature:
+		return fmt.Errorf("max temperature %v out of range [%v, %v]", c.MaxTemperature, minTemperature, maxTemperature)
+	case c.TemperatureStep < 0:
+		return fmt.Errorf("temperature step %v must not be negative", c.TemperatureStep)
+	case c.MaxNewTokens <= 0:
+		return fmt.Errorf("max new tokens must be positive, got %d", c.MaxNewTokens)
+	case c.MaxContextTokens != 0 && c.MaxContextTokens <= c.MaxNewTokens:
+		return fmt.Errorf("max context tokens %d must exceed max new tokens %d", c.MaxContextTokens, c.MaxNewTokens)
+	case c.TopP < 0 || c.TopP > 1:
+		return fmt.Errorf("top_p %v out of range (0, 1]", c.TopP)
+	case c.RepetitionPenalty != 0 && (c.RepetitionPenalty < 1 || c.RepetitionPenalty > 2):
+		return fmt.Errorf("repetition penalty %v out of range [1, 2]", c.RepetitionPenalty)
+	case c.ParseRetries < 0 || c.MaxRetries < 0:
+		return fmt.Errorf("retry counts must not be negative (parse %d, http %d)", c.ParseRetries, c.MaxRetries)
+	case c.IAMTimeout < 0 || c.GenerationTimeout < 0 || c.RequestTimeout < 0:
+		return fmt.Errorf("timeouts must not be negative (iam %v, generation %v, request %v)", c.IAMTimeout, c.GenerationTimeout, c.RequestTimeout)
+	case !validBaseURL(c.MLEndpointBase) || !validBaseURL(c.IAMURL):
+		return fmt.Errorf("endpoint URLs must be absolute http(s) URLs (ml %q, iam %q)", c.MLEndpointBase, c.IAMURL)
+	}
+
+	return nil
+}
+
+// validBaseURL accepts "" (unset) or an absolute http(s) URL.
+func validBaseURL(raw string) bool {
+
+	if raw == "" {
+		return true
+	}
+
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// hasEndpoint reports whether the ML endpoint is known: from the region
+// or from MLEndpointBase.
+func (c WatsonConfig) hasEndpoint() bool {
+	return c.Region != "" || c.MLEndpointBase != ""
+}
+
+/* ---------------- TIMEOUTS ---------------- */
+
+// Timeout causes wrap context.DeadlineExceeded so failureReason still
+// reports "timeout" whichever one tripped.
+var (
+	errIAMTimeout        = fmt.Errorf("IAM token fetch timed out: %w", context.DeadlineExceeded)
+	errGenerationTimeout = fmt.Errorf("Watsonx generation timed out: %w", context.DeadlineExceeded)
+	errRequestTimeout    = fmt.Errorf("Watsonx request deadline exceeded: %w", context.DeadlineExceeded)
+)
+
+// withStepTimeout bounds one step of a Watson call, recording cause so a
+// timeout can be told apart from the caller's own deadline. d <= 0 only
+// adds cancellation.
+func withStepTimeout(ctx context.Context, d time.Duration, cause error) (context.Context, context.CancelFunc) {
+
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, d, cause)
+}
+
+// stepTimeoutError names the step whose timeout ended ctx, unless err
+// already does.
+func stepTimeoutError(ctx context.Context, d time.Duration, err error) error {
+
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	cause := context.Cause(ctx)
+	if cause == ctx.Err() || errors.Is(err, cause) {
+		return err
+	}
+
+	return fmt.Errorf("%w after %v: %w", cause, d, err)
+}
+
+// splitAPIKeys splits the comma-separated key list, trimming whitespace
+// and dropping empty entries ("a, b ,c," → [a b c]).
+func splitAPIKeys(raw string) []string {
+
+	var keys []string
+	for _, k := range strings.Split(raw, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// parseAPIKeys reads entries of the form "key" or "key:weight". Missing
+// or invalid weights default to 1.
+func parseAPIKeys(raw string) ([]string, []int) {
+
+	var keys []string
+	var weights []int
+
+	for _, entry := range splitAPIKeys(raw) {
+
+		key, weight := entry, 1
+
+		if i := strings.LastIndex(entry, ":"); i > 0 {
+			if w, err := strconv.Atoi(entry[i+1:]); err == nil {
+				key = strings.TrimSpace(entry[:i])
+				if w > 0 {
+					weight = w
+				}
+			}
+		}
+
+		keys = append(keys, key)
+		weights = append(weights, weight)
+	}
+
+	return keys, weights
+}
+
+var (
+	watsonConfig     WatsonConfig
+	watsonConfigOnce sync.Once
 )
 
+// getWatsonConfig loads the config on first use, after .env is loaded.
+func getWatsonConfig() WatsonConfig {
+	watsonConfigOnce.Do(func() {
+		watsonConfig = DefaultWatsonConfig()
+	})
+	return watsonConfig
+}
+
 /* ---------------- API KEY ROTATION ---------------- */
 
 var (
-	apiKeys  []string
-	keyIndex int
-	keyMutex sync.Mutex
+	apiKeys    []string
+	keyWeights []int
+	keyCurrent []int
+	keyBadTill = map[string]time.Time{}
+	keyMutex   sync.Mutex
 )
 
+func loadAPIKeysLocked() error {
+
+	if len(apiKeys) == 0 {
+		cfg := getWatsonConfig()
+		if len(cfg.APIKeys) == 0 {
+			return errors.New("WATSONX_API_KEYS not set")
+		}
+
+		apiKeys = cfg.APIKeys
+		keyWeights = cfg.KeyWeights
+		keyCurrent = make([]int, len(apiKeys))
+	}
+
+	return nil
+}
+
+// getNextAPIKey uses smooth weighted round-robin: with weights 3 and 1
+// the keys are picked A A B A, and equal weights give plain round-robin.
+// Keys that recently failed auth are skipped; when every key is cooling
+// down all of them are eligible again.
 func getNextAPIKey() (string, error) {
 	keyMutex.Lock()
 	defer keyMutex.Unlock()
 
-	if len(apiKeys) == 0 {
-		raw := os.Getenv("WATSONX_API_KEYS")
-		if raw == "" {
-			return "", errors.New("WATSONX_API_KEYS not set")
+	if err := loadAPIKeysLocked(); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	eligible := make([]int, 0, len(apiKeys))
+	for i, key := range apiKeys {
+		if now.After(keyBadTill[key]) {
+			eligible = append(eligible, i)
+		}
+	}
+
+	if len(eligible) == 0 {
+		for i := range apiKeys {
+			eligible = append(eligible, i)
+		}
+	}
+
+	best, total := -1, 0
+	for _, i := range eligible {
+		keyCurrent[i] += keyWeights[i]
+		total += keyWeights[i]
+
+		if best == -1 || keyCurrent[i] > keyCurrent[best] {
+			best = i
+		}
+	}
+
+	keyCurrent[best] -= total
+	return apiKeys[best], nil
+}
+
+func apiKeyCount() int {
+	keyMutex.Lock()
+	defer keyMutex.Unlock()
+
+	if err := loadAPIKeysLocked(); err != nil {
+		return 0
+	}
+	return len(apiKeys)
+}
+
+// healthyKeyCount counts configured keys not cooling down after a failure.
+func healthyKeyCount() int {
+	keyMutex.Lock()
+	defer keyMutex.Unlock()
+
+	if err := loadAPIKeysLocked(); err != nil {
+		return 0
+	}
+
+	now := time.Now()
+	n := 0
+	for _, key := range apiKeys {
+		if now.After(keyBadTill[key]) {
+			n++
 		}
-		apiKeys = strings.Split(raw, ",")
+	}
+	return n
+}
+
+// markKeyFailed skips the key for WATSONX_KEY_COOLDOWN (default 5m).
+func markKeyFailed(key string) {
+	keyMutex.Lock()
+	defer keyMutex.Unlock()
+
+	keyBadTill[key] = time.Now().Add(envDuration("WATSONX_KEY_COOLDOWN", 5*time.Minute))
+}
+
+/* ---------------- HTTP STATUS ERRORS ---------------- */
+
+type statusError struct {
+	Service string
+	Code    int
+	Body    string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s failed %d: %s", e.Service, e.Code, e.Body)
+}
+
+// isKeyFailure reports errors tied to the API key itself: IAM rejecting
+// it (IAM answers 400 for unknown keys) or the ML endpoint refusing or
+// rate-limiting its token.
+func isKeyFailure(err error) bool {
+
+	var se *statusError
+	if !errors.As(err, &se) {
+		return false
 	}
 
-	key := strings.TrimSpace(apiKeys[keyIndex])
-	keyIndex = (keyIndex + 1) % len(apiKeys)
-	return key, nil
+	switch se.Service {
+	case "IAM auth":
+		return se.Code == 400 || se.Code == 401 || se.Code == 403
+	case "Watsonx":
+		return se.Code == 401 || se.Code == 403 || se.Code == 429
+	}
+
+	return false
 }
 
 /* ---------------- IAM TOKEN CACHE ---------------- */
@@ -46,41 +443,106 @@ type tokenEntry struct {
 	expiry time.Time
 }
 
+// tokenMutex only guards tokenCache; fetches run outside it, and
+// tokenFetches coalesces concurrent fetches for the same key so different
+// keys never wait on each other.
 var (
-	tokenCache = map[string]tokenEntry{}
-	tokenMutex sync.Mutex
+	tokenCache   = map[string]tokenEntry{}
+	tokenMutex   sync.Mutex
+	tokenFetches singleflight.Group
 )
 
-func getIAMToken(apiKey string) (string, error) {
+// iamTokenURL is the default of WATSONX_IAM_URL.
+var iamTokenURL = "https://iam.cloud.ibm.com/identity/token"
+
+func (c WatsonConfig) retryPolicy() retryPolicy {
+	return retryPolicy{MaxRetries: c.MaxRetries, BaseDelay: c.RetryBaseDelay}
+}
+
+func cachedIAMToken(apiKey string) (string, bool) {
 
 	tokenMutex.Lock()
 	defer tokenMutex.Unlock()
 
-	if entry, ok := tokenCache[apiKey]; ok {
-		if time.Now().Before(entry.expiry) {
-			return entry.token, nil
+	if entry, ok := tokenCache[apiKey]; ok && time.Now().Before(entry.expiry) {
+		return entry.token, true
+	}
+
+	return "", false
+}
+
+func getIAMToken(ctx context.Context, apiKey string) (string, error) {
+
+	if token, ok := cachedIAMToken(apiKey); ok {
+		return token, nil
+	}
+
+	// The shared fetch must outlive any one caller giving up
+	fetchCtx := context.WithoutCancel(ctx)
+
+	ch := tokenFetches.DoChan(apiKey, func() (interface{}, error) {
+
+		// Another caller may have just finished fetching
+		if token, ok := cachedIAMToken(apiKey); ok {
+			return token, nil
+		}
+
+		return fetchIAMToken(fetchCtx, apiKey)
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
 		}
+		return res.Val.(string), nil
 	}
+}
+
+// fetchIAMToken exchanges apiKey for a bearer token and caches it.
+func fetchIAMToken(ctx context.Context, apiKey string) (_ string, err error) {
+
+	defer observeSince(iamTokenSeconds, time.Now())
+
+	ctx, span := startSpan(ctx, "iam.token")
+	defer endSpan(span, &err)
+
+	cfg := getWatsonConfig()
+
+	ctx, cancel := withStepTimeout(ctx, cfg.IAMTimeout, errIAMTimeout)
+	defer cancel()
+	defer func() { err = stepTimeoutError(ctx, cfg.IAMTimeout, err) }()
 
 	data := url.Values{}
 	data.Set("grant_type", "urn:ibm:params:oauth:grant-type:apikey")
 	data.Set("apikey", apiKey)
 
-	req, err := http.NewRequest(
-		"POST",
-		"https://iam.cloud.ibm.com/identity/token",
-		bytes.NewBufferString(data.Encode()),
-	)
-	if err != nil {
-		return "", err
+	client := newHTTPClient(0)
+
+	tokenURL := cfg.IAMURL
+	if tokenURL == "" {
+		tokenURL = iamTokenURL
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
+	resp, err := doWithRetry(ctx, client, cfg.retryPolicy(), "IAM", func() (*http.Request, error) {
 
-	client := &http.Client{Timeout: 30 * time.Second}
+		req, err := http.NewRequestWithContext(
+			ctx,
+			"POST",
+			tokenURL,
+			bytes.NewBufferString(data.Encode()),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
 
-	resp, err := client.Do(req)
+		return req, nil
+	})
 	if err != nil {
 		return "", err
 	}
@@ -88,7 +550,7 @@ func getIAMToken(apiKey string) (string, error) {
 
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("IAM auth failed %d: %s", resp.StatusCode, string(body))
+		return "", &statusError{Service: "IAM auth", Code: resp.StatusCode, Body: string(body)}
 	}
 
 	var tokenResp struct {
@@ -100,205 +562,761 @@ func getIAMToken(apiKey string) (string, error) {
 		return "", err
 	}
 
+	tokenMutex.Lock()
 	tokenCache[apiKey] = tokenEntry{
 		token:  tokenResp.AccessToken,
 		expiry: time.Now().Add(time.Duration(tokenResp.ExpiresIn-60) * time.Second),
 	}
+	tokenMutex.Unlock()
 
 	return tokenResp.AccessToken, nil
 }
 
-/* ---------------- BUILD RAG FROM RELEVANT CVEs ---------------- */
+/* ---------------- JSON EXTRACTOR ---------------- */
 
-func buildRagFromCVEs(cves []CVE) string {
+// extractJSON finds the answer object in the model output. Markdown
+// fences are dropped first; when the prose also holds other objects (an
+// echoed format example), the first valid one with a "severity" key
+// wins, then the first valid one, then the first balanced one. Output cut
+// off mid-object (max_new_tokens) is closed after its last complete
+// field and reported as truncated.
+func extractJSON(text string) (string, bool) {
 
-	if len(cves) == 0 {
-		return ""
+	objects, tail := jsonObjects(stripCodeFences(text))
+
+	recovered := closeTruncatedJSON(tail)
+	firstValid := ""
+
+	for _, obj := range objects {
+
+		var fields map[string]json.RawMessage
+		if json.Unmarshal([]byte(obj), &fields) != nil {
+			continue
+		}
+
+		if _, ok := fields["severity"]; ok {
+			return obj, false
+		}
+
+		if firstValid == "" {
+			firstValid = obj
+		}
+	}
+
+	if recovered != "" && (firstValid == "" || hasJSONKey(recovered, "severity")) {
+		return recovered, true
+	}
+
+	switch {
+	case firstValid != "":
+		return firstValid, false
+	case len(objects) > 0:
+		return objects[0], false
+	}
+
+	return "", false
+}
+
+func hasJSONKey(obj, key string) bool {
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(obj), &fields) != nil {
+		return false
 	}
 
-	// limit to top 5
-	if len(cves) > 5 {
-		cves = cves[:5]
+	_, ok := fields[key]
+	return ok
+}
+
+// closeTruncatedJSON turns an unterminated top-level object into valid
+// JSON by closing it after the last complete field, or returns "".
+func closeTruncatedJSON(fragment string) string {
+
+	if fragment == "" {
+		return ""
 	}
 
-	var b strings.Builder
-	b.WriteString("<Rag>\n")
+	var commas []int
+
+	depth := 0
+	inString, escaped := false, false
+
+	for i := 0; i < len(fragment); i++ {
 
-	for _, c := range cves {
+		ch := fragment[i]
 
-		score := "N/A"
-		if c.CVSSScore > 0 {
-			score = fmt.Sprintf("%.1f", c.CVSSScore)
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
 		}
 
-		b.WriteString(
-			fmt.Sprintf("%s - %s/%s - CVSS %s\n",
-				c.ID, c.Vendor, c.Product, score),
-		)
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+
+	// The fragment may end right after a value; otherwise cut at the
+	// top-level commas, last first
+	cuts := []int{len(fragment)}
+	for i := len(commas) - 1; i >= 0; i-- {
+		cuts = append(cuts, commas[i])
+	}
+
+	for _, cut := range cuts {
+
+		candidate := strings.TrimSpace(fragment[:cut]) + "}"
+		if json.Valid([]byte(candidate)) {
+			return candidate
+		}
 	}
 
-	b.WriteString("</Rag>\n")
-	return b.String()
+	return ""
 }
 
-/* ---------------- JSON EXTRACTOR ---------------- */
+// stripCodeFences removes ``` fence lines (with or without a language
+// tag), keeping what they enclose.
+func stripCodeFences(text string) string {
 
-func extractFirstJSON(text string) string {
+	if !strings.Contains(text, "```") {
+		return text
+	}
 
-	start := strings.Index(text, "{")
-	if start == -1 {
-		return ""
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		kept = append(kept, line)
 	}
 
-	braces := 0
-	for i := start; i < len(text); i++ {
-		switch text[i] {
+	return strings.Join(kept, "\n")
+}
+
+// jsonObjects returns every top-level balanced {...} in text, ignoring
+// braces inside JSON strings, and the unterminated object at the end of
+// text, if any.
+func jsonObjects(text string) (objects []string, tail string) {
+
+	depth, start := 0, -1
+	inString, escaped := false, false
+
+	for i := 0; i < len(text); i++ {
+
+		ch := text[i]
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = depth > 0
 		case '{':
-			braces++
+			if depth == 0 {
+				start = i
+			}
+			depth++
 		case '}':
-			braces--
-			if braces == 0 {
-				return text[start : i+1]
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					objects = append(objects, text[start:i+1])
+				}
 			}
 		}
 	}
 
-	return ""
+	if depth > 0 {
+		tail = text[start:]
+	}
+
+	return objects, tail
 }
 
 /* ---------------- CALL WATSONX ---------------- */
 
 func CallWatsonAI(event Event, cves []CVE) (UnifiedResponse, error) {
 
-	apiKey, err := getNextAPIKey()
-	if err != nil {
-		return UnifiedResponse{}, err
-	}
+	ragData := buildRagBlock(event, cves)
+
+	return CallWatsonAIContext(context.Background(), event, fitRagBlock(getWatsonConfig().forEvent(event), event, ragData))
+}
+
+// CallWatsonAIContext stops IAM and generation calls, including retry
+// backoff, as soon as ctx is cancelled. ragData goes into the prompt as
+// given: the caller has already fitted it to the context window.
+func CallWatsonAIContext(ctx context.Context, event Event, ragData string) (UnifiedResponse, error) {
 
-	region := os.Getenv("WATSONX_REGION")
-	projectID := os.Getenv("WATSONX_PROJECT_ID")
+	cfg := getWatsonConfig().forEvent(event)
 
-	if region == "" || projectID == "" {
+	if apiKeyCount() == 0 {
+		return UnifiedResponse{}, errors.New("WATSONX_API_KEYS not set")
+	}
+
+	if !cfg.hasEndpoint() || cfg.ProjectID == "" {
 		return UnifiedResponse{}, errors.New("Watsonx env vars missing")
 	}
 
-	token, err := getIAMToken(apiKey)
-	if err != nil {
-		return UnifiedResponse{}, err
+	if err := cfg.Validate(); err != nil {
+		return UnifiedResponse{}, fmt.Errorf("invalid Watsonx config: %w", err)
+	}
+
+	return analyzeWithModel(ctx, "Watsonx", cfg, event, ragData, func(ctx context.Context, prompt string, temperature float64, maxNewTokens int) ([]string, TokenUsage, error) {
+		return generateWithKeyRotation(ctx, cfg, prompt, temperature, maxNewTokens)
+	})
+}
+
+// generateFunc asks the model once for completions of prompt.
+type generateFunc func(ctx context.Context, prompt string, temperature float64, maxNewTokens int) ([]string, TokenUsage, error)
+
+// analyzeWithModel is the provider-independent part of an analysis: the
+// result cache, the prompt, parsing and parse retries. cfg supplies the
+// model ID and generation settings; provider only names it in logs.
+func analyzeWithModel(ctx context.Context, provider string, cfg WatsonConfig, event Event, ragData string, generate generateFunc) (UnifiedResponse, error) {
+
+	ctx, cancel := withStepTimeout(ctx, cfg.RequestTimeout, errRequestTimeout)
+	defer cancel()
+
+	// Identical recent events reuse the cached analysis
+	ttl := resultCacheTTL()
+	cacheKey := resultCacheKey(event, cfg.ModelID, ragData)
+	if ttl > 0 {
+		if cached, ok := results.get(cacheKey); ok {
+			cached.Usage = nil
+			return cached, nil
+		}
 	}
 
-	// 🔥 USE RELEVANT CVEs PASSED BY DISPATCHER
-	ragData := BuildCVERagBlockFromList(cves)
+	// 🔥 USE RAG BLOCK ASSEMBLED (AND FITTED) BY DISPATCHER
+	prompt := buildPrompt(event, ragData)
 
-	endpoint := fmt.Sprintf(
-		"https://%s.ml.cloud.ibm.com/ml/v1/text/generation?version=2024-01-10",
-		region,
+	LogFields("Calling "+provider,
+		"type", event.Type,
+		"rag_tokens", estimateTokens(ragData),
+		"prompt_tokens", estimateTokens(prompt),
 	)
 
-	prompt := fmt.Sprintf(
-`%s
+	// Parse failures are retried with a slightly higher temperature to
+	// break the model out of a bad deterministic path. HTTP errors are not.
+	temperature := cfg.Temperature
+	usage := TokenUsage{Model: cfg.ModelID}
 
-<System data>
-Event type: %s
-Event message: %s
-</System data>
+	for attempt := 0; ; attempt++ {
 
-<Instructions>
-Analyze the event.
+		texts, used, err := generate(ctx, prompt, temperature, maxNewTokensForMode(cfg, event.Mode))
+		usage.add(used)
+		if err != nil {
+			err = stepTimeoutError(ctx, cfg.RequestTimeout, err)
+			watsonFailures.WithLabelValues(failureReason(err)).Inc()
+			return UnifiedResponse{}, err
+		}
 
-Use CVE data ONLY if relevant.
-Do NOT mention RAG or system data.
+		result, ok := parseResults(event, texts)
+		if ok || attempt >= cfg.ParseRetries {
+			result.Usage = &usage
+			if !ok {
+				watsonFailures.WithLabelValues(failureParse).Inc()
+			} else if ttl > 0 && !result.Truncated {
+				results.put(cacheKey, result, ttl)
+			}
+			return result, nil
+		}
+
+		next := math.Min(temperature+cfg.TemperatureStep, cfg.MaxTemperature)
 
-Respond ONLY with valid JSON.
-No extra text.
+		LogFields("⚠️ Unparseable "+provider+" output — retrying",
+			"temperature", next,
+			"previous_temperature", temperature,
+		)
 
-Format:
-{
-  "severity": "low | medium | high | critical",
-  "explanation": "brief reason",
-  "recommended_action": "clear action"
+		temperature = next
+	}
 }
-</Instructions>
 
-<Question>
-Determine severity and recommended action.
-</Question>`,
-		ragData,
-		event.Type,
-		event.Message,
-	)
+/* ---------------- KEY ROTATION ON AUTH FAILURE ---------------- */
 
-	payload := map[string]interface{}{
-		"model_id":   "ibm/granite-3-8b-instruct",
-		"project_id": projectID,
+// withKeyRotation runs fn with a token for each configured key at most
+// once, moving on when IAM or Watsonx rejects the current key.
+func withKeyRotation(ctx context.Context, fn func(token string) error) error {
+
+	var lastErr error
+
+	for try := 0; try < apiKeyCount(); try++ {
+
+		apiKey, err := getNextAPIKey()
+		if err != nil {
+			return err
+		}
+
+		token, err := getIAMToken(ctx, apiKey)
+		if err == nil {
+			if err = fn(token); err == nil {
+				return nil
+			}
+		}
+
+		if !isKeyFailure(err) {
+			return err
+		}
+
+		markKeyFailed(apiKey)
+		dropCachedToken(apiKey)
+		lastErr = err
+
+		LogFields("⚠️ API key rejected — rotating to next key",
+			"key", "..."+keySuffix(apiKey),
+			"status", failureReason(err),
+			"error", err,
+		)
+	}
+
+	return lastErr
+}
+
+func generateWithKeyRotation(ctx context.Context, cfg WatsonConfig, prompt string, temperature float64, maxNewTokens int) ([]string, TokenUsage, error) {
+
+	var texts []string
+	usage := TokenUsage{Model: cfg.ModelID}
+
+	err := withKeyRotation(ctx, func(token string) (err error) {
+
+		ctx, span := startSpan(ctx, "watson.generate",
+			attribute.String("llm.model", cfg.ModelID),
+			attribute.Float64("llm.temperature", temperature),
+		)
+		defer endSpan(span, &err)
+
+		var used TokenUsage
+		texts, used, err = generateText(ctx, cfg, token, prompt, temperature, maxNewTokens)
+		usage.add(used)
+		return err
+	})
+
+	return texts, usage, err
+}
+
+func dropCachedToken(apiKey string) {
+	tokenMutex.Lock()
+	delete(tokenCache, apiKey)
+	tokenMutex.Unlock()
+}
+
+// keySuffix identifies a key in logs without leaking it.
+func keySuffix(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[len(key)-4:]
+}
+
+/* ---------------- GENERATION REQUEST ---------------- */
+
+// mlEndpoint is the URL of an ML API path, under MLEndpointBase when set
+// and https://<region>.ml.cloud.ibm.com otherwise.
+func mlEndpoint(cfg WatsonConfig, path string) string {
+
+	base := cfg.MLEndpointBase
+	if base == "" {
+		base = fmt.Sprintf("https://%s.ml.cloud.ibm.com", cfg.Region)
+	}
+
+	return fmt.Sprintf("%s/ml/v1/%s?version=2024-01-10", base, path)
+}
+
+func generationPayload(cfg WatsonConfig, prompt string, temperature float64, maxNewTokens int) map[string]interface{} {
+
+	params := map[string]interface{}{
+		"temperature":    temperature,
+		"max_new_tokens": maxNewTokens,
+	}
+
+	if len(cfg.StopSequences) > 0 {
+		params["stop_sequences"] = cfg.StopSequences
+	}
+	if cfg.TopP > 0 {
+		params["top_p"] = cfg.TopP
+	}
+	if cfg.RepetitionPenalty > 0 {
+		params["repetition_penalty"] = cfg.RepetitionPenalty
+	}
+
+	return map[string]interface{}{
+		"model_id":   cfg.ModelID,
+		"project_id": cfg.ProjectID,
 		"input":      prompt,
-		"parameters": map[string]interface{}{
-			"temperature":    0.1,
-			"max_new_tokens": 400,
+		"parameters": params,
+	}
+}
+
+// parseStopSequences turns the \n and \t escapes of an env list into
+// real newlines and tabs ("\n\nType:" → two newlines then "Type:").
+func parseStopSequences(raw []string) []string {
+
+	unescape := strings.NewReplacer(`\n`, "\n", `\t`, "\t")
+
+	out := make([]string, 0, len(raw))
+	for _, s := range raw {
+		out = append(out, unescape.Replace(s))
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	return out
+}
+
+// chatSystemPrompt frames the chat API conversation; the built prompt
+// (instructions, system data, RAG) is sent as the user message.
+const chatSystemPrompt = "You are a network security analyst. Follow the instructions in the user message and respond only with valid JSON."
+
+// The chat API has no repetition_penalty; stops and top_p carry over.
+func chatPayload(cfg WatsonConfig, prompt string, temperature float64, maxNewTokens int) map[string]interface{} {
+
+	payload := map[string]interface{}{
+		"model_id":   cfg.ModelID,
+		"project_id": cfg.ProjectID,
+		"messages": []map[string]string{
+			{"role": "system", "content": chatSystemPrompt},
+			{"role": "user", "content": prompt},
 		},
+		"temperature": temperature,
+		"max_tokens":  maxNewTokens,
 	}
 
-	body, _ := json.Marshal(payload)
+	if len(cfg.StopSequences) > 0 {
+		payload["stop"] = cfg.StopSequences
+	}
+	if cfg.TopP > 0 {
+		payload["top_p"] = cfg.TopP
+	}
+	if format := responseFormat(cfg); format != nil {
+		payload["response_format"] = format
+	}
 
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
-	if err != nil {
-		return UnifiedResponse{}, err
+	return payload
+}
+
+// generationRequest returns the endpoint and payload for cfg's API mode.
+func generationRequest(cfg WatsonConfig, prompt string, temperature float64, maxNewTokens int) (string, map[string]interface{}) {
+
+	if cfg.UseChatAPI {
+		return mlEndpoint(cfg, "text/chat"), chatPayload(cfg, prompt, temperature, maxNewTokens)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	return mlEndpoint(cfg, "text/generation"), generationPayload(cfg, prompt, temperature, maxNewTokens)
+}
+
+func generateText(ctx context.Context, cfg WatsonConfig, token, prompt string, temperature float64, maxNewTokens int) (_ []string, _ TokenUsage, err error) {
+
+	defer observeSince(watsonRequestSeconds, time.Now())
+
+	ctx, cancel := withStepTimeout(ctx, cfg.GenerationTimeout, errGenerationTimeout)
+	defer cancel()
+	defer func() { err = stepTimeoutError(ctx, cfg.GenerationTimeout, err) }()
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	endpoint, payload := generationRequest(cfg, prompt, temperature, maxNewTokens)
+
+	body, _ := json.Marshal(payload)
+
+	client := newHTTPClient(0)
+
+	resp, err := doWithRetry(ctx, client, cfg.retryPolicy(), "Watsonx", func() (*http.Request, error) {
+
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
 
-	resp, err := client.Do(req)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+
+		return req, nil
+	})
 	if err != nil {
-		return UnifiedResponse{}, err
+		return nil, TokenUsage{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		return UnifiedResponse{}, fmt.Errorf(
-			"Watsonx failed %d: %s",
-			resp.StatusCode,
-			string(body),
-		)
+
+		if cfg.UseChatAPI && rejectedResponseFormat(cfg, resp.StatusCode, string(body)) {
+			cfg.EnforceJSON = false
+			return generateText(ctx, cfg, token, prompt, temperature, maxNewTokens)
+		}
+
+		return nil, TokenUsage{}, &statusError{Service: "Watsonx", Code: resp.StatusCode, Body: string(body)}
+	}
+
+	if cfg.UseChatAPI {
+		return decodeChatResponse(cfg, resp.Body)
 	}
 
+	return decodeGenerationResponse(cfg, resp.Body)
+}
+
+func decodeGenerationResponse(cfg WatsonConfig, r io.Reader) ([]string, TokenUsage, error) {
+
 	var res struct {
 		Results []struct {
-			GeneratedText string `json:"generated_text"`
+			GeneratedText       string `json:"generated_text"`
+			InputTokenCount     int    `json:"input_token_count"`
+			GeneratedTokenCount int    `json:"generated_token_count"`
 		} `json:"results"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return UnifiedResponse{}, err
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, TokenUsage{}, err
+	}
+
+	usage := TokenUsage{Model: cfg.ModelID}
+	for _, r := range res.Results {
+		usage.InputTokens += r.InputTokenCount
+		usage.GeneratedTokens += r.GeneratedTokenCount
 	}
+	recordModelUsage(usage)
 
 	if len(res.Results) == 0 {
-		return UnifiedResponse{}, errors.New("empty response from Watsonx")
+		return nil, usage, errors.New("empty response from Watsonx")
 	}
 
-	raw := res.Results[0].GeneratedText
-	cleanJSON := extractFirstJSON(raw)
+	if len(res.Results) > 1 {
+		LogFields("⚠️ Watsonx returned more results than expected",
+			"results", len(res.Results),
+		)
+	}
+
+	texts := make([]string, 0, len(res.Results))
+	for _, r := range res.Results {
+		texts = append(texts, r.GeneratedText)
+	}
+
+	return texts, usage, nil
+}
+
+// decodeChatResponse reads choices[].message.content from text/chat (or
+// an OpenAI-compatible chat/completions endpoint).
+func decodeChatResponse(cfg WatsonConfig, r io.Reader) ([]string, TokenUsage, error) {
+
+	var res struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, TokenUsage{}, err
+	}
+
+	usage := TokenUsage{
+		Model:           cfg.ModelID,
+		InputTokens:     res.Usage.PromptTokens,
+		GeneratedTokens: res.Usage.CompletionTokens,
+	}
+	recordModelUsage(usage)
+
+	if len(res.Choices) == 0 {
+		return nil, usage, errors.New("empty chat response")
+	}
+
+	texts := make([]string, 0, len(res.Choices))
+	for _, c := range res.Choices {
+		texts = append(texts, c.Message.Content)
+	}
+
+	return texts, usage, nil
+}
+
+// parseResults parses every generation and selects one. ok is false only
+// when none of them could be parsed as JSON.
+func parseResults(event Event, texts []string) (UnifiedResponse, bool) {
+
+	parsed := make([]UnifiedResponse, 0, len(texts))
+	anyOK := false
+
+	for _, text := range texts {
+		p, ok := parseResponse(text)
+
+		LogFields("Watsonx result",
+			"type", event.Type,
+			"severity", p.Severity,
+			"raw_output", text,
+		)
+
+		anyOK = anyOK || ok
+		parsed = append(parsed, p)
+	}
+
+	return selectResult(parsed), anyOK
+}
+
+/* ---------------- RESPONSE PARSING ---------------- */
+
+func parseResponse(raw string) (UnifiedResponse, bool) {
+
+	cleanJSON, truncated := extractJSON(raw)
 
 	if cleanJSON == "" {
 		return UnifiedResponse{
 			Severity:          "unknown",
 			Explanation:       strings.TrimSpace(raw),
 			RecommendedAction: "Manual review required",
-		}, nil
+			RawOutput:         raw,
+		}, false
 	}
 
-	var ai UnifiedResponse
-	if err := json.Unmarshal([]byte(cleanJSON), &ai); err != nil {
+	// Confidence is decoded loosely: models send 85, 85.0, "85" or 0.85
+	var parsed struct {
+		UnifiedResponse
+		Confidence interface{} `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(cleanJSON), &parsed); err != nil {
 		return UnifiedResponse{
 			Severity:          "unknown",
 			Explanation:       cleanJSON,
 			RecommendedAction: "Manual review required",
-		}, nil
+			RawOutput:         raw,
+		}, false
+	}
+
+	ai := parsed.UnifiedResponse
+
+	// Remediate answers carry no severity; the dispatcher fills it in
+	if ai.Severity != "" {
+		if normalized := normalizeSeverity(ai.Severity); normalized != ai.Severity {
+			LogFields("⚠️ Normalized model severity",
+				"severity", ai.Severity,
+				"to", normalized,
+			)
+			ai.Severity = normalized
+		}
+	}
+
+	ai.Confidence = parseConfidence(parsed.Confidence, ai.Severity)
+	ai.RawOutput = raw
+
+	if truncated {
+		LogFields("⚠️ Watsonx output truncated — parsed up to the last complete field",
+			"severity", ai.Severity,
+		)
+		ai.Truncated = true
+	}
+
+	return ai, true
+}
+
+/* ---------------- CONFIDENCE ---------------- */
+
+const defaultConfidence = 50
+
+// parseConfidence clamps the model's confidence to [0,100]. Fractions
+// below 1 are read as 0–1 probabilities. A missing or unreadable value
+// falls back to defaultConfidence, or 0 when there is no severity.
+func parseConfidence(v interface{}, severity string) int {
+
+	var f float64
+
+	switch c := v.(type) {
+	case float64:
+		f = c
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(c), "%"), 64)
+		if err != nil {
+			return fallbackConfidence(severity)
+		}
+		f = n
+	default:
+		return fallbackConfidence(severity)
+	}
+
+	if f > 0 && f < 1 {
+		f *= 100
+	}
+
+	return int(math.Round(math.Max(0, math.Min(100, f))))
+}
+
+func fallbackConfidence(severity string) int {
+
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "", "unknown":
+		return 0
+	}
+
+	return defaultConfidence
+}
+
+/* ---------------- RESULT SELECTION ---------------- */
+
+// selectResult picks the answer to return when Watsonx produced one or more
+// generations. A single result is returned as-is; with several, the severity
+// chosen by most generations wins (ties go to the earliest) and the first
+// result carrying that severity is returned. Unparseable results ("unknown")
+// only win when nothing else was parsed.
+func selectResult(results []UnifiedResponse) UnifiedResponse {
+
+	if len(results) == 1 {
+		return results[0]
+	}
+
+	votes := map[string]int{}
+	for _, r := range results {
+		if r.Severity != "unknown" {
+			votes[strings.ToLower(r.Severity)]++
+		}
+	}
+
+	best, bestVotes := "", 0
+	for _, r := range results {
+		sev := strings.ToLower(r.Severity)
+		if votes[sev] > bestVotes {
+			best, bestVotes = sev, votes[sev]
+		}
+	}
+
+	if best == "" {
+		return results[0]
+	}
+
+	for _, r := range results {
+		if strings.ToLower(r.Severity) == best {
+			return r
+		}
 	}
 
-	return ai, nil
+	return results[0]
 }
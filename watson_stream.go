@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+/* ---------------- STREAMING GENERATION ---------------- */
+
+// CallWatsonAIStream generates via the generation_stream endpoint, calling
+// onChunk with each generated_text delta as it arrives. The full text is
+// parsed into a UnifiedResponse once the stream ends.
+func CallWatsonAIStream(ctx context.Context, event Event, ragData string, onChunk func(string)) (UnifiedResponse, error) {
+
+	cfg := getWatsonConfig().forEvent(event)
+
+	if apiKeyCount() == 0 {
+		return UnifiedResponse{}, errors.New("WATSONX_API_KEYS not set")
+	}
+
+	if !cfg.hasEndpoint() || cfg.ProjectID == "" {
+		return UnifiedResponse{}, errors.New("Watsonx env vars missing")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return UnifiedResponse{}, fmt.Errorf("invalid Watsonx config: %w", err)
+	}
+
+	ctx, cancel := withStepTimeout(ctx, cfg.RequestTimeout, errRequestTimeout)
+	defer cancel()
+
+	prompt := buildPrompt(event, ragData)
+
+	LogFields("Streaming from Watsonx",
+		"type", event.Type,
+		"rag_tokens", estimateTokens(ragData),
+		"prompt_tokens", estimateTokens(prompt),
+	)
+
+	var full strings.Builder
+	usage := TokenUsage{Model: cfg.ModelID}
+
+	err := withKeyRotation(ctx, func(token string) (err error) {
+		full.Reset()
+
+		ctx, span := startSpan(ctx, "watson.generate_stream", attribute.String("llm.model", cfg.ModelID))
+		defer endSpan(span, &err)
+
+		used, err := streamText(ctx, cfg, token, prompt, maxNewTokensForMode(cfg, event.Mode), func(delta string) {
+			full.WriteString(delta)
+			onChunk(delta)
+		})
+		usage.add(used)
+		return err
+	})
+	if err != nil {
+		err = stepTimeoutError(ctx, cfg.RequestTimeout, err)
+		watsonFailures.WithLabelValues(failureReason(err)).Inc()
+		return UnifiedResponse{}, err
+	}
+
+	result, ok := parseResults(event, []string{full.String()})
+	result.Usage = &usage
+	if !ok {
+		watsonFailures.WithLabelValues(failureParse).Inc()
+	}
+	return result, nil
+}
+
+func streamText(ctx context.Context, cfg WatsonConfig, token, prompt string, maxNewTokens int, onDelta func(string)) (usage TokenUsage, err error) {
+
+	defer observeSince(watsonRequestSeconds, time.Now())
+
+	endpoint := mlEndpoint(cfg, "text/generation_stream")
+
+	body, _ := json.Marshal(generationPayload(cfg, prompt, cfg.Temperature, maxNewTokens))
+
+	client := newHTTPClient(2 * time.Minute)
+
+	resp, err := doWithRetry(ctx, client, cfg.retryPolicy(), "Watsonx", func() (*http.Request, error) {
+
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+
+		return req, nil
+	})
+	if err != nil {
+		return usage, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return usage, &statusError{Service: "Watsonx", Code: resp.StatusCode, Body: string(body)}
+	}
+
+	// Token counts are running totals; the last frame carries the final ones
+	usage.Model = cfg.ModelID
+	defer func() {
+		recordModelUsage(usage)
+	}()
+
+	err = readSSE(resp.Body, func(data []byte) error {
+
+		var frame struct {
+			Results []struct {
+				GeneratedText       string `json:"generated_text"`
+				InputTokenCount     int    `json:"input_token_count"`
+				GeneratedTokenCount int    `json:"generated_token_count"`
+			} `json:"results"`
+		}
+
+		// Non-JSON frames (keep-alives, close markers) are skipped
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return nil
+		}
+
+		for _, r := range frame.Results {
+			if r.GeneratedText != "" {
+				onDelta(r.GeneratedText)
+			}
+			usage.InputTokens = max(usage.InputTokens, r.InputTokenCount)
+			usage.GeneratedTokens = max(usage.GeneratedTokens, r.GeneratedTokenCount)
+		}
+
+		return nil
+	})
+
+	return usage, err
+}
+
+/* ---------------- SSE PARSER ---------------- */
+
+// readSSE calls onData with the payload of each server-sent event. Lines
+// are buffered until complete, so frames split across reads are handled;
+// multi-line data fields are joined with "\n".
+func readSSE(r io.Reader, onData func([]byte) error) error {
+
+	reader := bufio.NewReader(r)
+	var data bytes.Buffer
+
+	flush := func() error {
+		if data.Len() == 0 {
+			return nil
+		}
+		defer data.Reset()
+		return onData(data.Bytes())
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		if len(line) > 0 || err == nil {
+			line = strings.TrimRight(line, "\r\n")
+
+			switch {
+			case line == "":
+				if ferr := flush(); ferr != nil {
+					return ferr
+				}
+			case strings.HasPrefix(line, "data:"):
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			}
+		}
+
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
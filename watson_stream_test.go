@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+/* ---------------- SSE STREAMING ---------------- */
+
+const sseFrames = "id: 1\nevent: message\ndata: {\"results\":[{\"generated_text\":\"{\\\"severity\\\":\"}]}\n\n" +
+	"data: {\"results\":[{\"generated_text\":\"\\\"high\\\"}\",\"generated_token_count\":4}]}\r\n\r\n" +
+	": keep-alive\n\n" +
+	"data: line one\ndata: line two\n"
+
+func TestReadSSEPartialFrames(t *testing.T) {
+
+	var frames []string
+	err := readSSE(iotest.OneByteReader(strings.NewReader(sseFrames)), func(data []byte) error {
+		frames = append(frames, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`{"results":[{"generated_text":"{\"severity\":"}]}`,
+		`{"results":[{"generated_text":"\"high\"}","generated_token_count":4}]}`,
+		"line one\nline two",
+	}
+	if fmt.Sprintf("%q", frames) != fmt.Sprintf("%q", want) {
+		t.Errorf("frames = %q\nwant %q", frames, want)
+	}
+}
+
+func TestStreamAnalysisAcrossSplitWrites(t *testing.T) {
+
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{sseFrames[:17], sseFrames[17:60], sseFrames[60:]} {
+			_, _ = w.Write([]byte(part))
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	var chunks []string
+	got, err := CallWatsonAIStream(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, "", func(delta string) {
+		chunks = append(chunks, delta)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(chunks, "") != `{"severity":"high"}` {
+		t.Errorf("chunks = %q", chunks)
+	}
+	if got.Severity != "high" || got.Usage == nil || got.Usage.GeneratedTokens != 4 {
+		t.Errorf("got %q usage %+v, want high with 4 generated tokens", got.Severity, got.Usage)
+	}
+}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/* ---------------- MULTIPLE RESULTS ---------------- */
+
+func TestDecodeGenerationResponseMultipleResults(t *testing.T) {
+
+	body := `{"results":[
+		{"generated_text":"{\"severity\":\"high\"}","input_token_count":100,"generated_token_count":10},
+		{"generated_text":"{\"severity\":\"low\"}","input_token_count":100,"generated_token_count":12}
+	]}`
+
+	texts, usage, err := decodeGenerationResponse(WatsonConfig{ModelID: "m"}, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(texts) != 2 {
+		t.Fatalf("texts = %d, want 2", len(texts))
+	}
+	if usage.InputTokens != 200 || usage.GeneratedTokens != 22 {
+		t.Errorf("usage = %+v, want tokens summed over results", usage)
+	}
+}
+
+func TestSelectResultMajoritySeverity(t *testing.T) {
+
+	got := selectResult([]UnifiedResponse{
+		{Severity: "unknown", Explanation: "garbage"},
+		{Severity: "low", Explanation: "first low"},
+		{Severity: "high", Explanation: "first high"},
+		{Severity: "high", Explanation: "second high"},
+	})
+
+	if got.Severity != "high" || got.Explanation != "first high" {
+		t.Errorf("got %q/%q, want the first of the majority severity", got.Severity, got.Explanation)
+	}
+}
+
+func TestAnalyzeMultiResultPayload(t *testing.T) {
+
+	stubWatsonx(t, generationReply(
+		`not json at all`,
+		`{"severity":"medium","explanation":"a","recommended_action":"b","confidence":70}`,
+		`{"severity":"critical","explanation":"c","recommended_action":"d","confidence":90}`,
+		`{"severity":"medium","explanation":"e","recommended_action":"f","confidence":60}`,
+	))
+
+	got, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Severity != "medium" || got.Explanation != "a" {
+		t.Errorf("got %q/%q, want medium/a", got.Severity, got.Explanation)
+	}
+}
+
+/* ---------------- ROOT CAUSE AND IMPACT ---------------- */
+
+func TestRootCauseAndImpactRoundTrip(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	stubWatsonx(t, generationReply(`{
+		"severity":"high",
+		"explanation":"uplink lost",
+		"root_cause":"fiber cut",
+		"impact":"branch offline",
+		"recommended_action":"dispatch field tech",
+		"confidence":80
+	}`))
+
+	w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var got UnifiedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Severity != "high" || got.Explanation != "uplink lost" || got.RootCause != "fiber cut" ||
+		got.Impact != "branch offline" || got.RecommendedAction != "dispatch field tech" {
+		t.Errorf("fields lost: %+v", got)
+	}
+}
+
+/* ---------------- TEMPERATURE ESCALATION ---------------- */
+
+// recordTemperatures answers with replies in turn (repeating the last)
+// and records the temperature of each generation request.
+func recordTemperatures(temps *[]float64, replies ...string) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var req struct {
+			Parameters struct {
+				Temperature float64 `json:"temperature"`
+			} `json:"parameters"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		reply := replies[min(len(*temps), len(replies)-1)]
+		*temps = append(*temps, req.Parameters.Temperature)
+
+		generationReply(reply)(w, r)
+	}
+}
+
+func TestParseRetryEscalatesTemperatureWithinBound(t *testing.T) {
+
+	var temps []float64
+	_, cfg := stubWatsonx(t, recordTemperatures(&temps, "not json"))
+
+	cfg.ParseRetries = 3
+	useWatsonConfig(t, cfg)
+
+	got, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []float64{0.1, 0.3, 0.3, 0.3} // 0.1 + 0.2, capped at 0.3
+	if fmt.Sprint(temps) != fmt.Sprint(want) {
+		t.Errorf("temperatures = %v, want %v", temps, want)
+	}
+	if got.Severity != "unknown" {
+		t.Errorf("severity = %q, want unknown after the last retry", got.Severity)
+	}
+}
+
+func TestParseRetryStopsOnValidOutput(t *testing.T) {
+
+	var temps []float64
+	stubWatsonx(t, recordTemperatures(&temps, "not json", `{"severity":"low","explanation":"ok","recommended_action":"none"}`))
+
+	got, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Severity != "low" || len(temps) != 2 || temps[1] <= temps[0] {
+		t.Errorf("severity %q after temperatures %v, want low after one escalated retry", got.Severity, temps)
+	}
+}
+
+func TestHTTPErrorIsNotParseRetried(t *testing.T) {
+
+	var temps []float64
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		temps = append(temps, 0)
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	cfg.ParseRetries = 3
+	useWatsonConfig(t, cfg)
+
+	if _, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, ""); err == nil {
+		t.Fatal("want the HTTP error")
+	}
+	if len(temps) != 1 {
+		t.Errorf("%d generation requests for a 400, want 1", len(temps))
+	}
+}
+
+/* ---------------- RETRY ON 429 AND 5XX ---------------- */
+
+func TestAnalyzeRetries503ThenParses(t *testing.T) {
+
+	calls := 0
+	stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		generationReply(`{"severity":"high","explanation":"x","recommended_action":"y"}`)(w, r)
+	})
+
+	got, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Severity != "high" || calls != 3 {
+		t.Errorf("severity %q after %d calls, want high after 3", got.Severity, calls)
+	}
+}
+
+func TestAnalyzeGivesUpAfterRetryBudget(t *testing.T) {
+
+	calls := 0
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	cfg.MaxRetries = 2
+	useWatsonConfig(t, cfg)
+
+	if _, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, ""); err == nil {
+		t.Fatal("want an error once retries are exhausted")
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 1 + MaxRetries", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+
+	if d, ok := parseRetryAfter("2"); !ok || d != 2*time.Second {
+		t.Errorf("seconds form: %v %v", d, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("garbage accepted")
+	}
+}
+
+/* ---------------- API KEY PARSING ---------------- */
+
+func TestSplitAPIKeysTrims(t *testing.T) {
+
+	got := splitAPIKeys("a, b ,c,")
+	if fmt.Sprint(got) != "[a b c]" || len(got) != 3 {
+		t.Errorf("got %q, want [a b c]", got)
+	}
+}
+
+/* ---------------- WEIGHTED KEY ROTATION ---------------- */
+
+func TestParseAPIKeysWeights(t *testing.T) {
+
+	keys, weights := parseAPIKeys("a:3, b, c:0, d:x")
+
+	if fmt.Sprint(keys) != "[a b c d:x]" || fmt.Sprint(weights) != "[3 1 1 1]" {
+		t.Errorf("got %q %v", keys, weights)
+	}
+}
+
+func TestWeightedRotationDistribution(t *testing.T) {
+
+	cfg := testWatsonConfig("http://unused")
+	cfg.APIKeys = []string{"big", "small"}
+	cfg.KeyWeights = []int{3, 1}
+	useWatsonConfig(t, cfg)
+
+	counts := map[string]int{}
+	for i := 0; i < 400; i++ {
+		key, err := getNextAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[key]++
+	}
+
+	if counts["big"] != 300 || counts["small"] != 100 {
+		t.Errorf("counts = %v, want 3:1", counts)
+	}
+}
+
+/* ---------------- CONFIDENCE ---------------- */
+
+func TestParseResponseConfidence(t *testing.T) {
+
+	tests := []struct {
+		reply string
+		want  int
+	}{
+		{`{"severity":"high","confidence":150}`, 100},
+		{`{"severity":"high","confidence":-5}`, 0},
+		{`{"severity":"high","confidence":"85"}`, 85},
+		{`{"severity":"high","confidence":0.85}`, 85},
+		{`{"severity":"high"}`, defaultConfidence},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseResponse(tt.reply)
+		if !ok {
+			t.Fatalf("parseResponse(%s) failed", tt.reply)
+		}
+		if got.Confidence != tt.want {
+			t.Errorf("parseResponse(%s).Confidence = %d, want %d", tt.reply, got.Confidence, tt.want)
+		}
+	}
+}
+
+/* ---------------- SEVERITY NORMALIZATION ---------------- */
+
+func TestParseResponseNormalizesSeverity(t *testing.T) {
+
+	tests := map[string]string{
+		"SEV1":          "critical",
+		"Warning":       "medium",
+		"informational": "info",
+		"purple":        severityUnknown,
+	}
+
+	for in, want := range tests {
+		got, ok := parseResponse(`{"severity":"` + in + `"}`)
+		if !ok {
+			t.Fatalf("parseResponse failed for %q", in)
+		}
+		if got.Severity != want {
+			t.Errorf("severity %q → %q, want %q", in, got.Severity, want)
+		}
+	}
+}
+
+/* ---------------- CHAT API ---------------- */
+
+func TestGenerationRequestPerMode(t *testing.T) {
+
+	cfg := testWatsonConfig("https://ml.example")
+
+	endpoint, payload := generationRequest(cfg, "PROMPT", 0.1, 400)
+	if endpoint != "https://ml.example/ml/v1/text/generation?version=2024-01-10" {
+		t.Errorf("completion endpoint = %s", endpoint)
+	}
+	if payload["input"] != "PROMPT" || payload["messages"] != nil {
+		t.Errorf("completion payload = %v, want input and no messages", payload)
+	}
+
+	cfg.UseChatAPI = true
+
+	endpoint, payload = generationRequest(cfg, "PROMPT", 0.1, 400)
+	if endpoint != "https://ml.example/ml/v1/text/chat?version=2024-01-10" {
+		t.Errorf("chat endpoint = %s", endpoint)
+	}
+	messages, _ := payload["messages"].([]map[string]string)
+	if len(messages) != 2 || messages[0]["role"] != "system" ||
+		messages[1]["role"] != "user" || messages[1]["content"] != "PROMPT" {
+		t.Errorf("chat messages = %v, want system + user prompt", payload["messages"])
+	}
+	if payload["input"] != nil {
+		t.Errorf("chat payload carries completion input: %v", payload)
+	}
+}
+
+func TestChatAPIRoundTrip(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var path string
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		writeJSON(w, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": `{"severity":"low","explanation":"chat"}`}},
+			},
+		})
+	})
+	cfg.UseChatAPI = true
+	useWatsonConfig(t, cfg)
+
+	w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	if !strings.HasSuffix(path, "/text/chat") {
+		t.Errorf("request went to %s, want text/chat", path)
+	}
+	if !strings.Contains(w.Body.String(), `"explanation":"chat"`) {
+		t.Errorf("chat content not parsed: %s", w.Body)
+	}
+}
+
+/* ---------------- PER-EVENT MODEL ---------------- */
+
+func TestEventModelOverride(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var model string
+	_, cfg := stubWatsonx(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ModelID string `json:"model_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		model = payload.ModelID
+		generationReply(`{"severity":"low"}`)(w, r)
+	})
+	cfg.AllowedModels = []string{"ibm/granite-13b-instruct-v2"}
+	useWatsonConfig(t, cfg)
+
+	tests := []struct {
+		name, body string
+		status     int
+		want       string
+	}{
+		{"default", `{"type":"link_down","message":"a down"}`, http.StatusOK, cfg.ModelID},
+		{"override", `{"type":"link_down","message":"b down","model_id":"ibm/granite-13b-instruct-v2"}`, http.StatusOK, "ibm/granite-13b-instruct-v2"},
+		{"rejected", `{"type":"link_down","message":"c down","model_id":"meta-llama/llama-3-70b"}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			model = ""
+			w := postEvent(t, tt.body)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if model != tt.want {
+				t.Errorf("Watsonx called with model %q, want %q", model, tt.want)
+			}
+		})
+	}
+}
+
+/* ---------------- CONFIG BOUNDS ---------------- */
+
+func TestValidateRejectsOutOfRangeConfig(t *testing.T) {
+
+	tests := map[string]func(*WatsonConfig){
+		"temperature": func(c *WatsonConfig) { c.Temperature = 2.0 },
+		"negative":    func(c *WatsonConfig) { c.Temperature = -0.1 },
+		"max new":     func(c *WatsonConfig) { c.MaxNewTokens = 0 },
+	}
+
+	for name, mutate := range tests {
+		cfg := testWatsonConfig("https://ml.example")
+		mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate accepted %+v", name, cfg)
+		}
+	}
+
+	if err := testWatsonConfig("https://ml.example").Validate(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+}
+
+func TestDefaultConfigClampsTemperature(t *testing.T) {
+
+	t.Setenv("WATSONX_TEMPERATURE", "2.0")
+
+	if got := DefaultWatsonConfig().Temperature; got != maxTemperature {
+		t.Errorf("Temperature = %v, want clamped to %v", got, maxTemperature)
+	}
+}
+
+/* ---------------- IAM TOKEN COALESCING ---------------- */
+
+// Run with -race: concurrent fetches share the token cache.
+func TestConcurrentIAMTokenFetchesCoalesce(t *testing.T) {
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		writeJSON(w, map[string]interface{}{"access_token": "token-" + r.FormValue("apikey"), "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	cfg := testWatsonConfig(srv.URL)
+	cfg.IAMURL = srv.URL
+	useWatsonConfig(t, cfg)
+
+	const n = 20
+	tokens := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := getIAMToken(context.Background(), "key-a")
+			if err != nil {
+				t.Error(err)
+			}
+			tokens <- token
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("IAM calls = %d for %d concurrent requests, want 1", got, n)
+	}
+	for token := range tokens {
+		if token != "token-key-a" {
+			t.Errorf("token = %q", token)
+		}
+	}
+}
+
+func TestIAMTokenFetchesForDifferentKeysRunInParallel(t *testing.T) {
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// key-a's fetch only finishes once key-b's has
+		if r.FormValue("apikey") == "key-a" {
+			<-release
+		} else {
+			defer close(release)
+		}
+		writeJSON(w, map[string]interface{}{"access_token": "t", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	cfg := testWatsonConfig(srv.URL)
+	cfg.IAMURL = srv.URL
+	useWatsonConfig(t, cfg)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := getIAMToken(context.Background(), "key-a")
+		done <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := getIAMToken(ctx, "key-b"); err != nil {
+		t.Fatalf("key-b waited on key-a: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+/* ---------------- STEP TIMEOUTS ---------------- */
+
+func TestSlowIAMTripsIAMTimeout(t *testing.T) {
+
+	var generations atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/identity/token" {
+			_ = r.ParseForm() // lets the server notice the client giving up
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		generations.Add(1)
+		generationReply(`{"severity":"low"}`)(w, r)
+	}))
+	defer srv.Close()
+
+	cfg := testWatsonConfig(srv.URL)
+	cfg.MaxRetries = 0
+	cfg.IAMTimeout = 50 * time.Millisecond
+	cfg.GenerationTimeout = 5 * time.Second
+	useWatsonConfig(t, cfg)
+
+	_, err := CallWatsonAIContext(context.Background(), Event{Type: "link_down", Message: "Gi0/1 down"}, "")
+
+	if !errors.Is(err, errIAMTimeout) {
+		t.Fatalf("err = %v, want the IAM timeout", err)
+	}
+	if errors.Is(err, errGenerationTimeout) || errors.Is(err, errRequestTimeout) {
+		t.Errorf("err = %v also names another timeout", err)
+	}
+	if n := generations.Load(); n != 0 {
+		t.Errorf("generation called %d times without a token", n)
+	}
+	if failureReason(err) != "timeout" {
+		t.Errorf("failureReason = %q, want timeout", failureReason(err))
+	}
+}
+
+func TestZeroTimeoutDisablesStep(t *testing.T) {
+
+	t.Setenv("WATSONX_IAM_TIMEOUT", "0")
+	t.Setenv("WATSONX_GENERATION_TIMEOUT", "0s")
+	t.Setenv("WATSONX_REQUEST_TIMEOUT", "")
+
+	cfg := DefaultWatsonConfig()
+	if cfg.IAMTimeout != 0 || cfg.GenerationTimeout != 0 {
+		t.Errorf("timeouts = %v/%v, want 0 (disabled)", cfg.IAMTimeout, cfg.GenerationTimeout)
+	}
+	if cfg.RequestTimeout != 90*time.Second {
+		t.Errorf("RequestTimeout = %v, want the 90s default", cfg.RequestTimeout)
+	}
+
+	ctx, cancel := withStepTimeout(context.Background(), 0, errIAMTimeout)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("0 timeout set a deadline")
+	}
+}
+
+/* ---------------- FENCED AND DECOY JSON ---------------- */
+
+func TestParseResponseFencedJSON(t *testing.T) {
+
+	raw := "Here is the analysis:\n```json\n{\"severity\":\"high\",\"explanation\":\"uplink lost\"}\n```\n"
+
+	got, ok := parseResponse(raw)
+	if !ok || got.Severity != "high" || got.Explanation != "uplink lost" {
+		t.Errorf("parseResponse(fenced) = %+v, %v", got, ok)
+	}
+}
+
+func TestParseResponseSkipsDecoyObject(t *testing.T) {
+
+	raw := `The format is {"type":"example","note":"fill in"}. Answer:
+{"severity":"critical","explanation":"core down"}`
+
+	got, ok := parseResponse(raw)
+	if !ok || got.Severity != "critical" || got.Explanation != "core down" {
+		t.Errorf("parseResponse(decoy first) = %+v, %v", got, ok)
+	}
+}
+
+/* ---------------- TRUNCATED JSON ---------------- */
+
+func TestParseResponseTruncatedAfterExplanation(t *testing.T) {
+
+	tests := map[string]string{
+		"mid value":   `{"severity":"high","explanation":"The uplink {Gi0/1} flapped and`,
+		"after value": `{"severity":"high","explanation":"The uplink {Gi0/1} flapped",`,
+		"mid key":     `{"severity":"high","explanation":"The uplink {Gi0/1} flapped","recommended_ac`,
+	}
+
+	for name, raw := range tests {
+		got, ok := parseResponse(raw)
+		if !ok || !got.Truncated {
+			t.Errorf("%s: ok=%v truncated=%v, want a recovered truncated result", name, ok, got.Truncated)
+			continue
+		}
+		if got.Severity != "high" {
+			t.Errorf("%s: severity = %q, want high", name, got.Severity)
+		}
+		if strings.Contains(got.Explanation, "severity") {
+			t.Errorf("%s: raw JSON dumped into explanation: %q", name, got.Explanation)
+		}
+	}
+
+	got, _ := parseResponse(tests["after value"])
+	if got.Explanation != "The uplink {Gi0/1} flapped" {
+		t.Errorf("explanation = %q, want the complete value with its braces", got.Explanation)
+	}
+}
+
+/* ---------------- GENERATION PARAMETERS ---------------- */
+
+// captureParameters answers like text/generation and stores the request's
+// parameters map in *params.
+func captureParameters(params *map[string]json.RawMessage) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Parameters map[string]json.RawMessage `json:"parameters"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		*params = payload.Parameters
+		generationReply(`{"severity":"low"}`)(w, r)
+	}
+}
+
+func TestCustomParametersReachPayload(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+	t.Setenv("WATSONX_STOP_SEQUENCES", `\n\nType:,</System data>`)
+
+	var params map[string]json.RawMessage
+	_, cfg := stubWatsonx(t, captureParameters(&params))
+	cfg.StopSequences = parseStopSequences(envList("WATSONX_STOP_SEQUENCES", nil))
+	cfg.TopP = 0.9
+	cfg.RepetitionPenalty = 1.1
+	useWatsonConfig(t, cfg)
+
+	if w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var stops []string
+	_ = json.Unmarshal(params["stop_sequences"], &stops)
+	if len(stops) != 2 || stops[0] != "\n\nType:" || stops[1] != "</System data>" {
+		t.Errorf("parameters.stop_sequences = %q", stops)
+	}
+
+	for key, value := range map[string]string{"top_p": "0.9", "repetition_penalty": "1.1"} {
+		if got := string(params[key]); got != value {
+			t.Errorf("parameters.%s = %s, want %s", key, got, value)
+		}
+	}
+}
+
+func TestUnsetParametersOmitted(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var params map[string]json.RawMessage
+	stubWatsonx(t, captureParameters(&params))
+
+	if w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	for _, key := range []string{"stop_sequences", "top_p", "repetition_penalty"} {
+		if raw, ok := params[key]; ok {
+			t.Errorf("unset %s sent as %s", key, raw)
+		}
+	}
+	if len(parseStopSequences(nil)) != 0 || parseStopSequences([]string{}) != nil {
+		t.Error("an empty stop list must be nil so it is omitted")
+	}
+}
+
+/* ---------------- ENDPOINT OVERRIDES ---------------- */
+
+func TestEndpointOverrides(t *testing.T) {
+
+	t.Setenv("WATSONX_REGION", "eu-de")
+	t.Setenv("WATSONX_ML_URL", "")
+	t.Setenv("WATSONX_IAM_URL", "")
+
+	cfg := DefaultWatsonConfig()
+	if got, want := mlEndpoint(cfg, "text/generation"), "https://eu-de.ml.cloud.ibm.com/ml/v1/text/generation?version=2024-01-10"; got != want {
+		t.Errorf("default ML endpoint %q, want %q", got, want)
+	}
+	if cfg.IAMURL != "https://iam.cloud.ibm.com/identity/token" {
+		t.Errorf("default IAM URL %q", cfg.IAMURL)
+	}
+
+	t.Setenv("WATSONX_ML_URL", "https://ml.dedicated.example:8443/watsonx/")
+	t.Setenv("WATSONX_IAM_URL", "https://iam.dedicated.example/oidc/token")
+
+	cfg = DefaultWatsonConfig()
+	if got, want := mlEndpoint(cfg, "text/chat"), "https://ml.dedicated.example:8443/watsonx/ml/v1/text/chat?version=2024-01-10"; got != want {
+		t.Errorf("overridden ML endpoint %q, want %q", got, want)
+	}
+	if cfg.IAMURL != "https://iam.dedicated.example/oidc/token" {
+		t.Errorf("overridden IAM URL %q", cfg.IAMURL)
+	}
+}
+
+func TestMLOverrideReachesServer(t *testing.T) {
+
+	setFlags(t, map[string]bool{FlagRAG: false})
+
+	var paths []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/token") {
+			writeJSON(w, map[string]interface{}{"access_token": "token", "expires_in": 3600})
+			return
+		}
+		generationReply(`{"severity":"low"}`)(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := testWatsonConfig(srv.URL + "/dedicated")
+	cfg.IAMURL = srv.URL + "/custom-iam/token"
+	cfg.Region = "us-south" // ignored while the override is set
+	useWatsonConfig(t, cfg)
+
+	if w := postEvent(t, `{"type":"link_down","message":"Gi0/1 down"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"/custom-iam/token", "/dedicated/ml/v1/text/generation"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+}
+
+/* ---------------- PROMPT LOGGING ---------------- */
+
+func TestPromptNotLoggedAtDebug(t *testing.T) {
+
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_DROP_FIELDS", "")
+	stubWatsonx(t, generationReply(`{"severity":"low"}`))
+
+	const secret = "tacacs key s3cr3t-value rejected"
+
+	out := captureLog(t, func() {
+		if _, err := CallWatsonAIContext(context.Background(), Event{Type: "auth_fail", Message: secret}, ""); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if !strings.Contains(out, "prompt_tokens=") {
+		t.Errorf("analysis log lacks prompt_tokens: %s", out)
+	}
+	if strings.Contains(out, "s3cr3t-value") {
+		t.Errorf("event message logged with the prompt: %s", out)
+	}
+}